@@ -1,45 +1,374 @@
 package fileintegrity
 
 import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/device"
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/analysis/throttle"
+	"github.com/aicirt2012/fileintegrity/src/analysis/watch"
+	"github.com/aicirt2012/fileintegrity/src/config"
 	"github.com/aicirt2012/fileintegrity/src/store"
 	"github.com/aicirt2012/fileintegrity/src/store/check"
+	"github.com/aicirt2012/fileintegrity/src/store/diff"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/fsck"
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/aicirt2012/fileintegrity/src/store/lock"
+	"github.com/aicirt2012/fileintegrity/src/store/repair"
+	"github.com/aicirt2012/fileintegrity/src/store/retention"
+	"github.com/aicirt2012/fileintegrity/src/store/transfer"
 )
 
 // Set with linker flags
 var Version = "development"
 
 // Upsert inserts or updates entries into the integrity file. An update is performed when the actual file
-// modification date is after the file modification date of the stored entry.
+// modification date is after the file modification date of the stored entry. With a redundancy percentage
+// greater than zero, reed-solomon recovery data is created for new and updated files. With a chunk size greater
+// than zero, chunk hashes are stored for larger files to localise corrupted byte ranges during verification.
+// With the append-only option, only the tail of grown files with chunk hashes is hashed.
+// Reads are throttled according to the read rate, worker and priority options. The read rate and worker limits
+// can be changed at runtime with the control file .integrity/throttle, e.g. "rate=20MB" and "workers=2".
+// Symbolic links are ignored, recorded with their target or followed according to the symlink policy.
+// With the metadata option, mode bits, ownership and selected extended attributes are recorded. Metadata changes
+// of files with unchanged content are recorded as metadata operation.
 func Upsert(path string, options Options) error {
-	return store.Upsert(path, options.toStoreOptions())
+	if err := dir.CheckDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Exclusive, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer prune(path, options.Config)
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	return store.Upsert(path, storeOptions)
+}
+
+// Watch upserts the directory once and keeps the integrity file up to date afterwards until stop is closed.
+// Changes are detected by inotify on Linux, only changed files are hashed after the debounce period and the
+// integrity file is defragmented each interval. Without change notifications, the directory is rescanned
+// each interval instead.
+// The directory is locked exclusively only while changes are processed, so verify can run in between.
+func Watch(path string, stop <-chan bool, options Options) error {
+	if err := dir.CheckDir(path); err != nil {
+		return err
+	}
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	err = store.Watch(path, storeOptions, stop)
+	// Expired files are removed with the exclusive lock like after the other writing executions
+	if release, lockErr := lock.Acquire(path, lock.Exclusive, options.LockWait); lockErr == nil {
+		prune(path, options.Config)
+		release()
+	}
+	return err
 }
 
 // Verify verifies that the actual file hash is similar to the hash stored in the integrity file entry.
+// Corrupted byte ranges are reported for files with chunk hashes. Changed metadata is reported as drift.
+// Reads are throttled like within Upsert.
 func Verify(path string, options Options) error {
-	return store.Verify(path, options.toStoreOptions())
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	return store.Verify(path, storeOptions)
 }
 
 // CheckDuplicates checks for duplicate files within the integrity file.
-func CheckDuplicates(path string, options Options) {
-	check.Duplicates(path, options.toStoreOptions())
+func CheckDuplicates(path string, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	check.Duplicates(path, storeOptions)
+	return nil
 }
 
 // CheckContained checks if files of an external directory are contained within the integrity file.
 // With the optional flag fix, contained and duplicated files are deleted form the external directory.
-func CheckContained(path string, externalPath string, fix bool, options Options) {
-	check.Contained(path, externalPath, fix, options.toStoreOptions())
+func CheckContained(path string, externalPath string, fix bool, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	if err := dir.CheckDir(externalPath); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	return check.Contained(path, externalPath, fix, storeOptions)
 }
 
 // CheckStyleIssues checks style issues related to the file system based on the integrity file.
 // Check categories are: Directory hierarchy issues, path and directory length issues, naming issues.
-func CheckStyleIssues(path string, options Options) {
-	check.StyleIssues(path, options.toStoreOptions())
+func CheckStyleIssues(path string, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	check.StyleIssues(path, storeOptions)
+	return nil
 }
 
 // CheckExtensionStats checks the distribution of file extensions based on the file size within the integrity file
-func CheckExtensionStats(path string, options Options) {
-	check.ExtensionStats(path, options.toStoreOptions())
+func CheckExtensionStats(path string, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	check.ExtensionStats(path, storeOptions)
+	return nil
+}
+
+// Diff compares the integrity files of two directories without reading any file content.
+// Reported are files only in A, only in B, modified files with an equal path and moved files with an equal hash.
+func Diff(path string, otherPath string, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	if err := dir.CheckIntegrityDir(otherPath); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	releaseOther, err := lock.Acquire(otherPath, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer releaseOther()
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	diff.Compare(path, otherPath, storeOptions)
+	return nil
+}
+
+// Sync mirrors the source into the target directory based on both integrity files. Missing and modified files
+// are copied with a hash verification of the written bytes and the target integrity file is updated accordingly.
+// With the optional flags deletions and moves, removed and moved files of the source are propagated to the target.
+func Sync(sourcePath string, targetPath string, deletions bool, moves bool, options Options) error {
+	if err := dir.CheckIntegrityDir(sourcePath); err != nil {
+		return err
+	}
+	if err := dir.CheckDir(targetPath); err != nil {
+		return err
+	}
+	release, err := lockTransfer(sourcePath, targetPath, options)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer prune(targetPath, nil)
+	storeOptions, err := options.toStoreOptions(sourcePath)
+	if err != nil {
+		return err
+	}
+	if err := checkTargetAlgorithm(targetPath, storeOptions.Algorithm); err != nil {
+		return err
+	}
+	transfer.Sync(sourcePath, targetPath, deletions, moves, storeOptions)
+	return nil
+}
+
+// Copy copies all files of the source directory matching the relative path or glob into the target directory.
+// The written bytes are verified against the source integrity file and the matching entries are appended
+// to the target integrity file, so the target is verifiable without a second hash pass.
+func Copy(sourcePath string, pattern string, targetPath string, options Options) error {
+	if err := dir.CheckIntegrityDir(sourcePath); err != nil {
+		return err
+	}
+	if err := dir.CheckDir(targetPath); err != nil {
+		return err
+	}
+	release, err := lockTransfer(sourcePath, targetPath, options)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer prune(targetPath, nil)
+	storeOptions, err := options.toStoreOptions(sourcePath)
+	if err != nil {
+		return err
+	}
+	if err := checkTargetAlgorithm(targetPath, storeOptions.Algorithm); err != nil {
+		return err
+	}
+	transfer.Copy(sourcePath, pattern, targetPath, storeOptions)
+	return nil
+}
+
+// Repair verifies all files and replaces invalid files by a reconstruction based on the recovery data or by a
+// valid copy of a replica. Replicas are looked up by relative path first and by hash within the replica integrity
+// file afterwards. Invalid files are kept in quarantine.
+func Repair(path string, replicaPaths []string, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	for _, replicaPath := range replicaPaths {
+		if err := dir.CheckDir(replicaPath); err != nil {
+			return err
+		}
+	}
+	release, err := lock.Acquire(path, lock.Exclusive, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer prune(path, options.Config)
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	repair.Repair(path, replicaPaths, storeOptions)
+	return nil
+}
+
+// Fsck checks the consistency of the integrity file without reading any file content. Malformed records,
+// duplicate paths with an equal creation date, impossible values like negative sizes, bad hashes or future
+// timestamps and absolute or parent relative paths are reported. With the repair flag, the valid entries are
+// salvaged into a new integrity file, the current integrity file is backed up before.
+func Fsck(path string, repair bool, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	mode := lock.Shared
+	if repair {
+		mode = lock.Exclusive
+	}
+	release, err := lock.Acquire(path, mode, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if repair {
+		defer prune(path, options.Config)
+	}
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	return fsck.Check(path, repair, storeOptions)
+}
+
+// Backups lists the backups of the integrity file ordered by timestamp. Backups are created by upsert, repair,
+// sync and copy with the backup option and before a restore.
+func Backups(path string) ([]BackupInfo, error) {
+	return file.Backups(path)
+}
+
+// RestoreBackup replaces the integrity file by the backup of the timestamp, e.g. after a faulty execution.
+// The backup is validated before and the current integrity file is backed up, so a restore can be reverted.
+func RestoreBackup(path string, timestamp string) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Exclusive, 0)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return file.RestoreBackup(path, timestamp)
+}
+
+// DiffBackup compares the backup of the timestamp (A) with the current integrity file (B) like Diff
+func DiffBackup(path string, timestamp string, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Shared, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	return diff.CompareBackup(path, timestamp, storeOptions)
+}
+
+// GC removes the logs and backups within the integrity directory, which are expired according to the retention
+// of the config. Besides, expired files are removed automatically after each writing execution. With the dry run flag,
+// expired files are only reported.
+func GC(path string, dryRun bool, options Options) error {
+	if err := dir.CheckIntegrityDir(path); err != nil {
+		return err
+	}
+	release, err := lock.Acquire(path, lock.Exclusive, options.LockWait)
+	if err != nil {
+		return err
+	}
+	defer release()
+	c := options.Config
+	if c == nil {
+		if c, err = config.Load(path); err != nil {
+			return err
+		}
+		options.Config = c
+	}
+	storeOptions, err := options.toStoreOptions(path)
+	if err != nil {
+		return err
+	}
+	retention.GC(path, policies(c), dryRun, storeOptions)
+	return nil
 }
 
 // DefaultOptions for execution
@@ -88,15 +417,205 @@ type Options struct {
 	LogFile     bool
 	Backup      bool
 	ProgressBar bool
+	Redundancy  int           // Percentage of recovery data, zero disables the creation
+	ChunkSize   int64         // Chunk size in bytes for chunk hashes of larger files, zero disables the creation
+	AppendOnly  bool          // Grown files with chunk hashes are assumed to be appended, only the tail is hashed
+	IOStrategy  string        // I/O strategy auto, sequential or parallel, empty defaults to auto detection
+	Symlinks    string        // Symlink policy ignore, record or follow, empty defaults to record
+	Metadata    bool          // Captures mode bits and ownership of files
+	Xattrs      []string      // Extended attributes captured with the metadata, e.g. system.posix_acl_access
+	ReadRate    int64         // Read throughput limit in bytes per second, zero disables the limit
+	BufferSize  int64         // Read buffer size in bytes per file reader, zero defaults to 30MiB
+	Workers     int           // Maximum number of concurrent file readers, zero uses the I/O strategy default
+	Nice        int           // Process niceness between 1 and 19, zero keeps the current priority
+	IdleIO      bool          // Idle I/O scheduling class for background runs, only supported on linux
+	Debounce    time.Duration // Quiet period of watch before changed files are hashed, zero defaults to 2s
+	Interval    time.Duration // Defragmentation and polling interval of watch, zero defaults to 1m
+	LockWait    time.Duration // Duration to wait for a lock of another process, zero fails immediately
+	Observer    Observer      // Optional receiver of the console entries and the progress, e.g. for a service
+	Config      *Config       // Settings of the archive, nil loads .integrity/config. Non-zero options take precedence.
+}
+
+// Config contains the settings of an archive stored within .integrity/config
+type Config = config.Config
+
+// LoadConfig reads the config of the directory, the default config is returned without config file
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path)
+}
+
+// BackupInfo describes a backup of the integrity file
+type BackupInfo = file.BackupInfo
+
+// Observer receives the entries visible on the console, e.g. ilog.VerifyLog, the concluding summary and the
+// progress in processed bytes
+type Observer = ilog.Observer
+
+// Validate checks the option values independent of a directory, e.g. before a service schedules executions.
+// Invalid values are reported by each execution otherwise.
+func (o Options) Validate() error {
+	if _, err := device.Parse(o.IOStrategy); err != nil {
+		return err
+	}
+	if _, err := path.ParseSymlinks(o.Symlinks); err != nil {
+		return err
+	}
+	return nil
 }
 
-func (o Options) toStoreOptions() store.Options {
+func (o Options) toStoreOptions(basePath string) (store.Options, error) {
+	c := o.Config
+	if c == nil {
+		var err error
+		if c, err = config.Load(basePath); err != nil {
+			return store.Options{}, err
+		}
+	}
+	strategy, err := device.Parse(or(o.IOStrategy, c.IO.Strategy))
+	if err != nil {
+		return store.Options{}, err
+	}
+	symlinks, err := path.ParseSymlinks(or(o.Symlinks, c.Upsert.Symlinks))
+	if err != nil {
+		return store.Options{}, err
+	}
+	algorithm, err := digest.Parse(c.Hash)
+	if err != nil {
+		return store.Options{}, err
+	}
+	ignore, err := path.ParseIgnore(c.Ignore)
+	if err != nil {
+		return store.Options{}, err
+	}
+	xattrs := o.Xattrs
+	if len(xattrs) == 0 {
+		xattrs = c.Upsert.Xattrs
+	}
 	return store.Options{
 		Log: ilog.Options{
-			Console: o.LogConsole,
-			File:    o.LogFile,
+			Console:   o.LogConsole,
+			File:      o.LogFile,
+			Observer:  o.Observer,
+			FlushSize: c.Log.FlushSize,
 		},
-		Backup:      o.Backup,
-		ProgressBar: o.ProgressBar,
+		Backup:              o.Backup,
+		LockWait:            o.LockWait,
+		ProgressBar:         o.ProgressBar,
+		Redundancy:          or(o.Redundancy, c.Upsert.Redundancy),
+		ChunkSize:           or(o.ChunkSize, int64(c.Upsert.ChunkSize)),
+		AppendOnly:          o.AppendOnly || c.Upsert.AppendOnly,
+		IOStrategy:          strategy,
+		Symlinks:            symlinks,
+		Ignore:              ignore,
+		Algorithm:           algorithm,
+		Metadata:            o.Metadata || c.Upsert.Metadata || len(xattrs) > 0,
+		Xattrs:              xattrs,
+		MaxPathLength:       c.Style.MaxPathLength,
+		MaxDirLength:        c.Style.MaxDirLength,
+		DuplicateIgnoreSize: int64(c.Duplicates.IgnoreSize),
+		Throttle: throttle.New(throttle.Limits{
+			ReadRate: or(o.ReadRate, int64(c.IO.Rate)),
+			Workers:  or(o.Workers, c.IO.Workers),
+			Nice:     o.Nice,
+			IdleIO:   o.IdleIO,
+		}),
+		Watch: watch.Options{
+			Debounce: o.Debounce,
+			Interval: o.Interval,
+		},
+		BufferSize: int(or(o.BufferSize, int64(c.IO.BufferSize))),
+	}, nil
+}
+
+// Locks the source shared and the target exclusively. The same directory as source and target is rejected,
+// since the exclusive lock conflicts with the shared lock of the own process.
+func lockTransfer(sourcePath string, targetPath string, options Options) (release func(), err error) {
+	if samePath(sourcePath, targetPath) {
+		return nil, errors.New("source and target are the same directory: " + sourcePath)
+	}
+	releaseSource, err := lock.Acquire(sourcePath, lock.Shared, options.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	releaseTarget, err := lock.Acquire(targetPath, lock.Exclusive, options.LockWait)
+	if err != nil {
+		releaseSource()
+		return nil, err
+	}
+	return func() {
+		releaseTarget()
+		releaseSource()
+	}, nil
+}
+
+// Entries of the source are appended to the target without hashing, hence the target must use the algorithm of
+// the source
+func checkTargetAlgorithm(targetPath string, algorithm digest.Algorithm) error {
+	c, err := config.Load(targetPath)
+	if err != nil {
+		return err
+	}
+	configured, err := digest.Parse(c.Hash)
+	if err != nil {
+		return err
+	}
+	if configured != algorithm {
+		return errors.New("target is configured with another hash algorithm than " + string(algorithm) + " of the source: " + targetPath)
+	}
+	if err := store.AssertAlgorithm(targetPath, algorithm); err != nil {
+		return errors.New("target " + err.Error() + ": " + targetPath)
+	}
+	return nil
+}
+
+// Returns true, if both paths refer to the same directory, e.g. via a relative path or a symbolic link
+func samePath(a string, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(infoA, infoB)
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// Prunes the logs and backups of the directory after a writing execution, the config is loaded if nil. Pruning
+// requires the exclusive lock, since concurrent readers may still list logs and backups.
+// Failures are reported, but do not fail the execution.
+func prune(basePath string, c *Config) {
+	if c == nil {
+		var err error
+		if c, err = config.Load(basePath); err != nil {
+			return
+		}
+	}
+	if err := retention.Prune(basePath, policies(c)); err != nil {
+		log.Println("could not prune logs and backups", err)
+	}
+}
+
+func policies(c *Config) retention.Policies {
+	policy := func(p config.Policy) retention.Policy {
+		return retention.Policy{
+			KeepLast:    p.KeepLast,
+			KeepDaily:   p.KeepDaily,
+			KeepWeekly:  p.KeepWeekly,
+			KeepMonthly: p.KeepMonthly,
+			MaxSize:     int64(p.MaxSize),
+		}
+	}
+	return retention.Policies{
+		Logs:    policy(c.Retention.Logs),
+		Backups: policy(c.Retention.Backups),
+	}
+}
+
+// Returns the option, unless it is zero
+func or[T comparable](option T, fallback T) T {
+	var zero T
+	if option == zero {
+		return fallback
 	}
+	return option
 }
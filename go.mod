@@ -3,8 +3,6 @@ module github.com/aicirt2012/fileintegrity
 go 1.21
 
 require (
-	github.com/gocarina/gocsv v0.0.0-20231116093920-b87c2d0e983a
-	github.com/mitchellh/hashstructure/v2 v2.0.2
 	github.com/schollz/progressbar/v3 v3.14.1
 	golang.org/x/exp v0.0.0-20231214170342-aacd6d4b4611
 )
@@ -16,8 +14,8 @@ require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/cobra v1.8.0
-	github.com/spf13/pflag v1.0.5 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,175 @@
+package chunk
+
+import (
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+const dirName = "chunks"
+const ext = ".json"
+
+// Filename of the chunk hashes, which are shared by all files with an equal hash
+func Filename(basePath string, hash string) string {
+	return filepath.Join(basePath, dir.Name, dirName, hash+ext)
+}
+
+func Exists(basePath string, hash string) bool {
+	_, err := os.Stat(Filename(basePath, hash))
+	return err == nil
+}
+
+func Load(basePath string, hash string) (Chunks, error) {
+	content, err := os.ReadFile(Filename(basePath, hash))
+	if err != nil {
+		return Chunks{}, err
+	}
+	chunks := Chunks{}
+	if err := json.Unmarshal(content, &chunks); err != nil {
+		return Chunks{}, errors.New("could not parse chunk file")
+	}
+	if chunks.ChunkSize <= 0 {
+		return Chunks{}, errors.New("invalid chunk size")
+	}
+	return chunks, nil
+}
+
+// Save writes the chunk hashes into a synced temporary file, which is renamed afterwards
+func Save(basePath string, hash string, chunks Chunks) error {
+	path := filepath.Join(basePath, dir.Name, dirName)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return errors.New("could not create chunk dir")
+	}
+	content, err := json.Marshal(chunks)
+	if err != nil {
+		return err
+	}
+	if err := dir.WriteFile(Filename(basePath, hash), content); err != nil {
+		return errors.New("could not write chunk file")
+	}
+	return nil
+}
+
+// Hashes returns all hashes with existing chunk hashes
+func Hashes(basePath string) map[string]bool {
+	hashes := map[string]bool{}
+	entries, _ := os.ReadDir(filepath.Join(basePath, dir.Name, dirName))
+	for _, entry := range entries {
+		if hash, found := strings.CutSuffix(entry.Name(), ext); found {
+			hashes[hash] = true
+		}
+	}
+	return hashes
+}
+
+// Prune removes chunk hashes of hashes that are not referenced anymore
+func Prune(basePath string, hashes map[string]bool) error {
+	path := filepath.Join(basePath, dir.Name, dirName)
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		hash, isChunk := strings.CutSuffix(entry.Name(), ext)
+		if isChunk && hashes[hash] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hasher computes the file hash and the hashes of all chunks within one pass
+type Hasher struct {
+	chunkSize int64
+	file      hash.Hash
+	chunk     hash.Hash
+	filled    int64
+	size      int64
+	hashes    []string
+	state     []byte
+}
+
+func NewHasher(chunkSize int64, algorithm digest.Algorithm) *Hasher {
+	return &Hasher{
+		chunkSize: chunkSize,
+		file:      algorithm.New(),
+		chunk:     algorithm.New(),
+		state:     []byte{},
+	}
+}
+
+// Resume restores the file hash state at the end of the last full chunk. The hashing continues at this offset.
+func Resume(chunks Chunks, algorithm digest.Algorithm) (*Hasher, error) {
+	h := NewHasher(chunks.ChunkSize, algorithm)
+	full := chunks.FullChunks()
+	if full == 0 || int64(len(chunks.Hashes)) < full {
+		return nil, errors.New("no full chunk available")
+	}
+	if err := h.file.(encoding.BinaryUnmarshaler).UnmarshalBinary(chunks.State); err != nil {
+		return nil, errors.New("could not restore hash state")
+	}
+	h.size = full * chunks.ChunkSize
+	h.hashes = append(h.hashes, chunks.Hashes[:full]...)
+	h.state = chunks.State
+	return h, nil
+}
+
+// Offset returns the number of bytes, which are already hashed
+func (h *Hasher) Offset() int64 {
+	return h.size
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		c := min(int64(len(p)), h.chunkSize-h.filled)
+		h.file.Write(p[:c])
+		h.chunk.Write(p[:c])
+		h.filled += c
+		h.size += c
+		p = p[c:]
+		if h.filled == h.chunkSize {
+			h.hashes = append(h.hashes, hex.EncodeToString(h.chunk.Sum(nil)))
+			h.chunk.Reset()
+			h.filled = 0
+			state, err := h.file.(encoding.BinaryMarshaler).MarshalBinary()
+			if err != nil {
+				return n - len(p), err
+			}
+			h.state = state
+		}
+	}
+	return n, nil
+}
+
+// Sum returns the hash of the whole file
+func (h *Hasher) Sum() string {
+	return hex.EncodeToString(h.file.Sum(nil))
+}
+
+// Chunks returns the hashes of all chunks including the last partial chunk
+func (h *Hasher) Chunks() Chunks {
+	hashes := append([]string{}, h.hashes...)
+	if h.filled > 0 {
+		hashes = append(hashes, hex.EncodeToString(h.chunk.Sum(nil)))
+	}
+	return Chunks{
+		Size:      h.size,
+		ChunkSize: h.chunkSize,
+		Hashes:    hashes,
+		State:     h.state,
+	}
+}
@@ -0,0 +1,62 @@
+package chunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasher(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	h := NewHasher(64, digest.SHA256)
+	h.Write(content[:100])
+	h.Write(content[100:])
+
+	chunks := h.Chunks()
+	assert.Equal(t, sha256Hex(content), h.Sum())
+	assert.Equal(t, int64(1000), chunks.Size)
+	assert.Len(t, chunks.Hashes, 16)
+	assert.Equal(t, sha256Hex(content[64:128]), chunks.Hashes[1])
+	assert.Equal(t, sha256Hex(content[960:]), chunks.Hashes[15])
+}
+
+func TestResume(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	h := NewHasher(64, digest.SHA256)
+	h.Write(content[:900])
+
+	resumed, err := Resume(h.Chunks(), digest.SHA256)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(896), resumed.Offset())
+	resumed.Write(content[896:])
+	assert.Equal(t, sha256Hex(content), resumed.Sum())
+	assert.Len(t, resumed.Chunks().Hashes, 16)
+}
+
+func TestResume_noFullChunk(t *testing.T) {
+	h := NewHasher(64, digest.SHA256)
+	h.Write([]byte("short"))
+	_, err := Resume(h.Chunks(), digest.SHA256)
+	assert.NotNil(t, err)
+}
+
+func TestCorrupt(t *testing.T) {
+	chunks := Chunks{
+		Size:      250,
+		ChunkSize: 64,
+		Hashes:    []string{"a", "b", "c", "d"},
+	}
+	assert.Equal(t, "", chunks.Corrupt([]string{"a", "b", "c", "d"}).String())
+	assert.Equal(t, "64-191", chunks.Corrupt([]string{"a", "x", "x", "d"}).String())
+	assert.Equal(t, "0-63, 192-249", chunks.Corrupt([]string{"x", "b", "c", "x"}).String())
+	assert.Equal(t, "128-249", chunks.Corrupt([]string{"a", "b"}).String())
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package chunk
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Chunks contains the hashes of fixed size chunks of a file. The state of the file hash at the end of the
+// last full chunk allows to continue the hashing of an appended file.
+type Chunks struct {
+	Size      int64    `json:"size"`
+	ChunkSize int64    `json:"chunkSize"`
+	Hashes    []string `json:"hashes"`
+	State     []byte   `json:"state"`
+}
+
+// Range of bytes, start and end are inclusive
+type Range struct {
+	Start int64
+	End   int64
+}
+
+type Ranges []Range
+
+func (r Range) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+func (r Ranges) String() string {
+	values := []string{}
+	for _, value := range r {
+		values = append(values, value.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+// FullChunks returns the number of chunks, which are completely filled
+func (c Chunks) FullChunks() int64 {
+	return c.Size / c.ChunkSize
+}
+
+// Corrupt returns the merged byte ranges of all chunks with a different hash
+func (c Chunks) Corrupt(actual []string) Ranges {
+	ranges := Ranges{}
+	for i := range c.Hashes {
+		if i < len(actual) && actual[i] == c.Hashes[i] {
+			continue
+		}
+		start := int64(i) * c.ChunkSize
+		end := min(start+c.ChunkSize, c.Size) - 1
+		if len(ranges) > 0 && ranges[len(ranges)-1].End+1 == start {
+			ranges[len(ranges)-1].End = end
+		} else {
+			ranges = append(ranges, Range{Start: start, End: end})
+		}
+	}
+	return ranges
+}
@@ -0,0 +1,53 @@
+package device
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+)
+
+type Strategy string
+
+const (
+	AUTO       Strategy = "auto"
+	SEQUENTIAL Strategy = "sequential"
+	PARALLEL   Strategy = "parallel"
+)
+
+// Parse validates the strategy name, an empty name defaults to auto detection
+func Parse(name string) (Strategy, error) {
+	switch Strategy(strings.ToLower(name)) {
+	case "", AUTO:
+		return AUTO, nil
+	case SEQUENTIAL:
+		return SEQUENTIAL, nil
+	case PARALLEL:
+		return PARALLEL, nil
+	}
+	return "", errors.New("unknown io strategy: " + name)
+}
+
+// Resolve detects the strategy based on the device type of the path, if the strategy is set to auto.
+// Rotational devices are read sequentially to avoid seeks, all other devices are read in parallel.
+func Resolve(path string, strategy Strategy) Strategy {
+	if strategy != AUTO && strategy != "" {
+		return strategy
+	}
+	if rotational, err := isRotational(path); err == nil && rotational {
+		return SEQUENTIAL
+	}
+	return PARALLEL
+}
+
+// Workers returns the number of concurrent file readers
+func (s Strategy) Workers() int {
+	if s == SEQUENTIAL {
+		return 1
+	}
+	return runtime.NumCPU()
+}
+
+// Sorted reports whether files should be read ordered by path to follow the physical layout
+func (s Strategy) Sorted() bool {
+	return s == SEQUENTIAL
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package device
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Looks up the rotational flag of the block device containing the path. For partitions, the flag is
+// provided by the parent device.
+func isRotational(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, errors.New("device unknown")
+	}
+	dev := uint64(stat.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	sysPath, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", major, minor))
+	if err != nil {
+		return false, err
+	}
+	for _, candidate := range []string{"queue/rotational", "../queue/rotational"} {
+		content, err := os.ReadFile(filepath.Join(sysPath, candidate))
+		if err == nil {
+			return strings.TrimSpace(string(content)) == "1", nil
+		}
+	}
+	return false, errors.New("rotational flag unavailable")
+}
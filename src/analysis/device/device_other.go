@@ -0,0 +1,9 @@
+//go:build !linux
+
+package device
+
+import "errors"
+
+func isRotational(path string) (bool, error) {
+	return false, errors.New("rotational flag unavailable")
+}
@@ -0,0 +1,34 @@
+package device
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for name, expected := range map[string]Strategy{
+		"":           AUTO,
+		"auto":       AUTO,
+		"Sequential": SEQUENTIAL,
+		"parallel":   PARALLEL,
+	} {
+		actual, err := Parse(name)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	_, err := Parse("random")
+	assert.NotNil(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	assert.Equal(t, SEQUENTIAL, Resolve(t.TempDir(), SEQUENTIAL))
+	assert.Equal(t, PARALLEL, Resolve(t.TempDir(), PARALLEL))
+	assert.Contains(t, []Strategy{SEQUENTIAL, PARALLEL}, Resolve(t.TempDir(), AUTO))
+}
+
+func TestWorkers(t *testing.T) {
+	assert.Equal(t, 1, SEQUENTIAL.Workers())
+	assert.Equal(t, runtime.NumCPU(), PARALLEL.Workers())
+}
@@ -0,0 +1,56 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strings"
+)
+
+// Algorithm of the file hashes. The zero value is SHA256, which is the algorithm of integrity files
+// created without config.
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha256"
+	SHA384 Algorithm = "sha384"
+	SHA512 Algorithm = "sha512"
+)
+
+// Parse validates the algorithm name, an empty name defaults to sha256
+func Parse(name string) (Algorithm, error) {
+	switch Algorithm(strings.ToLower(name)) {
+	case "", SHA256:
+		return SHA256, nil
+	case SHA384:
+		return SHA384, nil
+	case SHA512:
+		return SHA512, nil
+	}
+	return "", errors.New("unknown hash algorithm: " + name)
+}
+
+// New creates a hash, which supports encoding.BinaryMarshaler to resume the hashing
+func (a Algorithm) New() hash.Hash {
+	switch a {
+	case SHA384:
+		return sha512.New384()
+	case SHA512:
+		return sha512.New()
+	}
+	return sha256.New()
+}
+
+// Sum returns the hex encoded hash of the content
+func (a Algorithm) Sum(content []byte) string {
+	h := a.New()
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HexLen returns the length of the hex encoded hashes, which differs for all algorithms
+func (a Algorithm) HexLen() int {
+	return a.New().Size() * 2
+}
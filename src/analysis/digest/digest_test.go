@@ -0,0 +1,25 @@
+package digest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for name, expected := range map[string]Algorithm{"": SHA256, "sha256": SHA256, "SHA512": SHA512, "sha384": SHA384} {
+		algorithm, err := Parse(name)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, algorithm)
+	}
+	_, err := Parse("md5")
+	assert.NotNil(t, err)
+}
+
+func TestAlgorithm_Sum(t *testing.T) {
+	content := []byte("a1 sample txt")
+	assert.Equal(t, "85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301", Algorithm("").Sum(content))
+	assert.Equal(t, SHA256.Sum(content), Algorithm("").Sum(content))
+	assert.Len(t, SHA384.Sum(content), SHA384.HexLen())
+	assert.Len(t, SHA512.Sum(content), 128)
+}
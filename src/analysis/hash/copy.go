@@ -0,0 +1,199 @@
+package hash
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/analysis/parity"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+const tmpExt = ".fitmp"
+
+func CopyWorker(requests <-chan CopyRequest, responses chan<- CopyResponse) {
+	for request := range requests {
+		hash, err := CopyVerified(request)
+		responses <- CopyResponse{
+			RelativePath: request.RelativePath,
+			Hash:         hash,
+			Error:        err,
+		}
+	}
+}
+
+// CopyVerified copies the source into a temporary file while the written bytes are hashed. The target is only
+// replaced, when the hash of the written bytes matches the expected hash. An existing target is optionally
+// kept within the quarantine path before it is replaced atomically.
+func CopyVerified(request CopyRequest) (string, error) {
+	if request.Target != "" {
+		return restoreLink(request)
+	}
+	if request.Dir {
+		if err := os.MkdirAll(request.TargetPath, 0755); err != nil {
+			return "", errors.New("could not create directory")
+		}
+		return DirHash, nil
+	}
+	return restoreVerified(request, errors.New("copied file hash different"), func(tmpPath string) (string, error) {
+		return Copy(request.SourcePath, tmpPath, request.Algorithm, request.BufferSize)
+	})
+}
+
+// ReconstructVerified reconstructs the target based on the recovery data given as source. The target is only
+// replaced, when the hash of the reconstructed file matches the expected hash.
+func ReconstructVerified(request CopyRequest) (string, error) {
+	return restoreVerified(request, errors.New("reconstructed file hash different"), func(tmpPath string) (string, error) {
+		if err := parity.Reconstruct(request.TargetPath, request.SourcePath, tmpPath); err != nil {
+			return "", err
+		}
+		return Hash(tmpPath, request.Algorithm, request.BufferSize)
+	})
+}
+
+func restoreVerified(request CopyRequest, hashErr error, restore func(tmpPath string) (string, error)) (string, error) {
+	tmpPath, err := createTmpFile(request.TmpDir)
+	if err != nil {
+		return "", err
+	}
+	hash, err := restore(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if hash != request.Hash {
+		os.Remove(tmpPath)
+		return hash, hashErr
+	}
+	if err := os.Chtimes(tmpPath, request.ModTime, request.ModTime); err != nil {
+		os.Remove(tmpPath)
+		return hash, errors.New("could not set modification time")
+	}
+	if request.QuarantinePath != "" {
+		if err := quarantine(request.TargetPath, request.QuarantinePath, request.BufferSize); err != nil {
+			os.Remove(tmpPath)
+			return hash, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(request.TargetPath), 0755); err != nil {
+		os.Remove(tmpPath)
+		return hash, errors.New("could not create target dir: " + filepath.Dir(request.TargetPath))
+	}
+	if err := os.Rename(tmpPath, request.TargetPath); err != nil {
+		os.Remove(tmpPath)
+		return hash, errors.New("could not replace target file")
+	}
+	return hash, nil
+}
+
+// Creates the symbolic link with the expected target and replaces the existing target file atomically
+func restoreLink(request CopyRequest) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(request.TargetPath), 0755); err != nil {
+		return "", errors.New("could not create target dir: " + filepath.Dir(request.TargetPath))
+	}
+	tmpPath, err := createTmpFile(request.TmpDir)
+	if err != nil {
+		return "", err
+	}
+	os.Remove(tmpPath)
+	if err := os.Symlink(request.Target, tmpPath); err != nil {
+		return "", errors.New("could not create link")
+	}
+	if request.QuarantinePath != "" {
+		if err := quarantine(request.TargetPath, request.QuarantinePath, request.BufferSize); err != nil {
+			os.Remove(tmpPath)
+			return "", err
+		}
+	}
+	if err := os.Rename(tmpPath, request.TargetPath); err != nil {
+		os.Remove(tmpPath)
+		return "", errors.New("could not replace target file")
+	}
+	return Link(request.Target, request.Algorithm), nil
+}
+
+// Creates an empty temporary file within the temporary directory, which is removed with all leftovers of
+// interrupted executions by RemoveTemporaryFiles
+func createTmpFile(tmpDir string) (string, error) {
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", errors.New("could not create temporary dir: " + tmpDir)
+	}
+	f, err := os.CreateTemp(tmpDir, "*"+tmpExt)
+	if err != nil {
+		return "", errors.New("could not create temporary file")
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+// TmpDir returns the directory of the temporary files within the integrity directory. Temporary files are
+// renamed to their target, hence the directory resides on the volume of the base directory.
+func TmpDir(basePath string) string {
+	return filepath.Join(basePath, dir.Name, "tmp")
+}
+
+// RemoveTemporaryFiles removes the temporary files of interrupted copies and repairs. Only the temporary
+// directory is removed, files of the user are never touched.
+func RemoveTemporaryFiles(basePath string) {
+	if err := os.RemoveAll(TmpDir(basePath)); err != nil {
+		log.Printf("could not remove temporary files: %v", err)
+	}
+}
+
+// Copy streams the source file into the target file and returns the hash of the written bytes. The target file
+// gets the permissions of the source file.
+func Copy(source string, target string, algorithm digest.Algorithm, bufferSize int) (string, error) {
+	src, err := os.Open(source)
+	if err != nil {
+		return "", errors.New("could not open file for copying: " + source)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return "", errors.New("file stat unreadable")
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", errors.New("could not create target dir: " + filepath.Dir(target))
+	}
+	dst, err := os.Create(target)
+	if err != nil {
+		return "", errors.New("could not create target file: " + target)
+	}
+	defer dst.Close()
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		return "", errors.New("could not set file mode: " + target)
+	}
+	h := algorithm.New()
+	pooled := getBuffer(bufferSize)
+	defer buffers.Put(pooled)
+	if _, err := io.CopyBuffer(io.MultiWriter(dst, h), src, *pooled); err != nil {
+		return "", errors.New("error during copying")
+	}
+	if err := dst.Sync(); err != nil {
+		return "", errors.New("could not sync target file")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Keeps a copy of an existing file within the quarantine path. A hard link is preferred, so the
+// file remains in place until it is replaced.
+func quarantine(path string, quarantinePath string, bufferSize int) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(quarantinePath), 0755); err != nil {
+		return errors.New("could not create quarantine dir")
+	}
+	if err := os.Link(path, quarantinePath); err == nil {
+		return nil
+	}
+	// The hash of the quarantined copy is not used
+	if _, err := Copy(path, quarantinePath, digest.SHA256, bufferSize); err != nil {
+		return errors.New("could not quarantine file")
+	}
+	return nil
+}
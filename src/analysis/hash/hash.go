@@ -1,21 +1,31 @@
 package hash
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"sync"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/chunk"
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/analysis/meta"
+	"github.com/aicirt2012/fileintegrity/src/analysis/parity"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/analysis/throttle"
 )
 
 func CreationWorker(requests <-chan CreateRequest, responses chan<- CreateResponse) {
 	for request := range requests {
-		hash, err := Hash(filepath.Join(request.BasePath, request.RelativePath))
+		request.Throttle.Acquire()
+		hash, hashedBytes, err := create(request)
+		request.Throttle.Release()
 		responses <- CreateResponse{
 			RelativePath: request.RelativePath,
 			Hash:         hash,
+			HashedBytes:  hashedBytes,
 			Error:        err,
 		}
 	}
@@ -23,43 +33,209 @@ func CreationWorker(requests <-chan CreateRequest, responses chan<- CreateRespon
 
 func VerifyWorker(requests <-chan VerifyRequest, responses chan<- VerifyResponse) {
 	for request := range requests {
+		request.Throttle.Acquire()
+		err := verify(request)
+		request.Throttle.Release()
+		if err == nil {
+			err = verifyMetadata(request)
+		}
 		responses <- VerifyResponse{
 			RelativePath: request.RelativePath,
-			Error:        verify(request),
+			Error:        err,
 		}
 	}
 }
 
-func Hash(filename string) (string, error) {
+// DirHash is the hash of empty directories, which differs from the hash of empty files
+const DirHash = "333178788eae3e0b14c9b07bbbb6232bfa4689c7f134eeaa9daae60aba96de53"
+
+// Link computes the hash of a symbolic link based on its target
+func Link(target string, algorithm digest.Algorithm) string {
+	return algorithm.Sum([]byte(target))
+}
+
+func Hash(filename string, algorithm digest.Algorithm, bufferSize int) (string, error) {
+	return hashFile(filename, algorithm, bufferSize, nil)
+}
+
+func hashFile(filename string, algorithm digest.Algorithm, bufferSize int, t *throttle.Throttle) (string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return "", errors.New("Could not open file for hashing: " + filename)
 	}
 	defer file.Close()
-	buf := make([]byte, 30*1024*1024)
-	sha256 := sha256.New()
+	return hashReader(file, nil, algorithm, bufferSize, t)
+}
+
+// Hashes the content and writes it optionally to an additional writer within the same read pass
+func hashReader(file io.Reader, w io.Writer, algorithm digest.Algorithm, bufferSize int, t *throttle.Throttle) (string, error) {
+	h := algorithm.New()
+	if w != nil {
+		w = io.MultiWriter(h, w)
+	} else {
+		w = h
+	}
+	if err := read(file, w, bufferSize, t); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DefaultBufferSize is the read buffer size per file reader without configured buffer size
+const DefaultBufferSize = 30 * 1024 * 1024
+
+// Read buffers are reused across files, so the memory stays bounded by the number of workers
+var buffers sync.Pool
+
+// Returns a pooled buffer of the size, buffers of another size are dropped
+func getBuffer(size int) *[]byte {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	if pooled, ok := buffers.Get().(*[]byte); ok && len(*pooled) == size {
+		return pooled
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+// Reads the content in blocks, which are throttled according to the read rate
+func read(file io.Reader, w io.Writer, bufferSize int, t *throttle.Throttle) error {
+	pooled := getBuffer(bufferSize)
+	defer buffers.Put(pooled)
+	buf := *pooled
 	for {
-		n, err := file.Read(buf)
+		n, err := file.Read(buf[:t.ReadSize(len(buf))])
 		if n > 0 {
-			_, err := sha256.Write(buf[:n])
-			if err != nil {
-				log.Fatal(err)
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
 			}
+			t.Wait(n)
 		}
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
 			log.Printf("Read %d bytes: %v", n, err)
-			return "", errors.New("error during hashing")
+			return errors.New("error during hashing")
+		}
+	}
+	return nil
+}
+
+// Computes the hash and optionally the recovery data and the chunk hashes of a file within one read pass.
+// The number of read bytes is smaller than the file size, when only the tail of an appended file is hashed.
+func create(request CreateRequest) (string, int64, error) {
+	filename := path.Absolute(request.BasePath, request.RelativePath)
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", 0, errors.New("Could not open file for hashing: " + filename)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", 0, errors.New("file stat unreadable")
+	}
+	withParity := request.Redundancy > 0 && info.Size() > 0
+	withChunks := request.ChunkSize > 0 && info.Size() > request.ChunkSize
+	if !withParity && !withChunks {
+		hash, err := hashReader(file, nil, request.Algorithm, request.BufferSize, request.Throttle)
+		return hash, info.Size(), err
+	}
+
+	fileHash := request.Algorithm.New()
+	var hasher *chunk.Hasher
+	var w io.Writer = fileHash
+	skippedBytes := int64(0)
+	if withChunks {
+		// recovery data requires the whole content, hence appended files are only resumed without parity
+		hasher = chunk.NewHasher(request.ChunkSize, request.Algorithm)
+		if request.AppendOnly && !withParity {
+			if resumed := resume(file, request, info.Size()); resumed != nil {
+				hasher = resumed
+			}
+		}
+		skippedBytes = hasher.Offset()
+		w = hasher
+	}
+	var encoder *parity.Encoder
+	var tmp *os.File
+	if withParity {
+		tmp, err = parity.Create(request.BasePath)
+		if err != nil {
+			return "", 0, err
 		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		encoder, err = parity.NewEncoder(tmp, info.Size(), request.Redundancy)
+		if err != nil {
+			return "", 0, errors.New("could not write parity file")
+		}
+		w = io.MultiWriter(w, encoder)
+	}
+	if err := read(file, w, request.BufferSize, request.Throttle); err != nil {
+		return "", 0, err
+	}
+	hash := hex.EncodeToString(fileHash.Sum(nil))
+	if withChunks {
+		hash = hasher.Sum()
+	}
+
+	if withParity {
+		if err := encoder.Close(); err != nil {
+			return "", 0, err
+		}
+		if err := parity.Commit(request.BasePath, tmp, hash); err != nil {
+			return "", 0, errors.New("could not commit parity file")
+		}
+	}
+	if withChunks {
+		if err := chunk.Save(request.BasePath, hash, hasher.Chunks()); err != nil {
+			return "", 0, err
+		}
+	}
+	return hash, info.Size() - skippedBytes, nil
+}
+
+// Resumes the chunk hashing of an appended file at the end of the last full chunk of the previous entry.
+// The previous content is assumed to be unchanged as promised by the append-only option, only the last full chunk
+// is hashed again as a plausibility check. Changes before are not detected. Returns nil, when the file can not
+// be resumed.
+func resume(file *os.File, request CreateRequest, size int64) *chunk.Hasher {
+	if request.PreviousHash == "" || size <= request.PreviousSize {
+		return nil
 	}
-	return hex.EncodeToString(sha256.Sum(nil)), nil
+	chunks, err := chunk.Load(request.BasePath, request.PreviousHash)
+	if err != nil || chunks.ChunkSize != request.ChunkSize || chunks.Size != request.PreviousSize {
+		return nil
+	}
+	hasher, err := chunk.Resume(chunks, request.Algorithm)
+	if err != nil {
+		return nil
+	}
+	last := chunks.FullChunks() - 1
+	chunkHash := request.Algorithm.New()
+	if _, err := io.Copy(chunkHash, io.NewSectionReader(file, last*chunks.ChunkSize, chunks.ChunkSize)); err != nil {
+		return nil
+	}
+	if hex.EncodeToString(chunkHash.Sum(nil)) != chunks.Hashes[last] {
+		return nil
+	}
+	if _, err := file.Seek(hasher.Offset(), io.SeekStart); err != nil {
+		return nil
+	}
+	return hasher
 }
 
 func verify(request VerifyRequest) error {
-	path := filepath.Join(request.BasePath, request.RelativePath)
-	info, err := os.Stat(path)
+	filename := path.Absolute(request.BasePath, request.RelativePath)
+	if request.Target != "" {
+		return verifyLink(filename, request.Target)
+	}
+	if request.Dir {
+		return verifyDir(filename)
+	}
+	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
 		return errors.New("file does not exist")
 	} else if err != nil {
@@ -68,7 +244,10 @@ func verify(request VerifyRequest) error {
 	if info.Size() != request.Size {
 		return errors.New("file size different")
 	}
-	hash, err := Hash(path)
+	if chunk.Exists(request.BasePath, request.Hash) {
+		return verifyChunks(filename, request)
+	}
+	hash, err := hashFile(filename, request.Algorithm, request.BufferSize, request.Throttle)
 	if err != nil {
 		return err
 	}
@@ -77,3 +256,93 @@ func verify(request VerifyRequest) error {
 	}
 	return nil
 }
+
+// DriftError reports changed or unreadable metadata of a file with valid content
+type DriftError struct {
+	Diff string // Empty, if the metadata is unreadable
+}
+
+func (e DriftError) Error() string {
+	if e.Diff == "" {
+		return "metadata unreadable"
+	}
+	return "metadata different: " + e.Diff
+}
+
+// IsDrift is true, if only the metadata of the file changed or is unreadable
+func IsDrift(err error) bool {
+	var drift DriftError
+	return errors.As(err, &drift)
+}
+
+// Verifies the metadata, if it was captured during the upsert
+func verifyMetadata(request VerifyRequest) error {
+	if request.Metadata.IsEmpty() {
+		return nil
+	}
+	filename := path.Absolute(request.BasePath, request.RelativePath)
+	actual, err := meta.Read(filename, request.Metadata.XattrNames())
+	if err != nil {
+		return DriftError{}
+	}
+	if diff := request.Metadata.Diff(actual); diff != "" {
+		return DriftError{Diff: diff}
+	}
+	return nil
+}
+
+func verifyDir(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return errors.New("directory does not exist")
+	} else if err != nil {
+		return errors.New("directory stat unreadable")
+	}
+	if !info.IsDir() {
+		return errors.New("file is no directory")
+	}
+	return nil
+}
+
+// Verifies that the link still exists with the same target and the target is not dangling
+func verifyLink(path string, expectedTarget string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return errors.New("link does not exist")
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return errors.New("file is no link")
+	}
+	if target != expectedTarget {
+		return errors.New("link target different: " + target)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return errors.New("link target does not exist")
+	}
+	return nil
+}
+
+// Verifies the file hash and reports the byte ranges of all corrupted chunks
+func verifyChunks(path string, request VerifyRequest) error {
+	chunks, err := chunk.Load(request.BasePath, request.Hash)
+	if err != nil {
+		return errors.New("chunk file unreadable")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.New("Could not open file for hashing: " + path)
+	}
+	defer file.Close()
+	hasher := chunk.NewHasher(chunks.ChunkSize, request.Algorithm)
+	if err := read(file, hasher, request.BufferSize, request.Throttle); err != nil {
+		return err
+	}
+	if hasher.Sum() == request.Hash {
+		return nil
+	}
+	corrupt := chunks.Corrupt(hasher.Chunks().Hashes)
+	if len(corrupt) == 0 {
+		return errors.New("file hash different")
+	}
+	return fmt.Errorf("file hash different at bytes %v", corrupt)
+}
@@ -1,6 +1,12 @@
 package hash
 
-import "time"
+import (
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/analysis/meta"
+	"github.com/aicirt2012/fileintegrity/src/analysis/throttle"
+)
 
 type VerifyRequest struct {
 	BasePath     string
@@ -8,6 +14,12 @@ type VerifyRequest struct {
 	Size         int64
 	ModTime      time.Time
 	Hash         string
+	Target       string // Expected target of a symbolic link, empty for files
+	Dir          bool   // Expected empty directory
+	Metadata     meta.Metadata
+	Algorithm    digest.Algorithm
+	BufferSize   int                // Read buffer size, zero defaults to DefaultBufferSize
+	Throttle     *throttle.Throttle // Limits of the execution, nil does not limit
 }
 
 type VerifyResponse struct {
@@ -18,9 +30,38 @@ type VerifyResponse struct {
 type CreateRequest struct {
 	BasePath     string
 	RelativePath string
+	Redundancy   int
+	ChunkSize    int64
+	AppendOnly   bool // Grown files are assumed to be appended, the chunk hashing is resumed
+	PreviousHash string
+	PreviousSize int64
+	Algorithm    digest.Algorithm
+	BufferSize   int                // Read buffer size, zero defaults to DefaultBufferSize
+	Throttle     *throttle.Throttle // Limits of the execution, nil does not limit
 }
 
 type CreateResponse struct {
+	RelativePath string
+	Hash         string
+	HashedBytes  int64
+	Error        error
+}
+
+type CopyRequest struct {
+	SourcePath     string
+	TargetPath     string
+	TmpDir         string // Directory of the temporary file, which is renamed to the target
+	QuarantinePath string
+	RelativePath   string
+	ModTime        time.Time
+	Hash           string
+	Target         string // Target of a symbolic link, which is created instead of a copy
+	Dir            bool   // Empty directory, which is created instead of a copy
+	Algorithm      digest.Algorithm
+	BufferSize     int // Read buffer size, zero defaults to DefaultBufferSize
+}
+
+type CopyResponse struct {
 	RelativePath string
 	Hash         string
 	Error        error
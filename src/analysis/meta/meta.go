@@ -0,0 +1,123 @@
+package meta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Metadata of a file besides its content. Empty fields are not captured.
+type Metadata struct {
+	Mode   string // Permission bits in octal notation including setuid, setgid and sticky bit, e.g. 0644
+	UID    string
+	GID    string
+	Xattrs string // Digests of the selected extended attributes as name:digest pairs separated by |
+}
+
+// FromInfo captures the mode bits and the ownership of the file
+func FromInfo(info os.FileInfo) Metadata {
+	uid, gid := owner(info)
+	return Metadata{
+		Mode: mode(info.Mode()),
+		UID:  uid,
+		GID:  gid,
+	}
+}
+
+// Read captures the metadata of the file including the selected extended attributes
+func Read(path string, xattrs []string) (Metadata, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	m := FromInfo(info)
+	m.Xattrs, err = ReadXattrs(path, xattrs)
+	return m, err
+}
+
+// ReadXattrs reads the selected extended attributes, e.g. system.posix_acl_access for ACLs. Missing attributes
+// are captured with an empty digest, so that added attributes are detected as well.
+func ReadXattrs(path string, names []string) (string, error) {
+	pairs := []string{}
+	for _, name := range names {
+		value, err := getxattr(path, name)
+		if err != nil {
+			return "", fmt.Errorf("could not read extended attribute %v: %v", name, err)
+		}
+		pairs = append(pairs, name+":"+digest(value))
+	}
+	return strings.Join(pairs, "|"), nil
+}
+
+func (m Metadata) IsEmpty() bool {
+	return m == Metadata{}
+}
+
+// XattrNames returns the names of the captured extended attributes
+func (m Metadata) XattrNames() []string {
+	names := []string{}
+	if m.Xattrs == "" {
+		return names
+	}
+	for _, pair := range strings.Split(m.Xattrs, "|") {
+		i := strings.LastIndex(pair, ":")
+		names = append(names, pair[:max(i, 0)])
+	}
+	return names
+}
+
+// Diff describes the differences to the actual metadata, which is empty if both are equal
+func (m Metadata) Diff(actual Metadata) string {
+	diffs := []string{}
+	if m.Mode != actual.Mode {
+		diffs = append(diffs, fmt.Sprintf("mode %v -> %v", m.Mode, actual.Mode))
+	}
+	if m.UID != actual.UID {
+		diffs = append(diffs, fmt.Sprintf("uid %v -> %v", m.UID, actual.UID))
+	}
+	if m.GID != actual.GID {
+		diffs = append(diffs, fmt.Sprintf("gid %v -> %v", m.GID, actual.GID))
+	}
+	if m.Xattrs != actual.Xattrs {
+		diffs = append(diffs, "xattrs "+strings.Join(changedXattrs(m.Xattrs, actual.Xattrs), ", "))
+	}
+	return strings.Join(diffs, ", ")
+}
+
+func changedXattrs(expected string, actual string) []string {
+	changed := []string{}
+	actualPairs := strings.Split(actual, "|")
+	for _, pair := range strings.Split(expected, "|") {
+		if !slices.Contains(actualPairs, pair) {
+			changed = append(changed, pair[:max(strings.LastIndex(pair, ":"), 0)])
+		}
+	}
+	return changed
+}
+
+func mode(m os.FileMode) string {
+	bits := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if m&os.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if m&os.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// Short digest of an attribute value, absent attributes have an empty digest
+func digest(value []byte) string {
+	if value == nil {
+		return ""
+	}
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,26 @@
+package meta
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMode(t *testing.T) {
+	assert.Equal(t, "0644", mode(0644))
+	assert.Equal(t, "4755", mode(0755|os.ModeSetuid))
+	assert.Equal(t, "3775", mode(0775|os.ModeSetgid|os.ModeSticky))
+}
+
+func TestDiff(t *testing.T) {
+	expected := Metadata{Mode: "0644", UID: "1000", GID: "1000", Xattrs: "user.a:1a|user.b:"}
+	assert.Equal(t, "", expected.Diff(expected))
+	actual := Metadata{Mode: "0600", UID: "0", GID: "1000", Xattrs: "user.a:1a|user.b:2b"}
+	assert.Equal(t, "mode 0644 -> 0600, uid 1000 -> 0, xattrs user.b", expected.Diff(actual))
+}
+
+func TestXattrNames(t *testing.T) {
+	assert.Equal(t, []string{}, Metadata{}.XattrNames())
+	assert.Equal(t, []string{"user.a", "user.b"}, Metadata{Xattrs: "user.a:1a|user.b:"}.XattrNames())
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package meta
+
+import "os"
+
+// Ownership is not supported
+func owner(info os.FileInfo) (string, string) {
+	return "", ""
+}
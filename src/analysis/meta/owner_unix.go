@@ -0,0 +1,17 @@
+//go:build unix
+
+package meta
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+func owner(info os.FileInfo) (string, string) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10)
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package meta
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Reads the attribute value, which is nil if the attribute does not exist
+func getxattr(path string, name string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if errors.Is(err, syscall.ENODATA) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, value)
+		if errors.Is(err, syscall.ERANGE) {
+			// The value grew in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return value[:n], nil
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadXattrs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(filename, []byte("a"), 0644)
+	if err := syscall.Setxattr(filename, "user.a", []byte("value"), 0); err != nil {
+		t.Skip("extended attributes are not supported by the file system")
+	}
+	xattrs, err := ReadXattrs(filename, []string{"user.a", "user.b"})
+	assert.Nil(t, err)
+	assert.Equal(t, "user.a:"+digest([]byte("value"))+"|user.b:", xattrs)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package meta
+
+// Extended attributes are only supported on linux, hence they are treated as absent
+func getxattr(path string, name string) ([]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+package parity
+
+import "errors"
+
+// Arithmetic within the galois field GF(2^8) based on the primitive polynomial x^8+x^4+x^3+x^2+1
+const polynomial = 0x11d
+
+var expTable [510]byte
+var logTable [256]byte
+var mulTable [256][256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		expTable[i+255] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= polynomial
+		}
+	}
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			mulTable[a][b] = mul(byte(a), byte(b))
+		}
+	}
+}
+
+func mul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func inv(a byte) byte {
+	return expTable[255-int(logTable[a])]
+}
+
+// Systematic encoding matrix, the identity for the data shards followed by a cauchy matrix for the
+// parity shards. Therefore, every square sub matrix built from distinct rows is invertible.
+func encodingMatrix(dataShards int, parityShards int) [][]byte {
+	m := make([][]byte, dataShards+parityShards)
+	for i := range m {
+		m[i] = make([]byte, dataShards)
+		if i < dataShards {
+			m[i][i] = 1
+			continue
+		}
+		for j := range m[i] {
+			m[i][j] = inv(byte(i) ^ byte(j))
+		}
+	}
+	return m
+}
+
+// Inverts a square matrix with the gauss-jordan elimination
+func invert(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	work := make([][]byte, n)
+	for i := range m {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if work[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot == -1 {
+			return nil, errors.New("matrix is singular")
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+		scale := inv(work[col][col])
+		for j := range work[col] {
+			work[col][j] = mul(work[col][j], scale)
+		}
+		for row := 0; row < n; row++ {
+			factor := work[row][col]
+			if row == col || factor == 0 {
+				continue
+			}
+			for j := range work[row] {
+				work[row][j] ^= mul(factor, work[col][j])
+			}
+		}
+	}
+	result := make([][]byte, n)
+	for i := range work {
+		result[i] = work[i][n:]
+	}
+	return result, nil
+}
+
+// Adds the product of the coefficient and the input to the output
+func mulAdd(coefficient byte, input []byte, output []byte) {
+	table := &mulTable[coefficient]
+	for i, b := range input {
+		output[i] ^= table[b]
+	}
+}
@@ -0,0 +1,257 @@
+package parity
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+const dirName = "parity"
+const ext = ".par"
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Filename of the recovery data, which is shared by all files with an equal hash
+func Filename(basePath string, hash string) string {
+	return filepath.Join(basePath, dir.Name, dirName, hash+ext)
+}
+
+func Exists(basePath string, hash string) bool {
+	_, err := os.Stat(Filename(basePath, hash))
+	return err == nil
+}
+
+// Create creates a temporary file for recovery data, that is committed after the hash is known
+func Create(basePath string) (*os.File, error) {
+	path := filepath.Join(basePath, dir.Name, dirName)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, errors.New("could not create parity dir")
+	}
+	return os.CreateTemp(path, "*"+ext+".tmp")
+}
+
+// Commit syncs the written temporary file and renames it atomically according to the hash
+func Commit(basePath string, tmp *os.File, hash string) error {
+	return dir.Commit(tmp, Filename(basePath, hash))
+}
+
+// Hashes returns all hashes with existing recovery data
+func Hashes(basePath string) map[string]bool {
+	hashes := map[string]bool{}
+	entries, _ := os.ReadDir(filepath.Join(basePath, dir.Name, dirName))
+	for _, entry := range entries {
+		if hash, found := strings.CutSuffix(entry.Name(), ext); found {
+			hashes[hash] = true
+		}
+	}
+	return hashes
+}
+
+// Prune removes recovery data of hashes that are not referenced anymore
+func Prune(basePath string, hashes map[string]bool) error {
+	path := filepath.Join(basePath, dir.Name, dirName)
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		hash, isParity := strings.CutSuffix(entry.Name(), ext)
+		if isParity && hashes[hash] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encoder computes reed-solomon recovery data for a stream of the given size. The stream is split into
+// stripes of data blocks. For each stripe, parity blocks are computed according to the redundancy
+// percentage. Checksums of all blocks allow to identify corrupted blocks.
+type Encoder struct {
+	w       *bufio.Writer
+	h       header
+	matrix  [][]byte
+	stripe  []byte
+	filled  int
+	written int64
+}
+
+func NewEncoder(w io.Writer, size int64, redundancy int) (*Encoder, error) {
+	h := newHeader(size, redundancy)
+	e := &Encoder{
+		w:      bufio.NewWriter(w),
+		h:      h,
+		matrix: encodingMatrix(int(h.DataShards), int(h.ParityShards)),
+		stripe: make([]byte, h.stripeBytes()),
+	}
+	return e, h.write(e.w)
+}
+
+func (e *Encoder) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		c := copy(e.stripe[e.filled:], p)
+		e.filled += c
+		p = p[c:]
+		if e.filled == len(e.stripe) {
+			if err := e.encodeStripe(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	e.written += int64(n)
+	return n, nil
+}
+
+// Close encodes the last padded stripe and flushes the recovery data
+func (e *Encoder) Close() error {
+	if e.filled > 0 {
+		clear(e.stripe[e.filled:])
+		if err := e.encodeStripe(); err != nil {
+			return err
+		}
+	}
+	if e.written != int64(e.h.Size) {
+		return errors.New("file size changed during parity encoding")
+	}
+	return e.w.Flush()
+}
+
+func (e *Encoder) encodeStripe() error {
+	shards := split(e.stripe, e.h)
+	parity := make([][]byte, e.h.ParityShards)
+	for i := range parity {
+		parity[i] = make([]byte, e.h.BlockSize)
+		for j, shard := range shards {
+			mulAdd(e.matrix[int(e.h.DataShards)+i][j], shard, parity[i])
+		}
+	}
+	for _, shard := range append(shards, parity...) {
+		if err := binary.Write(e.w, binary.LittleEndian, crc32.Checksum(shard, crcTable)); err != nil {
+			return err
+		}
+	}
+	for _, shard := range parity {
+		if _, err := e.w.Write(shard); err != nil {
+			return err
+		}
+	}
+	e.filled = 0
+	return nil
+}
+
+// Reconstruct restores the content of a damaged file into the target file based on the recovery data.
+// Missing or corrupted blocks are identified by their checksum and reconstructed as long as each stripe
+// contains enough valid blocks.
+func Reconstruct(damagedFilename string, parityFilename string, targetFilename string) error {
+	parityFile, err := os.Open(parityFilename)
+	if err != nil {
+		return errors.New("could not open parity file")
+	}
+	defer parityFile.Close()
+	r := bufio.NewReader(parityFile)
+	h, err := readHeader(r)
+	if err != nil {
+		return err
+	}
+	damaged, err := os.Open(damagedFilename)
+	if err != nil {
+		damaged = nil // a missing file is handled as completely corrupted
+	} else {
+		defer damaged.Close()
+	}
+	target, err := os.Create(targetFilename)
+	if err != nil {
+		return errors.New("could not create reconstructed file")
+	}
+	defer target.Close()
+
+	matrix := encodingMatrix(int(h.DataShards), int(h.ParityShards))
+	stripe := make([]byte, h.stripeBytes())
+	for s := int64(0); s < h.stripes(); s++ {
+		offset := s * h.stripeBytes()
+		clear(stripe)
+		if damaged != nil {
+			damaged.ReadAt(stripe[:min(h.stripeBytes(), int64(h.Size)-offset)], offset)
+		}
+		shards := split(stripe, h)
+		checksums := make([]uint32, h.totalShards())
+		if err := binary.Read(r, binary.LittleEndian, checksums); err != nil {
+			return errors.New("could not read parity checksums")
+		}
+		for i := 0; i < int(h.ParityShards); i++ {
+			shard := make([]byte, h.BlockSize)
+			if _, err := io.ReadFull(r, shard); err != nil {
+				return errors.New("could not read parity blocks")
+			}
+			shards = append(shards, shard)
+		}
+		if err := reconstructStripe(shards, checksums, matrix, h); err != nil {
+			return err
+		}
+		length := min(h.stripeBytes(), int64(h.Size)-offset)
+		if _, err := target.Write(stripe[:length]); err != nil {
+			return errors.New("could not write reconstructed file")
+		}
+	}
+	return target.Sync()
+}
+
+// Reconstructs invalid data shards in place based on the first valid shards
+func reconstructStripe(shards [][]byte, checksums []uint32, matrix [][]byte, h header) error {
+	valid := []int{}
+	invalidData := []int{}
+	for i, shard := range shards {
+		if crc32.Checksum(shard, crcTable) == checksums[i] {
+			valid = append(valid, i)
+		} else if i < int(h.DataShards) {
+			invalidData = append(invalidData, i)
+		}
+	}
+	if len(invalidData) == 0 {
+		return nil
+	}
+	if len(valid) < int(h.DataShards) {
+		return errors.New("too many corrupted blocks to reconstruct")
+	}
+	valid = valid[:h.DataShards]
+	sub := make([][]byte, len(valid))
+	for i, index := range valid {
+		sub[i] = matrix[index]
+	}
+	decoding, err := invert(sub)
+	if err != nil {
+		return err
+	}
+	restored := make([][]byte, len(invalidData))
+	for i, index := range invalidData {
+		restored[i] = make([]byte, h.BlockSize)
+		for j, validIndex := range valid {
+			mulAdd(decoding[index][j], shards[validIndex], restored[i])
+		}
+	}
+	for i, index := range invalidData {
+		copy(shards[index], restored[i])
+	}
+	return nil
+}
+
+// Splits a stripe into data shards that share the underlying memory
+func split(stripe []byte, h header) [][]byte {
+	shards := make([][]byte, h.DataShards)
+	for i := range shards {
+		shards[i] = stripe[i*int(h.BlockSize) : (i+1)*int(h.BlockSize)]
+	}
+	return shards
+}
@@ -0,0 +1,169 @@
+package parity
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvert(t *testing.T) {
+	m := encodingMatrix(4, 2)
+	sub := [][]byte{m[1], m[3], m[4], m[5]}
+	inverse, err := invert(sub)
+	assert.NoError(t, err)
+	for i := range sub {
+		for j := range sub {
+			var sum byte
+			for k := range sub {
+				sum ^= mul(sub[i][k], inverse[k][j])
+			}
+			expected := byte(0)
+			if i == j {
+				expected = 1
+			}
+			assert.Equal(t, expected, sum)
+		}
+	}
+}
+
+func TestNewHeader(t *testing.T) {
+	cases := []struct {
+		name       string
+		size       int64
+		redundancy int
+		expected   header
+	}{
+		{
+			name:       "Small file",
+			size:       10,
+			redundancy: 10,
+			expected:   header{Size: 10, BlockSize: minBlockSize, DataShards: 32, ParityShards: 4},
+		},
+		{
+			name:       "Medium file",
+			size:       3200,
+			redundancy: 50,
+			expected:   header{Size: 3200, BlockSize: 100, DataShards: 32, ParityShards: 16},
+		},
+		{
+			name:       "Large file",
+			size:       1024 * 1024 * 1024,
+			redundancy: 1,
+			expected:   header{Size: 1024 * 1024 * 1024, BlockSize: maxBlockSize, DataShards: 32, ParityShards: 1},
+		},
+		{
+			name:       "Exceeded redundancy",
+			size:       100,
+			redundancy: 200,
+			expected:   header{Size: 100, BlockSize: minBlockSize, DataShards: 32, ParityShards: 32},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, newHeader(c.size, c.redundancy))
+		})
+	}
+}
+
+func TestReconstruct(t *testing.T) {
+	content := make([]byte, 5*dataShards*minBlockSize+17)
+	rand.New(rand.NewSource(1)).Read(content)
+	blockSize := int(newHeader(int64(len(content)), 10).BlockSize)
+
+	cases := []struct {
+		name      string
+		corrupt   func(b []byte) []byte
+		expectErr bool
+	}{
+		{
+			name:    "Unchanged file",
+			corrupt: func(b []byte) []byte { return b },
+		},
+		{
+			name: "Corrupted blocks within redundancy",
+			corrupt: func(b []byte) []byte {
+				b[0] ^= 0xff
+				b[3*blockSize] ^= 0xff
+				b[len(b)-1] ^= 0xff
+				return b
+			},
+		},
+		{
+			name:    "Truncated last stripe",
+			corrupt: func(b []byte) []byte { return b[:len(b)-10] },
+		},
+		{
+			name: "Corrupted blocks exceed redundancy",
+			corrupt: func(b []byte) []byte {
+				for i := 0; i < 5; i++ {
+					b[i*blockSize] ^= 0xff
+				}
+				return b
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			parityFilename := filepath.Join(dir, "file.par")
+			damagedFilename := filepath.Join(dir, "damaged")
+			targetFilename := filepath.Join(dir, "target")
+
+			var buf bytes.Buffer
+			e, err := NewEncoder(&buf, int64(len(content)), 10)
+			assert.NoError(t, err)
+			_, err = e.Write(content)
+			assert.NoError(t, err)
+			assert.NoError(t, e.Close())
+			assert.NoError(t, os.WriteFile(parityFilename, buf.Bytes(), 0644))
+
+			damaged := c.corrupt(bytes.Clone(content))
+			assert.NoError(t, os.WriteFile(damagedFilename, damaged, 0644))
+
+			err = Reconstruct(damagedFilename, parityFilename, targetFilename)
+			if c.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			actual, err := os.ReadFile(targetFilename)
+			assert.NoError(t, err)
+			assert.Equal(t, content, actual)
+		})
+	}
+}
+
+func TestReconstruct_multipleStripes(t *testing.T) {
+	content := make([]byte, dataShards*maxBlockSize+100)
+	rand.New(rand.NewSource(1)).Read(content)
+	dir := t.TempDir()
+	parityFilename := filepath.Join(dir, "file.par")
+	damagedFilename := filepath.Join(dir, "damaged")
+	targetFilename := filepath.Join(dir, "target")
+
+	f, err := os.Create(parityFilename)
+	assert.NoError(t, err)
+	e, err := NewEncoder(f, int64(len(content)), 1)
+	assert.NoError(t, err)
+	_, err = e.Write(content)
+	assert.NoError(t, err)
+	assert.NoError(t, e.Close())
+	assert.NoError(t, f.Close())
+
+	damaged := bytes.Clone(content)
+	damaged[maxBlockSize+1] ^= 0xff
+	damaged[len(damaged)-1] ^= 0xff
+	assert.NoError(t, os.WriteFile(damagedFilename, damaged, 0644))
+
+	assert.NoError(t, Reconstruct(damagedFilename, parityFilename, targetFilename))
+	actual, err := os.ReadFile(targetFilename)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(content, actual))
+}
@@ -0,0 +1,68 @@
+package parity
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const magic = "FIPAR1"
+const dataShards = 32
+const minBlockSize = 64
+const maxBlockSize = 1024 * 1024
+
+type header struct {
+	Size         uint64
+	BlockSize    uint32
+	DataShards   uint16
+	ParityShards uint16
+}
+
+func newHeader(size int64, redundancy int) header {
+	blockSize := (size + dataShards - 1) / dataShards
+	blockSize = max(blockSize, minBlockSize)
+	blockSize = min(blockSize, maxBlockSize)
+	parityShards := (dataShards*redundancy + 99) / 100
+	parityShards = max(parityShards, 1)
+	parityShards = min(parityShards, dataShards)
+	return header{
+		Size:         uint64(size),
+		BlockSize:    uint32(blockSize),
+		DataShards:   dataShards,
+		ParityShards: uint16(parityShards),
+	}
+}
+
+func (h header) stripeBytes() int64 {
+	return int64(h.BlockSize) * int64(h.DataShards)
+}
+
+func (h header) stripes() int64 {
+	return (int64(h.Size) + h.stripeBytes() - 1) / h.stripeBytes()
+}
+
+func (h header) totalShards() int {
+	return int(h.DataShards) + int(h.ParityShards)
+}
+
+func (h header) write(w io.Writer) error {
+	if _, err := io.WriteString(w, magic); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, h)
+}
+
+func readHeader(r io.Reader) (header, error) {
+	h := header{}
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, m); err != nil || string(m) != magic {
+		return h, errors.New("invalid parity file")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return h, errors.New("invalid parity file header")
+	}
+	if h.DataShards == 0 || h.ParityShards == 0 || h.BlockSize == 0 || h.totalShards() > 256 {
+		return h, errors.New("invalid parity file header")
+	}
+	return h, nil
+}
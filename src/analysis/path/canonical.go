@@ -0,0 +1,94 @@
+package path
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Separator of canonical relative paths, which are independent of the platform
+const Separator = '/'
+
+// Canonical converts a relative path of the platform into the canonical form, which uses forward slashes and
+// the Unicode normalisation NFC. Hence, the same file has the same relative path on every platform, e.g. names
+// stored in NFD by macOS.
+func Canonical(relativePath string) string {
+	return norm.NFC.String(filepath.ToSlash(relativePath))
+}
+
+// Absolute converts the canonical relative path into the absolute path of the platform. Names stored in
+// another normalisation than NFC are resolved by the canonical form of the directory entries, missing names
+// are kept canonical, e.g. for files which are created afterwards.
+func Absolute(basePath string, relativePath string) string {
+	absolutePath := filepath.Join(basePath, filepath.FromSlash(relativePath))
+	if isASCII(relativePath) {
+		return absolutePath
+	}
+	if _, err := os.Lstat(absolutePath); err == nil {
+		return absolutePath
+	}
+	resolved := basePath
+	names := Split(relativePath)
+	for i, name := range names {
+		entry, ok := lookup(resolved, name)
+		if !ok {
+			return filepath.Join(append([]string{resolved}, names[i:]...)...)
+		}
+		resolved = filepath.Join(resolved, entry)
+	}
+	return resolved
+}
+
+// Split returns the names of the canonical relative path
+func Split(relativePath string) []string {
+	return strings.Split(relativePath, string(Separator))
+}
+
+// Dir returns the parent of the canonical relative path, "." for paths without parent
+func Dir(relativePath string) string {
+	i := strings.LastIndexByte(relativePath, Separator)
+	if i < 0 {
+		return "."
+	}
+	return relativePath[:i]
+}
+
+// Join concatenates canonical relative paths, empty paths are skipped
+func Join(relativePaths ...string) string {
+	names := []string{}
+	for _, relativePath := range relativePaths {
+		if relativePath != "" {
+			names = append(names, relativePath)
+		}
+	}
+	return strings.Join(names, string(Separator))
+}
+
+// Returns the directory entry, whose canonical form equals the name
+func lookup(dir string, name string) (string, bool) {
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		return name, true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		if norm.NFC.String(entry.Name()) == name {
+			return entry.Name(), true
+		}
+	}
+	return "", false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
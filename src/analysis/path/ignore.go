@@ -0,0 +1,52 @@
+package path
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// Ignore contains glob patterns of files and directories, which are not walked besides the system files.
+// Patterns without a slash match the name at any depth, e.g. "*.tmp", other patterns match the relative
+// path, e.g. "cache/*.bin". Patterns with a trailing slash only match directories, e.g. "node_modules/".
+type Ignore []string
+
+// ParseIgnore validates the glob syntax of the patterns
+func ParseIgnore(patterns []string) (Ignore, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil || pattern == "" {
+			return nil, errors.New("invalid ignore pattern: " + pattern)
+		}
+	}
+	return Ignore(patterns), nil
+}
+
+// Matches reports whether the file or directory of the canonical relative path is ignored
+func (i Ignore) Matches(relativePath string, isDir bool) bool {
+	name := path.Base(relativePath)
+	for _, pattern := range i {
+		dirOnly := strings.HasSuffix(pattern, "/")
+		if dirOnly && !isDir {
+			continue
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+		subject := name
+		if strings.Contains(pattern, "/") {
+			subject = relativePath
+		}
+		if matched, _ := path.Match(pattern, subject); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a parent directory of the relative path is ignored
+func (i Ignore) matchesParent(relativePath string) bool {
+	for parent := Dir(relativePath); parent != "."; parent = Dir(parent) {
+		if i.Matches(parent, true) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package path
+
+import "os"
+
+// Inodes are not supported, hence hardlinks are treated as independent files
+func inodeOf(info os.FileInfo) Inode {
+	return Inode{}
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package path
+
+import (
+	"os"
+	"syscall"
+)
+
+func inodeOf(info os.FileInfo) Inode {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return Inode{}
+	}
+	return Inode{
+		Device: uint64(stat.Dev),
+		Number: uint64(stat.Ino),
+		Links:  uint64(stat.Nlink),
+	}
+}
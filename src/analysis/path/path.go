@@ -2,10 +2,13 @@ package path
 
 import (
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 
+	"github.com/aicirt2012/fileintegrity/src/analysis/meta"
 	"github.com/aicirt2012/fileintegrity/src/store/dir"
 
 	"golang.org/x/exp/slices"
@@ -26,42 +29,298 @@ var ignoredFiles = []*regexp.Regexp{
 	regexp.MustCompile(`^~\$.*$`),      // win ~$*
 	regexp.MustCompile(`^\._.*$`),      // macos ._*
 	regexp.MustCompile(`^\.DS_Store$`), // macos
+	regexp.MustCompile(`^.*\.fitmp$`),  // temporary files of former versions
 }
 
 func ComputeDiskFileMap(basePath string) (DiskFileMap, error) {
 	diskFileMap := DiskFileMap{}
-	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
-		if basePath == path {
+	err := Walk(basePath, RECORD, nil, func(diskFile DiskFile) error {
+		diskFileMap.Add(diskFile)
+		return nil
+	})
+	return diskFileMap, err
+}
+
+// Walk streams all files and empty directories of the directory ordered by relative path according to Compare.
+// Symbolic links are handled according to the policy, ignored files and directories are skipped. Relative paths
+// are canonical, while absolute paths keep the names of the file system.
+func Walk(basePath string, symlinks Symlinks, ignore Ignore, fn func(DiskFile) error) error {
+	realPath, err := filepath.EvalSymlinks(basePath)
+	if err != nil {
+		return err
+	}
+	return walk(basePath, basePath, "", symlinks, ignore, []string{realPath}, fn)
+}
+
+// Walks the root, which is either the base path or the target of a followed directory link. The roots contain
+// the real paths of all followed directories to detect loops.
+func walk(basePath string, root string, relRoot string, symlinks Symlinks, ignore Ignore, roots []string, fn func(DiskFile) error) error {
+	return walkSorted(root, func(path string, info os.FileInfo, err error) error {
+		if root == path {
 			return nil
 		}
-		if info.IsDir() && isIgnoreDir(info.Name()) {
+		if err != nil {
+			// Entries removed during the walk are skipped, the info is nil if the entry could not be read
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if info != nil && info.IsDir() && IsIgnoredDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() && IsIgnoredDir(info.Name()) {
 			return filepath.SkipDir
 		}
+		relPath, err := filepath.Rel(root, path)
 		if err != nil {
-			return err
+			return errors.New("could not extract relative path from: " + path)
+		}
+		relPath = Join(relRoot, Canonical(relPath))
+		if ignore.Matches(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() && !isEmptyDir(path) {
+			return nil
 		}
-		if info.IsDir() {
+		if !info.IsDir() && isIgnoredFile(info.Name()) {
 			return nil
 		}
-		if isIgnoredFile(info.Name()) {
+		diskFile := newDiskFile(path, relPath, info)
+		if info.IsDir() || !isSymlink(info) {
+			return fn(diskFile)
+		}
+		if symlinks == IGNORE {
 			return nil
 		}
-		relPath, err := filepath.Rel(basePath, path)
+		diskFile, linkedDir, err := resolveLink(diskFile, symlinks)
 		if err != nil {
-			return errors.New("could not extract relative path from: " + path)
+			return err
+		}
+		if !linkedDir {
+			return fn(diskFile)
 		}
-		diskFileMap.Add(DiskFile{
-			AbsolutePath: path,
-			RelativePath: relPath,
-			Size:         info.Size(),
-			ModTime:      info.ModTime(),
+		realTarget, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return errors.New("could not resolve link: " + path)
+		}
+		realDir, err := filepath.EvalSymlinks(filepath.Dir(path))
+		if err != nil {
+			return errors.New("could not resolve directory: " + path)
+		}
+		if isLoop(realTarget, realDir, roots) {
+			return nil
+		}
+		return walk(basePath, realTarget, relPath, symlinks, ignore, append(roots, realTarget), fn)
+	})
+}
+
+// Stat returns the disk file of a single canonical relative path like Walk. False is returned, if the path does
+// not exist or is not walked, e.g. ignored files, directories containing files or ignored links. Followed
+// directory links are not walked, hence they are not returned either.
+func Stat(basePath string, relativePath string, symlinks Symlinks, ignore Ignore) (DiskFile, bool) {
+	names := Split(relativePath)
+	for _, name := range names[:len(names)-1] {
+		if IsIgnoredDir(name) {
+			return DiskFile{}, false
+		}
+	}
+	absolutePath := Absolute(basePath, relativePath)
+	info, err := os.Lstat(absolutePath)
+	if err != nil {
+		return DiskFile{}, false
+	}
+	if ignore.matchesParent(relativePath) || ignore.Matches(relativePath, info.IsDir()) {
+		return DiskFile{}, false
+	}
+	name := names[len(names)-1]
+	if info.IsDir() && (IsIgnoredDir(name) || !isEmptyDir(absolutePath)) {
+		return DiskFile{}, false
+	}
+	if !info.IsDir() && isIgnoredFile(name) {
+		return DiskFile{}, false
+	}
+	diskFile := newDiskFile(absolutePath, relativePath, info)
+	if info.IsDir() || !isSymlink(info) {
+		return diskFile, true
+	}
+	if symlinks == IGNORE {
+		return DiskFile{}, false
+	}
+	diskFile, linkedDir, err := resolveLink(diskFile, symlinks)
+	if err != nil || linkedDir {
+		return DiskFile{}, false
+	}
+	return diskFile, true
+}
+
+func newDiskFile(absolutePath string, relativePath string, info os.FileInfo) DiskFile {
+	diskFile := DiskFile{
+		AbsolutePath: absolutePath,
+		RelativePath: relativePath,
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		Inode:        inodeOf(info),
+		Metadata:     meta.FromInfo(info),
+	}
+	if info.IsDir() {
+		diskFile.Dir = true
+		diskFile.Size = 0
+		diskFile.Inode = Inode{}
+	}
+	return diskFile
+}
+
+// Resolves a symbolic link according to the policy. Returns true, if the link is a followed directory link,
+// which is walked instead of being returned.
+func resolveLink(diskFile DiskFile, symlinks Symlinks) (DiskFile, bool, error) {
+	target, err := os.Readlink(diskFile.AbsolutePath)
+	if err != nil {
+		return diskFile, false, errors.New("could not read link: " + diskFile.AbsolutePath)
+	}
+	targetInfo, err := os.Stat(diskFile.AbsolutePath)
+	if symlinks == RECORD || err != nil {
+		// Dangling links can not be followed, hence they are recorded
+		diskFile.Target = target
+		diskFile.Size = int64(len(target))
+		diskFile.Inode = Inode{}
+		diskFile.Metadata = meta.Metadata{}
+		return diskFile, false, nil
+	}
+	if targetInfo.IsDir() {
+		return diskFile, true, nil
+	}
+	diskFile.Size = targetInfo.Size()
+	diskFile.ModTime = targetInfo.ModTime()
+	diskFile.Inode = inodeOf(targetInfo)
+	diskFile.Metadata = meta.FromInfo(targetInfo)
+	return diskFile, false, nil
+}
+
+// Stream walks the directory in the background. The returned channel is closed after the walk, afterwards
+// the error of the walk is available.
+func Stream(basePath string, symlinks Symlinks, ignore Ignore) (<-chan DiskFile, func() error) {
+	diskFiles := make(chan DiskFile, 1000)
+	var err error
+	go func() {
+		defer close(diskFiles)
+		err = Walk(basePath, symlinks, ignore, func(diskFile DiskFile) error {
+			diskFiles <- diskFile
+			return nil
 		})
+	}()
+	return diskFiles, func() error {
+		return err
+	}
+}
+
+// Compare orders relative paths segment by segment, which corresponds to the order of a directory walk.
+// Hence, all files of a directory are placed before a sibling with a name starting equally, e.g. a/b/c < a/b.txt
+func Compare(a string, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := a[i], b[i]
+		if ca == cb {
+			continue
+		}
+		if ca == Separator {
+			return -1
+		}
+		if cb == Separator {
+			return 1
+		}
+		if ca < cb {
+			return -1
+		}
+		return 1
+	}
+	return len(a) - len(b)
+}
+
+// Walks the tree like filepath.Walk, but the entries of each directory are ordered by their canonical name.
+// Hence, the walk order corresponds to Compare of the canonical relative paths.
+func walkSorted(root string, fn filepath.WalkFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkTree(root, info, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
 		return nil
+	}
+	return err
+}
+
+func walkTree(path string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	names, err := readCanonicalDirNames(path)
+	if err := fn(path, info, err); err != nil || names == nil {
+		return err
+	}
+	for _, name := range names {
+		filename := filepath.Join(path, name)
+		fileInfo, err := os.Lstat(filename)
+		if err != nil {
+			if err := fn(filename, fileInfo, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+		} else if err := walkTree(filename, fileInfo, fn); err != nil {
+			if !fileInfo.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the names of the directory entries ordered by their canonical form, nil on failure
+func readCanonicalDirNames(dirname string) ([]string, error) {
+	dir, err := os.Open(dirname)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := Canonical(names[i]), Canonical(names[j])
+		return a < b || a == b && names[i] < names[j]
 	})
-	return diskFileMap, err
+	return names, nil
+}
+
+// A directory is empty, if it contains no entries besides ignored ones
+func isEmptyDir(path string) bool {
+	dir, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+	for {
+		names, err := dir.Readdirnames(100)
+		for _, name := range names {
+			if !IsIgnoredDir(name) && !isIgnoredFile(name) {
+				return false
+			}
+		}
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
 }
 
-func isIgnoreDir(name string) bool {
+// IsIgnoredDir is true for system directories and the integrity directory, which are not walked
+func IsIgnoredDir(name string) bool {
 	return slices.Contains(ignoredDirs, name)
 }
 
@@ -1,8 +1,13 @@
 package path
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
+	"golang.org/x/exp/slices"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,6 +42,11 @@ func TestIsIgnoredFile(t *testing.T) {
 			input:    ".DS_Store",
 			expected: true,
 		},
+		{
+			name:     "Temporary file",
+			input:    "a1.txt.fitmp",
+			expected: true,
+		},
 		{
 			name:     "Valid hidden file",
 			input:    ".",
@@ -55,3 +65,203 @@ func TestIsIgnoredFile(t *testing.T) {
 		})
 	}
 }
+
+func TestCompare(t *testing.T) {
+	assert.Equal(t, 0, Compare("a/b", "a/b"))
+	assert.Less(t, Compare("a/b", "a/c"), 0)
+	assert.Less(t, Compare("a/b/c", "a/b.txt"), 0)
+	assert.Greater(t, Compare("a/b.txt", "a/b/c"), 0)
+	assert.Less(t, Compare("a", "a/b"), 0)
+	assert.Less(t, Compare("a-b", "ab"), 0)
+}
+
+func TestWalk_order(t *testing.T) {
+	basePath := t.TempDir()
+	for _, name := range []string{"b.txt", "b/c.txt", "b/a/d.txt", "a b.txt", "b-c.txt", "c.txt"} {
+		filename := filepath.Join(basePath, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		os.WriteFile(filename, []byte(name), 0644)
+	}
+	paths := []string{}
+	err := Walk(basePath, RECORD, nil, func(diskFile DiskFile) error {
+		paths = append(paths, diskFile.RelativePath)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, paths, 6)
+	assert.True(t, slices.IsSortedFunc(paths, Compare))
+}
+
+func TestWalk_emptyDirs(t *testing.T) {
+	basePath := t.TempDir()
+	os.MkdirAll(filepath.Join(basePath, "a", "empty"), 0755)
+	os.MkdirAll(filepath.Join(basePath, "b", ".integrity"), 0755)
+	os.WriteFile(filepath.Join(basePath, "a", "f.txt"), []byte("f"), 0644)
+	paths := []string{}
+	err := Walk(basePath, RECORD, nil, func(diskFile DiskFile) error {
+		p := filepath.ToSlash(diskFile.RelativePath)
+		if diskFile.Dir {
+			p += "/"
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a/empty/", "a/f.txt", "b/"}, paths)
+}
+
+func TestWalk_ignore(t *testing.T) {
+	basePath := t.TempDir()
+	for _, name := range []string{"a.txt", "a.tmp", "b/c.tmp", "cache/x.bin", "cache/y.txt", "node_modules/m.js", "d/node_modules"} {
+		filename := filepath.Join(basePath, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		os.WriteFile(filename, []byte(name), 0644)
+	}
+	ignore, err := ParseIgnore([]string{"*.tmp", "cache/*.bin", "node_modules/"})
+	assert.Nil(t, err)
+	paths := []string{}
+	err = Walk(basePath, RECORD, ignore, func(diskFile DiskFile) error {
+		paths = append(paths, filepath.ToSlash(diskFile.RelativePath))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "cache/y.txt", "d/node_modules"}, paths)
+
+	_, ok := Stat(basePath, "node_modules/m.js", RECORD, ignore)
+	assert.False(t, ok)
+	_, ok = Stat(basePath, "cache/y.txt", RECORD, ignore)
+	assert.True(t, ok)
+
+	_, err = ParseIgnore([]string{"[a-"})
+	assert.NotNil(t, err)
+}
+
+func TestWalk_removedDuringWalk(t *testing.T) {
+	basePath := t.TempDir()
+	os.MkdirAll(filepath.Join(basePath, "b"), 0755)
+	for _, name := range []string{"a.txt", "b/b1.txt", "c.txt"} {
+		os.WriteFile(filepath.Join(basePath, filepath.FromSlash(name)), []byte(name), 0644)
+	}
+	paths := []string{}
+	err := Walk(basePath, RECORD, nil, func(diskFile DiskFile) error {
+		paths = append(paths, diskFile.RelativePath)
+		if diskFile.RelativePath == "a.txt" {
+			os.RemoveAll(filepath.Join(basePath, "b"))
+			os.Remove(filepath.Join(basePath, "c.txt"))
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt"}, paths)
+}
+
+func TestStat(t *testing.T) {
+	basePath := t.TempDir()
+	os.MkdirAll(filepath.Join(basePath, "a", "empty"), 0755)
+	os.MkdirAll(filepath.Join(basePath, ".integrity"), 0755)
+	os.WriteFile(filepath.Join(basePath, "a", "f.txt"), []byte("f"), 0644)
+	os.WriteFile(filepath.Join(basePath, "a", ".DS_Store"), []byte("d"), 0644)
+	os.WriteFile(filepath.Join(basePath, ".integrity", ".integrity"), []byte("i"), 0644)
+
+	diskFile, ok := Stat(basePath, "a/f.txt", RECORD, nil)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), diskFile.Size)
+	assert.Equal(t, filepath.Join(basePath, "a", "f.txt"), diskFile.AbsolutePath)
+	diskFile, ok = Stat(basePath, "a/empty", RECORD, nil)
+	assert.True(t, ok)
+	assert.True(t, diskFile.Dir)
+
+	for _, relativePath := range []string{"a", "a/.DS_Store", ".integrity/.integrity", "missing.txt"} {
+		_, ok = Stat(basePath, relativePath, RECORD, nil)
+		assert.False(t, ok, relativePath)
+	}
+}
+
+func TestWalk_canonical(t *testing.T) {
+	basePath := t.TempDir()
+	nfd := "e\u0301"
+	for _, name := range []string{"ea.txt", "f.txt", nfd + ".txt", nfd + "/g.txt"} {
+		filename := filepath.Join(basePath, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		os.WriteFile(filename, []byte(name), 0644)
+	}
+	paths := []string{}
+	err := Walk(basePath, RECORD, nil, func(diskFile DiskFile) error {
+		paths = append(paths, diskFile.RelativePath)
+		assert.FileExists(t, diskFile.AbsolutePath)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ea.txt", "f.txt", "\u00e9/g.txt", "\u00e9.txt"}, paths)
+	assert.True(t, slices.IsSortedFunc(paths, Compare))
+
+	assert.Equal(t, filepath.Join(basePath, nfd, "g.txt"), Absolute(basePath, "\u00e9/g.txt"))
+	assert.Equal(t, filepath.Join(basePath, nfd, "new.txt"), Absolute(basePath, "\u00e9/new.txt"))
+	diskFile, ok := Stat(basePath, "\u00e9.txt", RECORD, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "\u00e9.txt", diskFile.RelativePath)
+	assert.Equal(t, filepath.Join(basePath, nfd+".txt"), diskFile.AbsolutePath)
+}
+
+func TestCanonical(t *testing.T) {
+	assert.Equal(t, "a/b.txt", Canonical(filepath.Join("a", "b.txt")))
+	assert.Equal(t, "\u00e9", Canonical("e\u0301"))
+	assert.Equal(t, "a", Dir("a/b"))
+	assert.Equal(t, ".", Dir("a"))
+	assert.Equal(t, "a/b", Join("", "a", "b"))
+}
+
+func TestInode_ID(t *testing.T) {
+	assert.Equal(t, "", Inode{}.ID())
+	assert.Equal(t, "", Inode{Device: 1, Number: 2, Links: 1}.ID())
+	assert.Equal(t, "1:2", Inode{Device: 1, Number: 2, Links: 2}.ID())
+}
+
+func TestWalk_symlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges")
+	}
+	basePath := t.TempDir()
+	os.MkdirAll(filepath.Join(basePath, "d"), 0755)
+	os.WriteFile(filepath.Join(basePath, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(basePath, "d", "f.txt"), []byte("f"), 0644)
+	os.Symlink("a.txt", filepath.Join(basePath, "l.txt"))
+	os.Symlink("d", filepath.Join(basePath, "ld"))
+	os.Symlink("..", filepath.Join(basePath, "d", "loop"))
+	os.Symlink("missing.txt", filepath.Join(basePath, "x.txt"))
+
+	cases := []struct {
+		symlinks Symlinks
+		expected []string
+	}{
+		{IGNORE, []string{"a.txt", "d/f.txt"}},
+		{RECORD, []string{"a.txt", "d/f.txt", "d/loop -> ..", "l.txt -> a.txt", "ld -> d", "x.txt -> missing.txt"}},
+		{FOLLOW, []string{"a.txt", "d/f.txt", "l.txt", "ld/f.txt", "x.txt -> missing.txt"}},
+	}
+	for _, c := range cases {
+		t.Run(string(c.symlinks), func(t *testing.T) {
+			paths := []string{}
+			err := Walk(basePath, c.symlinks, nil, func(diskFile DiskFile) error {
+				p := filepath.ToSlash(diskFile.RelativePath)
+				if diskFile.Target != "" {
+					p += " -> " + diskFile.Target
+				}
+				paths = append(paths, p)
+				return nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, paths)
+		})
+	}
+}
+
+func TestParseSymlinks(t *testing.T) {
+	symlinks, err := ParseSymlinks("")
+	assert.Nil(t, err)
+	assert.Equal(t, RECORD, symlinks)
+	symlinks, err = ParseSymlinks("Follow")
+	assert.Nil(t, err)
+	assert.Equal(t, FOLLOW, symlinks)
+	_, err = ParseSymlinks("unknown")
+	assert.Error(t, err)
+}
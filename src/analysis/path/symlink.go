@@ -0,0 +1,44 @@
+package path
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Symlinks defines the handling of symbolic links during the directory walk
+type Symlinks string
+
+const (
+	IGNORE Symlinks = "ignore" // Links are skipped
+	RECORD Symlinks = "record" // Links are recorded with their target, the target itself is not hashed
+	FOLLOW Symlinks = "follow" // Linked files are hashed and linked directories are walked
+)
+
+// ParseSymlinks validates the policy name, an empty name defaults to record
+func ParseSymlinks(name string) (Symlinks, error) {
+	switch Symlinks(strings.ToLower(name)) {
+	case "", RECORD:
+		return RECORD, nil
+	case IGNORE:
+		return IGNORE, nil
+	case FOLLOW:
+		return FOLLOW, nil
+	}
+	return "", errors.New("unknown symlink policy: " + name)
+}
+
+func isSymlink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// A followed directory creates a loop, if it contains the current directory or one of the walked roots
+func isLoop(target string, dir string, roots []string) bool {
+	for _, p := range append(roots, dir) {
+		if p == target || strings.HasPrefix(p, target+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,13 @@
 package path
 
 import (
+	"fmt"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/meta"
 )
 
 type DiskFileMap map[string]DiskFile
@@ -54,6 +57,10 @@ type DiskFile struct {
 	RelativePath string
 	Size         int64
 	ModTime      time.Time
+	Inode        Inode
+	Target       string // Target of a recorded symbolic link, empty for files
+	Dir          bool   // Empty directory
+	Metadata     meta.Metadata
 }
 
 func (df DiskFile) Base() string {
@@ -74,3 +81,23 @@ func (df DiskFile) String() string {
 	s += df.RelativePath + "\n"
 	return s
 }
+
+// Inode identifies the file content on Unix, which is shared by all hardlinks of a file
+type Inode struct {
+	Device uint64
+	Number uint64
+	Links  uint64
+}
+
+// Linked is true, if the file has further hardlinks
+func (i Inode) Linked() bool {
+	return i.Links > 1
+}
+
+// ID identifies the hardlink group of a file, which is empty for files without further hardlinks
+func (i Inode) ID() string {
+	if !i.Linked() {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", i.Device, i.Number)
+}
@@ -0,0 +1,74 @@
+package throttle
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+const controlFilename = "throttle"
+const pollInterval = time.Second
+
+// Start applies the process priority. The limits can be changed at runtime with the control file
+// .integrity/throttle, which is polled until the returned stop function is executed. Removing the control
+// file restores the initial limits. The priority applies to the whole process, unlike the limits, and the stop
+// function restores the previous priority as far as permitted, e.g. a raised niceness requires privileges.
+func (t *Throttle) Start(basePath string) (stop func()) {
+	if t == nil {
+		return func() {}
+	}
+	restore, err := setPriority(t.initial.Nice, t.initial.IdleIO)
+	if err != nil {
+		log.Println("could not set process priority:", err)
+	}
+	controlFile := filepath.Join(basePath, dir.Name, controlFilename)
+	done := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		var modTime time.Time
+		reload := func() {
+			info, err := os.Stat(controlFile)
+			if err != nil {
+				if !modTime.IsZero() {
+					modTime = time.Time{}
+					t.Set(t.initial)
+				}
+				return
+			}
+			if info.ModTime().Equal(modTime) {
+				return
+			}
+			modTime = info.ModTime()
+			content, err := os.ReadFile(controlFile)
+			if err != nil {
+				return
+			}
+			updated, err := parse(string(content), t.initial)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			t.Set(updated)
+		}
+		reload()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				reload()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		restore()
+	}
+}
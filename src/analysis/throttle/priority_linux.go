@@ -0,0 +1,87 @@
+//go:build linux
+
+package throttle
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+const ioprioClassIdle = 3
+const ioprioClassShift = 13
+const ioprioWhoProcess = 1
+
+// Priorities are thread specific on linux, hence they are set for all threads of the process. New threads
+// inherit the priority of the creating thread. The returned restore function sets the previous priority of the
+// calling thread for all threads again.
+func setPriority(nice int, idleIO bool) (restore func(), err error) {
+	if nice <= 0 && !idleIO {
+		return func() {}, nil
+	}
+	// The raw getpriority syscall returns 20 - nice to avoid negative values
+	previous, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		return func() {}, err
+	}
+	previousIO, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		return func() {}, errno
+	}
+	restore = func() {
+		err := forEachThread(func(tid int) error {
+			if nice > 0 {
+				// Lowering the niceness requires privileges, hence it is only restored where permitted
+				err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, 20-previous)
+				if err != nil && err != syscall.EACCES && err != syscall.EPERM {
+					return err
+				}
+			}
+			if idleIO {
+				return ioprioSet(tid, previousIO)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Println("could not restore process priority:", err)
+		}
+	}
+	return restore, forEachThread(func(tid int) error {
+		if nice > 0 {
+			if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, min(nice, 19)); err != nil {
+				return err
+			}
+		}
+		if idleIO {
+			return ioprioSet(tid, ioprioClassIdle<<ioprioClassShift)
+		}
+		return nil
+	})
+}
+
+func forEachThread(fn func(tid int) error) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// Threads exited in the meanwhile are skipped
+		if err := fn(tid); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+func ioprioSet(tid int, ioprio uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprio)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package throttle
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStart_restorePriority(t *testing.T) {
+	ioprio := func() uintptr {
+		value, _, _ := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+		return value
+	}
+	previous := ioprio()
+	stop := New(Limits{IdleIO: true}).Start(t.TempDir())
+	assert.Equal(t, uintptr(ioprioClassIdle<<ioprioClassShift), ioprio())
+	stop()
+	assert.Equal(t, previous, ioprio())
+}
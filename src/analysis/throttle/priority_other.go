@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package throttle
+
+// Process priorities are not supported
+func setPriority(nice int, idleIO bool) (restore func(), err error) {
+	return func() {}, nil
+}
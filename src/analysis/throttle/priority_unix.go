@@ -0,0 +1,28 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package throttle
+
+import (
+	"log"
+	"syscall"
+)
+
+// The idle I/O scheduling class is not supported. The returned restore function sets the previous niceness
+// again, as far as permitted.
+func setPriority(nice int, idleIO bool) (restore func(), err error) {
+	if nice <= 0 {
+		return func() {}, nil
+	}
+	previous, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		return func() {}, err
+	}
+	restore = func() {
+		// Lowering the niceness requires privileges
+		err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, previous)
+		if err != nil && err != syscall.EACCES && err != syscall.EPERM {
+			log.Println("could not restore process priority:", err)
+		}
+	}
+	return restore, syscall.Setpriority(syscall.PRIO_PROCESS, 0, min(nice, 19))
+}
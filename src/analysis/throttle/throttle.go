@@ -0,0 +1,120 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+const minReadSize = 64 * 1024
+
+// Throttle limits the file readers of a single execution, so concurrent executions of other directories are
+// not affected. A nil throttle does not limit.
+type Throttle struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	initial Limits // Restored when the control file is removed
+	rate    int64
+	tokens  float64
+	last    time.Time
+	workers int
+	active  int
+}
+
+// New creates a throttle with the limits, the process priority is applied once the throttle is started
+func New(limits Limits) *Throttle {
+	t := &Throttle{initial: limits}
+	t.cond = sync.NewCond(&t.mu)
+	t.Set(limits)
+	return t
+}
+
+// Set changes the read rate and the worker limit. Blocked readers are released if the worker limit increases.
+func (t *Throttle) Set(limits Limits) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rate != limits.ReadRate {
+		t.tokens = 0
+		t.last = time.Now()
+	}
+	t.rate = limits.ReadRate
+	t.workers = limits.Workers
+	t.cond.Broadcast()
+}
+
+// Current returns the active read rate and worker limit
+func (t *Throttle) Current() Limits {
+	if t == nil {
+		return Limits{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Limits{ReadRate: t.rate, Workers: t.workers}
+}
+
+// ReadSize limits the size of a single read, so the throughput is smoothed over the time
+func (t *Throttle) ReadSize(n int) int {
+	if t == nil {
+		return n
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rate <= 0 {
+		return n
+	}
+	return min(n, max(minReadSize, int(t.rate/10)))
+}
+
+// Wait blocks until the given number of read bytes is within the read rate
+func (t *Throttle) Wait(n int) {
+	if d := t.reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Acquire blocks until the number of active readers is below the worker limit
+func (t *Throttle) Acquire() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.workers > 0 && t.active >= t.workers {
+		t.cond.Wait()
+	}
+	t.active++
+}
+
+func (t *Throttle) Release() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	t.cond.Broadcast()
+}
+
+// Token bucket with a burst of one second. Missing tokens are reserved as debt and the caller has to wait
+// until the debt is refilled.
+func (t *Throttle) reserve(n int) time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * float64(t.rate)
+	t.tokens = min(t.tokens, float64(t.rate))
+	t.last = now
+	t.tokens -= float64(n)
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / float64(t.rate) * float64(time.Second))
+}
@@ -0,0 +1,100 @@
+package throttle
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	defaults := Limits{ReadRate: 1000, Workers: 4, Nice: 10}
+	limits, err := parse("# nightly\nrate = 20MB\n\nworkers=2\n", defaults)
+	assert.Nil(t, err)
+	assert.Equal(t, Limits{ReadRate: 20 * 1000 * 1000, Workers: 2, Nice: 10}, limits)
+
+	limits, err = parse("workers=1", defaults)
+	assert.Nil(t, err)
+	assert.Equal(t, Limits{ReadRate: 1000, Workers: 1, Nice: 10}, limits)
+
+	for _, content := range []string{"rate", "rate=fast", "workers=-1", "speed=1"} {
+		limits, err = parse(content, defaults)
+		assert.NotNil(t, err)
+		assert.Equal(t, defaults, limits)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	th := New(Limits{})
+	assert.Equal(t, time.Duration(0), th.reserve(1000))
+
+	th.Set(Limits{ReadRate: 1000})
+	d := th.reserve(500)
+	assert.InDelta(t, 500*time.Millisecond, d, float64(50*time.Millisecond))
+	d = th.reserve(500)
+	assert.InDelta(t, time.Second, d, float64(50*time.Millisecond))
+}
+
+func TestReadSize(t *testing.T) {
+	var unlimited *Throttle
+	assert.Equal(t, 1024*1024, unlimited.ReadSize(1024*1024))
+	th := New(Limits{})
+	assert.Equal(t, 1024*1024, th.ReadSize(1024*1024))
+	th.Set(Limits{ReadRate: 10 * 1024 * 1024})
+	assert.Equal(t, 1024*1024, th.ReadSize(30*1024*1024))
+	th.Set(Limits{ReadRate: 1024})
+	assert.Equal(t, minReadSize, th.ReadSize(30*1024*1024))
+}
+
+func TestAcquire(t *testing.T) {
+	th := New(Limits{Workers: 2})
+	var active, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			th.Acquire()
+			defer th.Release()
+			n := active.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(10 * time.Millisecond)
+			active.Add(-1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestStart_controlFile(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	controlFile := filepath.Join(basePath, dir.Name, controlFilename)
+
+	th := New(Limits{ReadRate: 1000})
+	other := New(Limits{})
+	stop := th.Start(basePath)
+	assert.Equal(t, Limits{ReadRate: 1000}, th.Current())
+
+	os.WriteFile(controlFile, []byte("workers=3\n"), 0644)
+	assert.Eventually(t, func() bool {
+		return th.Current() == Limits{ReadRate: 1000, Workers: 3}
+	}, 3*time.Second, 100*time.Millisecond)
+	assert.Equal(t, Limits{}, other.Current())
+
+	os.Remove(controlFile)
+	assert.Eventually(t, func() bool {
+		return th.Current() == Limits{ReadRate: 1000}
+	}, 3*time.Second, 100*time.Millisecond)
+
+	// Executions of other directories keep their limits
+	stop()
+	assert.Equal(t, Limits{ReadRate: 1000}, th.Current())
+	assert.Equal(t, Limits{}, other.Current())
+}
@@ -0,0 +1,52 @@
+package throttle
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Limits for background executions, zero values disable the corresponding limit
+type Limits struct {
+	ReadRate int64 // Read throughput in bytes per second
+	Workers  int   // Maximum number of concurrent file readers
+	Nice     int   // Process niceness between 1 and 19
+	IdleIO   bool  // Idle I/O scheduling class, only supported on linux
+}
+
+// Parses the content of a control file. Each line contains a key value pair, e.g. "rate=20MB" or "workers=2".
+// Keys not contained within the content keep the given default value.
+func parse(content string, defaults Limits) (Limits, error) {
+	limits := defaults
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return defaults, errors.New("invalid throttle line: " + line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "rate":
+			rate, err := humanize.ParseBytes(value)
+			if err != nil {
+				return defaults, errors.New("invalid throttle rate: " + value)
+			}
+			limits.ReadRate = int64(rate)
+		case "workers":
+			workers, err := strconv.Atoi(value)
+			if err != nil || workers < 0 {
+				return defaults, errors.New("invalid throttle workers: " + value)
+			}
+			limits.Workers = workers
+		default:
+			return defaults, errors.New("unknown throttle key: " + key)
+		}
+	}
+	return limits, nil
+}
@@ -0,0 +1,179 @@
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+)
+
+const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+var errWatchLimit = errors.New("inotify watch limit reached")
+
+// Adds the watch of a directory, replaced by tests to simulate the watch limit
+var addWatch = syscall.InotifyAddWatch
+
+type inotify struct {
+	basePath string
+	fd       int
+	file     *os.File
+	dirs     map[int]string // Relative directory per watch descriptor
+	events   chan Event
+	done     chan bool
+}
+
+// New watches all directories below the base path with inotify. The integrity directory and ignored
+// directories are not watched. An error is returned, if inotify is not available or the watch limit
+// fs.inotify.max_user_watches is reached. Reaching the watch limit for a directory created later closes the
+// events, since the new subtree remains unobserved, hence the caller falls back to polling.
+func New(basePath string) (Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.New("could not initialize inotify: " + err.Error())
+	}
+	w := &inotify{
+		basePath: basePath,
+		fd:       fd,
+		file:     os.NewFile(uintptr(fd), "inotify"),
+		dirs:     map[int]string{},
+		events:   make(chan Event, 1000),
+		done:     make(chan bool),
+	}
+	if err := w.addTree("", nil); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	go w.read()
+	return w, nil
+}
+
+func (w *inotify) Events() <-chan Event {
+	return w.events
+}
+
+func (w *inotify) Close() {
+	close(w.done)
+	w.file.Close()
+}
+
+func (w *inotify) read() {
+	defer close(w.events)
+	defer w.file.Close()
+	buf := make([]byte, 64*1024)
+	for {
+		// The non-blocking descriptor is served by the runtime poller, hence Close interrupts the read
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[start:start+int(raw.Len)]), "\x00")
+			offset = start + int(raw.Len)
+			if !w.handle(int(raw.Wd), raw.Mask, name) {
+				return
+			}
+		}
+	}
+}
+
+// Translates an inotify event, returns false if the watcher is closed or the watch limit is reached
+func (w *inotify) handle(wd int, m uint32, name string) bool {
+	if m&syscall.IN_Q_OVERFLOW != 0 {
+		return w.send(Event{Overflow: true})
+	}
+	if m&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		return true
+	}
+	dir, ok := w.dirs[wd]
+	if !ok || name == "" {
+		return true
+	}
+	relativePath := filepath.Join(dir, name)
+	isDir := m&syscall.IN_ISDIR != 0
+	switch {
+	case isDir && path.IsIgnoredDir(name):
+		return true
+	case isDir && m&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		// Files may be created before the directory is watched, hence all contained paths are reported
+		if !w.send(Event{RelativePath: relativePath}) {
+			return false
+		}
+		if err := w.addTree(relativePath, w.send); err != nil {
+			log.Println(err.Error()+", changes are not observed:", filepath.Join(w.basePath, relativePath))
+			return false
+		}
+		return true
+	case isDir && m&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		w.removeTree(relativePath)
+		return w.send(Event{RelativePath: relativePath, Tree: true})
+	}
+	return w.send(Event{RelativePath: relativePath})
+}
+
+// Sends the event with the canonical relative path, returns false if the watcher is closed
+func (w *inotify) send(event Event) bool {
+	event.RelativePath = path.Canonical(event.RelativePath)
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// Watches the directory and all directories below. Contained paths are reported to the optional send function.
+func (w *inotify) addTree(relativeDir string, send func(Event) bool) error {
+	root := filepath.Join(w.basePath, relativeDir)
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Paths removed in the meanwhile are reported by their own events
+			return nil
+		}
+		relativePath, err := filepath.Rel(w.basePath, p)
+		if err != nil {
+			return err
+		}
+		if p != root && send != nil && !send(Event{RelativePath: relativePath}) {
+			return filepath.SkipAll
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != w.basePath && path.IsIgnoredDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		wd, err := addWatch(w.fd, p, mask|syscall.IN_ONLYDIR|syscall.IN_DONT_FOLLOW)
+		if err == syscall.ENOSPC {
+			return errWatchLimit
+		}
+		if err != nil {
+			return nil
+		}
+		if relativePath == "." {
+			relativePath = ""
+		}
+		w.dirs[wd] = relativePath
+		return nil
+	})
+}
+
+// Removes the watches of a moved directory, whose watch descriptors would report outdated paths
+func (w *inotify) removeTree(relativeDir string) {
+	for wd, dir := range w.dirs {
+		if dir == relativeDir || strings.HasPrefix(dir, relativeDir+string(filepath.Separator)) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	basePath := t.TempDir()
+	os.MkdirAll(filepath.Join(basePath, "a"), 0755)
+	os.MkdirAll(filepath.Join(basePath, ".integrity"), 0755)
+	w, err := New(basePath)
+	assert.Nil(t, err)
+	defer w.Close()
+	r := &receiver{watcher: w}
+
+	os.WriteFile(filepath.Join(basePath, ".integrity", "ignored.txt"), []byte("i"), 0644)
+	os.WriteFile(filepath.Join(basePath, "a", "a1.txt"), []byte("a1"), 0644)
+	assert.Equal(t, Event{RelativePath: filepath.Join("a", "a1.txt")}, r.next(t))
+
+	// Contained paths of moved directories are reported, since they are not observed
+	tmp := t.TempDir()
+	os.MkdirAll(filepath.Join(tmp, "b"), 0755)
+	os.WriteFile(filepath.Join(tmp, "b", "b1.txt"), []byte("b1"), 0644)
+	assert.Nil(t, os.Rename(filepath.Join(tmp, "b"), filepath.Join(basePath, "b")))
+	assert.Equal(t, Event{RelativePath: "b"}, r.next(t))
+	assert.Equal(t, Event{RelativePath: filepath.Join("b", "b1.txt")}, r.next(t))
+
+	os.WriteFile(filepath.Join(basePath, "b", "b2.txt"), []byte("b2"), 0644)
+	assert.Equal(t, Event{RelativePath: filepath.Join("b", "b2.txt")}, r.next(t))
+
+	assert.Nil(t, os.Rename(filepath.Join(basePath, "b"), filepath.Join(tmp, "c")))
+	assert.Equal(t, Event{RelativePath: "b", Tree: true}, r.next(t))
+}
+
+func TestNew_watchLimit(t *testing.T) {
+	basePath := t.TempDir()
+	defer func(original func(int, string, uint32) (int, error)) { addWatch = original }(addWatch)
+	addWatch = func(int, string, uint32) (int, error) { return -1, syscall.ENOSPC }
+	_, err := New(basePath)
+	assert.Equal(t, errWatchLimit, err)
+
+	// A directory exceeding the limit later closes the events, hence the caller falls back to polling
+	addWatch = syscall.InotifyAddWatch
+	w, err := New(basePath)
+	assert.Nil(t, err)
+	defer w.Close()
+	addWatch = func(int, string, uint32) (int, error) { return -1, syscall.ENOSPC }
+	os.MkdirAll(filepath.Join(basePath, "a"), 0755)
+	r := &receiver{watcher: w}
+	assert.Equal(t, Event{RelativePath: "a"}, r.next(t))
+	select {
+	case _, ok := <-w.Events():
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("events not closed")
+	}
+}
+
+type receiver struct {
+	watcher Watcher
+	last    Event
+}
+
+// Returns the next event, repeated events of the same path are skipped
+func (r *receiver) next(t *testing.T) Event {
+	t.Helper()
+	for {
+		select {
+		case event := <-r.watcher.Events():
+			if event != r.last {
+				r.last = event
+				return event
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return Event{}
+		}
+	}
+}
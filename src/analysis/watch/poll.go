@@ -0,0 +1,43 @@
+package watch
+
+import "time"
+
+type poller struct {
+	events chan Event
+	done   chan bool
+}
+
+// Poll is the fallback for platforms or file systems without change notifications. An overflow event is
+// provided each interval, so the whole directory is rescanned.
+func Poll(interval time.Duration) Watcher {
+	p := &poller{
+		events: make(chan Event, 1),
+		done:   make(chan bool),
+	}
+	go func() {
+		defer close(p.events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				// A pending rescan covers further ticks
+				select {
+				case p.events <- Event{Overflow: true}:
+				default:
+				}
+			}
+		}
+	}()
+	return p
+}
+
+func (p *poller) Events() <-chan Event {
+	return p.events
+}
+
+func (p *poller) Close() {
+	close(p.done)
+}
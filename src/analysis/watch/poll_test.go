@@ -0,0 +1,23 @@
+package watch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoll(t *testing.T) {
+	w := Poll(10 * time.Millisecond)
+	event := <-w.Events()
+	assert.True(t, event.Overflow)
+	w.Close()
+	for range w.Events() {
+	}
+}
+
+func TestOptions_WithDefaults(t *testing.T) {
+	assert.Equal(t, Options{Debounce: defaultDebounce, Interval: defaultInterval}, Options{}.WithDefaults())
+	o := Options{Debounce: time.Second, Interval: time.Hour}
+	assert.Equal(t, o, o.WithDefaults())
+}
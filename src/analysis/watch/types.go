@@ -0,0 +1,37 @@
+package watch
+
+import "time"
+
+const (
+	defaultDebounce = 2 * time.Second
+	defaultInterval = time.Minute
+)
+
+// Event reports a changed path relative to the watched directory, the relative path is canonical
+type Event struct {
+	RelativePath string
+	Tree         bool // Removed or moved directory, hence all paths below are changed as well
+	Overflow     bool // Events were lost or are not observable, hence the whole directory requires a rescan
+}
+
+// Watcher provides the events of a directory until it is closed. The events channel is closed afterwards.
+type Watcher interface {
+	Events() <-chan Event
+	Close()
+}
+
+// Options of a watch, zero values use the defaults
+type Options struct {
+	Debounce time.Duration // Quiet period after the last event, before the changed files are processed
+	Interval time.Duration // Interval of the polling fallback and of the defragmentation
+}
+
+func (o Options) WithDefaults() Options {
+	if o.Debounce <= 0 {
+		o.Debounce = defaultDebounce
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultInterval
+	}
+	return o
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package watch
+
+import (
+	"errors"
+	"runtime"
+)
+
+// Change notifications are only supported on linux, hence Poll is used as fallback
+func New(basePath string) (Watcher, error) {
+	return nil, errors.New("change notifications are not supported on " + runtime.GOOS)
+}
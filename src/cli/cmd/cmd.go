@@ -1,11 +1,26 @@
 package cmd
 
 import (
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aicirt2012/fileintegrity"
 	"github.com/aicirt2012/fileintegrity/doc/license"
+	"github.com/aicirt2012/fileintegrity/src/config"
+	"github.com/aicirt2012/fileintegrity/src/hook"
+	"github.com/aicirt2012/fileintegrity/src/metrics"
+	"github.com/aicirt2012/fileintegrity/src/serve"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
 )
 
 func Root() *cobra.Command {
@@ -15,38 +30,157 @@ func Root() *cobra.Command {
 		Version: fileintegrity.Version,
 	}
 	cmd.AddCommand(upsert())
+	cmd.AddCommand(watch())
 	cmd.AddCommand(verify())
 	cmd.AddCommand(check())
+	cmd.AddCommand(diff())
+	cmd.AddCommand(sync())
+	cmd.AddCommand(cp())
+	cmd.AddCommand(repair())
+	cmd.AddCommand(fsck())
+	cmd.AddCommand(daemon())
+	cmd.AddCommand(configure())
+	cmd.AddCommand(gc())
+	cmd.AddCommand(backup())
 	cmd.AddCommand(licenseTxt())
 	return cmd
 }
 
 func upsert() *cobra.Command {
-	var quiet bool
+	var wait time.Duration
+	var quiet, backup, appendOnly bool
+	var redundancy int
+	var chunkSize string
+	var ioStrategy string
+	var symlinks string
+	var metadata bool
+	var xattrs []string
+	var limits throttleFlags
+	var reports reportFlags
 	var cmd = &cobra.Command{
 		Use:   `upsert <dir>`,
 		Short: `Upsert integrity`,
 		Long:  `Creates or updated integrity file if needed`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			fileintegrity.Upsert(args[0], options(&quiet))
+			o := options(&quiet)
+			o.Backup = backup
+			o.LockWait = wait
+			o.Redundancy = redundancy
+			o.ChunkSize = parseBytes(cmd, chunkSize)
+			o.AppendOnly = appendOnly
+			o.IOStrategy = ioStrategy
+			o.Symlinks = symlinks
+			o.Metadata = metadata
+			o.Xattrs = xattrs
+			limits.apply(cmd, &o)
+			o.Config = loadConfig(cmd, args[0])
+			reports.run(cmd, args[0], &o, func() error {
+				return fileintegrity.Upsert(args[0], o)
+			})
+		},
+	}
+	cmd.Flags().IntVarP(&redundancy, "redundancy", "r", 0, "percentage of reed-solomon recovery data for new and updated files")
+	cmd.Flags().StringVarP(&chunkSize, "chunk-size", "c", "0", "size of chunk hashes for files larger than the chunk size, e.g. 16MiB")
+	cmd.Flags().BoolVar(&appendOnly, "append-only", false, "hash only the tail of grown files with chunk hashes, changes before the tail are not detected")
+	cmd.Flags().StringVar(&symlinks, "symlinks", "", "symlink policy: ignore, record link targets or follow links, defaults to record")
+	cmd.Flags().BoolVarP(&metadata, "metadata", "m", false, "record mode bits and ownership of files")
+	cmd.Flags().StringSliceVar(&xattrs, "xattrs", nil, "extended attributes recorded with the metadata, e.g. system.posix_acl_access")
+	addIOFlag(cmd, &ioStrategy)
+	addThrottleFlags(cmd, &limits)
+	addReportFlags(cmd, &reports)
+	addBackupFlag(cmd, &backup)
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func watch() *cobra.Command {
+	var wait time.Duration
+	var quiet, appendOnly bool
+	var redundancy int
+	var chunkSize string
+	var ioStrategy string
+	var symlinks string
+	var metadata bool
+	var xattrs []string
+	var debounce time.Duration
+	var interval time.Duration
+	var limits throttleFlags
+	var cmd = &cobra.Command{
+		Use:   `watch <dir>`,
+		Short: `Watch integrity`,
+		Long:  `Upserts integrity file continuously on changes until interrupted`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.ProgressBar = false
+			o.LockWait = wait
+			o.Redundancy = redundancy
+			o.ChunkSize = parseBytes(cmd, chunkSize)
+			o.AppendOnly = appendOnly
+			o.IOStrategy = ioStrategy
+			o.Symlinks = symlinks
+			o.Metadata = metadata
+			o.Xattrs = xattrs
+			o.Debounce = debounce
+			o.Interval = interval
+			limits.apply(cmd, &o)
+			o.Config = loadConfig(cmd, args[0])
+			stop := make(chan bool)
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-signals
+				close(stop)
+			}()
+			if err := fileintegrity.Watch(args[0], stop, o); err != nil {
+				cmd.PrintErrln(err)
+				os.Exit(1)
+			}
 		},
 	}
+	cmd.Flags().IntVarP(&redundancy, "redundancy", "r", 0, "percentage of reed-solomon recovery data for new and updated files")
+	cmd.Flags().StringVarP(&chunkSize, "chunk-size", "c", "0", "size of chunk hashes for files larger than the chunk size, e.g. 16MiB")
+	cmd.Flags().BoolVar(&appendOnly, "append-only", false, "hash only the tail of grown files with chunk hashes, changes before the tail are not detected")
+	cmd.Flags().StringVar(&symlinks, "symlinks", "", "symlink policy: ignore, record link targets or follow links, defaults to record")
+	cmd.Flags().BoolVarP(&metadata, "metadata", "m", false, "record mode bits and ownership of files")
+	cmd.Flags().StringSliceVar(&xattrs, "xattrs", nil, "extended attributes recorded with the metadata, e.g. system.posix_acl_access")
+	cmd.Flags().DurationVar(&debounce, "debounce", 2*time.Second, "quiet period before changed files are hashed")
+	cmd.Flags().DurationVar(&interval, "interval", time.Minute, "defragmentation interval and polling interval without change notifications")
+	addIOFlag(cmd, &ioStrategy)
+	addThrottleFlags(cmd, &limits)
+	addWaitFlag(cmd, &wait)
 	addQuietFlag(cmd, &quiet)
 	return cmd
 }
 
 func verify() *cobra.Command {
+	var wait time.Duration
 	var quiet bool
+	var ioStrategy string
+	var limits throttleFlags
+	var reports reportFlags
 	var cmd = &cobra.Command{
 		Use:   `verify <dir>`,
 		Short: `Verify integrity`,
 		Long:  `Verify integrity file if exist`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			fileintegrity.Verify(args[0], options(&quiet))
+			o := options(&quiet)
+			o.LockWait = wait
+			o.IOStrategy = ioStrategy
+			limits.apply(cmd, &o)
+			o.Config = loadConfig(cmd, args[0])
+			reports.run(cmd, args[0], &o, func() error {
+				return fileintegrity.Verify(args[0], o)
+			})
 		},
 	}
+	addIOFlag(cmd, &ioStrategy)
+	addThrottleFlags(cmd, &limits)
+	addReportFlags(cmd, &reports)
+	addWaitFlag(cmd, &wait)
 	addQuietFlag(cmd, &quiet)
 	return cmd
 }
@@ -65,21 +199,30 @@ func check() *cobra.Command {
 }
 
 func checkDuplicates() *cobra.Command {
+	var wait time.Duration
 	var quiet bool
+	var reports reportFlags
 	var cmd = &cobra.Command{
 		Use:   `duplicates <dir>`,
 		Short: `Check duplicates`,
 		Long:  `Check duplicates within integrity file`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			fileintegrity.CheckDuplicates(args[0], options(&quiet))
+			o := options(&quiet)
+			o.LockWait = wait
+			reports.run(cmd, args[0], &o, func() error {
+				return fileintegrity.CheckDuplicates(args[0], o)
+			})
 		},
 	}
+	addReportFlags(cmd, &reports)
+	addWaitFlag(cmd, &wait)
 	addQuietFlag(cmd, &quiet)
 	return cmd
 }
 
 func checkContains() *cobra.Command {
+	var wait time.Duration
 	var quiet, fix bool
 	var cmd = &cobra.Command{
 		Use:   `contains <dir> <externalDir>`,
@@ -87,30 +230,42 @@ func checkContains() *cobra.Command {
 		Long:  `Check contains within integrity file`,
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			fileintegrity.CheckContained(args[0], args[1], fix, options(&quiet))
+			o := options(&quiet)
+			o.LockWait = wait
+			exitOnError(cmd, fileintegrity.CheckContained(args[0], args[1], fix, o))
 		},
 	}
 	cmd.Flags().BoolVarP(&fix, "fix", "f", false, "delete contained and duplicate files within the external directory")
+	addWaitFlag(cmd, &wait)
 	addQuietFlag(cmd, &quiet)
 	return cmd
 }
 
 func checkStyleIssue() *cobra.Command {
+	var wait time.Duration
 	var quiet bool
+	var reports reportFlags
 	var cmd = &cobra.Command{
 		Use:   `style <dir>`,
 		Short: `Check style issues`,
 		Long:  `Check style issues within integrity file`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			fileintegrity.CheckStyleIssues(args[0], options(&quiet))
+			o := options(&quiet)
+			o.LockWait = wait
+			reports.run(cmd, args[0], &o, func() error {
+				return fileintegrity.CheckStyleIssues(args[0], o)
+			})
 		},
 	}
+	addHookFlags(cmd, &reports.hooks, &reports.webhooks)
+	addWaitFlag(cmd, &wait)
 	addQuietFlag(cmd, &quiet)
 	return cmd
 }
 
 func checkExtStats() *cobra.Command {
+	var wait time.Duration
 	var quiet bool
 	var cmd = &cobra.Command{
 		Use:   `ext-stats <dir>`,
@@ -118,9 +273,307 @@ func checkExtStats() *cobra.Command {
 		Long:  `Check ext-stats within integrity file`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			fileintegrity.CheckExtensionStats(args[0], options(&quiet))
+			o := options(&quiet)
+			o.LockWait = wait
+			exitOnError(cmd, fileintegrity.CheckExtensionStats(args[0], o))
+		},
+	}
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func diff() *cobra.Command {
+	var wait time.Duration
+	var quiet bool
+	var cmd = &cobra.Command{
+		Use:   `diff <dirA> <dirB>`,
+		Short: `Diff integrity`,
+		Long:  `Compares the integrity files of two directories`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.LockWait = wait
+			exitOnError(cmd, fileintegrity.Diff(args[0], args[1], o))
+		},
+	}
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func sync() *cobra.Command {
+	var wait time.Duration
+	var quiet, backup, deletions, moves bool
+	var cmd = &cobra.Command{
+		Use:   `sync <source> <target>`,
+		Short: `Sync integrity`,
+		Long:  `Mirrors missing and modified files of the source into the target directory`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.Backup = backup
+			o.LockWait = wait
+			exitOnError(cmd, fileintegrity.Sync(args[0], args[1], deletions, moves, o))
+		},
+	}
+	cmd.Flags().BoolVarP(&deletions, "delete", "d", false, "delete files within the target directory that do not exist in the source")
+	cmd.Flags().BoolVarP(&moves, "move", "m", false, "move files within the target directory that were moved in the source")
+	addBackupFlag(cmd, &backup)
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func cp() *cobra.Command {
+	var wait time.Duration
+	var quiet, backup bool
+	var cmd = &cobra.Command{
+		Use:   `cp <src-dir> <relpath-or-glob> <dest-dir>`,
+		Short: `Copy verified`,
+		Long:  `Copies matching files verified and appends their entries to the destination integrity file`,
+		Args:  cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.Backup = backup
+			o.LockWait = wait
+			exitOnError(cmd, fileintegrity.Copy(args[0], args[1], args[2], o))
+		},
+	}
+	addBackupFlag(cmd, &backup)
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func repair() *cobra.Command {
+	var wait time.Duration
+	var quiet, backup bool
+	var replicas []string
+	var ioStrategy string
+	var cmd = &cobra.Command{
+		Use:   `repair <dir> [--from <replicaDir> ...]`,
+		Short: `Repair integrity`,
+		Long:  `Replaces invalid files by a verified reconstruction based on recovery data or a copy of a replica`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.Backup = backup
+			o.LockWait = wait
+			o.IOStrategy = ioStrategy
+			o.Config = loadConfig(cmd, args[0])
+			exitOnError(cmd, fileintegrity.Repair(args[0], replicas, o))
+		},
+	}
+	cmd.Flags().StringArrayVarP(&replicas, "from", "f", []string{}, "replica directory to restore invalid files from")
+	addIOFlag(cmd, &ioStrategy)
+	addBackupFlag(cmd, &backup)
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func fsck() *cobra.Command {
+	var quiet, repair bool
+	var wait time.Duration
+	var reports reportFlags
+	var cmd = &cobra.Command{
+		Use:   `fsck <dir> [--repair]`,
+		Short: `Check integrity file`,
+		Long:  `Checks the consistency of the integrity file and salvages the valid entries with the repair flag`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.LockWait = wait
+			reports.run(cmd, args[0], &o, func() error {
+				return fileintegrity.Fsck(args[0], repair, o)
+			})
 		},
 	}
+	cmd.Flags().BoolVar(&repair, "repair", false, "salvage the valid entries into a new integrity file")
+	addHookFlags(cmd, &reports.hooks, &reports.webhooks)
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func daemon() *cobra.Command {
+	var addr string
+	var token string
+	var upsertInterval, verifyInterval, checksInterval time.Duration
+	var ioStrategy string
+	var symlinks string
+	var metadata bool
+	var xattrs []string
+	var hooks, webhooks []string
+	var cmd = &cobra.Command{
+		Use:   `serve [<dir>...]`,
+		Short: `Serve integrity API`,
+		Long:  `Runs scheduled and requested jobs of registered directories and serves a local JSON API`,
+		Run: func(cmd *cobra.Command, args []string) {
+			o := fileintegrity.DefaultOptions()
+			o.IOStrategy = ioStrategy
+			o.Symlinks = symlinks
+			o.Metadata = metadata
+			o.Xattrs = xattrs
+			exitOnError(cmd, o.Validate())
+			server := serve.New(o, parseHooks(cmd, hooks, webhooks))
+			schedule := serve.Schedule{}
+			for kind, interval := range map[serve.Kind]time.Duration{
+				serve.UPSERT:     upsertInterval,
+				serve.VERIFY:     verifyInterval,
+				serve.DUPLICATES: checksInterval,
+				serve.STYLE:      checksInterval,
+				serve.EXTENSIONS: checksInterval,
+			} {
+				if interval > 0 {
+					schedule[kind] = interval
+				}
+			}
+			for _, dir := range args {
+				if err := server.Register(dir, schedule); err != nil {
+					cmd.PrintErrln(err)
+					os.Exit(1)
+				}
+			}
+			stop := make(chan bool)
+			go server.Schedule(stop)
+			if token == "" {
+				token = os.Getenv("FILEINTEGRITY_TOKEN")
+			}
+			httpServer := &http.Server{Addr: addr, Handler: server.Handler(token)}
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-signals
+				close(stop)
+				httpServer.Close()
+			}()
+			if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+				cmd.PrintErrln(err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8750", "listen address of the api, loopback only by default")
+	cmd.Flags().StringVar(&token, "token", "", "bearer token required by the api, defaults to $FILEINTEGRITY_TOKEN")
+	cmd.Flags().DurationVar(&upsertInterval, "upsert", 0, "upsert interval of the given directories, zero disables the schedule")
+	cmd.Flags().DurationVar(&verifyInterval, "verify", 0, "verify interval of the given directories, zero disables the schedule")
+	cmd.Flags().DurationVar(&checksInterval, "checks", 0, "interval of the duplicates, style and extension checks, zero disables the schedule")
+	cmd.Flags().StringVar(&symlinks, "symlinks", "", "symlink policy: ignore, record link targets or follow links, defaults to record")
+	cmd.Flags().BoolVarP(&metadata, "metadata", "m", false, "record mode bits and ownership of files")
+	cmd.Flags().StringSliceVar(&xattrs, "xattrs", nil, "extended attributes recorded with the metadata, e.g. system.posix_acl_access")
+	addIOFlag(cmd, &ioStrategy)
+	addHookFlags(cmd, &hooks, &webhooks)
+	return cmd
+}
+
+func configure() *cobra.Command {
+	var initialize bool
+	var cmd = &cobra.Command{
+		Use:   `config <dir>`,
+		Short: `Show config`,
+		Long:  `Shows the effective config of the directory stored within .integrity/config`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir.AssertDir(args[0])
+			c := loadConfig(cmd, args[0])
+			if initialize {
+				if _, err := os.Stat(config.Filename(args[0])); err == nil {
+					cmd.PrintErrln("config already exists: " + config.Filename(args[0]))
+					os.Exit(1)
+				}
+				dir.UpsertIntegrityDir(args[0])
+				exitOnError(cmd, c.Save(args[0]))
+			}
+			content, err := yaml.Marshal(c)
+			exitOnError(cmd, err)
+			// The config is printed on stdout, so it can be redirected into a file
+			fmt.Fprint(cmd.OutOrStdout(), string(content))
+		},
+	}
+	cmd.Flags().BoolVar(&initialize, "init", false, "write the default config, if no config exists")
+	return cmd
+}
+
+func gc() *cobra.Command {
+	var wait time.Duration
+	var quiet, dryRun bool
+	var cmd = &cobra.Command{
+		Use:   `gc <dir>`,
+		Short: `Remove expired logs and backups`,
+		Long:  `Removes logs and backups within the integrity directory according to the retention of the config`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.LockWait = wait
+			exitOnError(cmd, fileintegrity.GC(args[0], dryRun, o))
+		},
+	}
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "only report expired logs and backups")
+	addWaitFlag(cmd, &wait)
+	addQuietFlag(cmd, &quiet)
+	return cmd
+}
+
+func backup() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   `backup`,
+		Short: `Manage integrity backups`,
+		Long:  `Lists, compares and restores the backups of the integrity file`,
+	}
+	cmd.AddCommand(backupList())
+	cmd.AddCommand(backupRestore())
+	cmd.AddCommand(backupDiff())
+	return cmd
+}
+
+func backupList() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   `list <dir>`,
+		Short: `List backups`,
+		Long:  `Lists the backups of the integrity file with their timestamp`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			backups, err := fileintegrity.Backups(args[0])
+			exitOnError(cmd, err)
+			for _, b := range backups {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s  %s  %s\n", b.Timestamp, b.Modified.Format(time.DateTime), humanize.Bytes(uint64(b.Size)))
+			}
+		},
+	}
+	return cmd
+}
+
+func backupRestore() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   `restore <dir> <timestamp>`,
+		Short: `Restore backup`,
+		Long:  `Replaces the integrity file by a validated backup, the current integrity file is backed up before`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			exitOnError(cmd, fileintegrity.RestoreBackup(args[0], args[1]))
+			cmd.Println("restored backup " + args[1])
+		},
+	}
+	return cmd
+}
+
+func backupDiff() *cobra.Command {
+	var wait time.Duration
+	var quiet bool
+	var cmd = &cobra.Command{
+		Use:   `diff <dir> <timestamp>`,
+		Short: `Diff backup`,
+		Long:  `Compares a backup (A) with the current integrity file (B)`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			o := options(&quiet)
+			o.LockWait = wait
+			exitOnError(cmd, fileintegrity.DiffBackup(args[0], args[1], o))
+		},
+	}
+	addWaitFlag(cmd, &wait)
 	addQuietFlag(cmd, &quiet)
 	return cmd
 }
@@ -146,6 +599,157 @@ func addQuietFlag(cmd *cobra.Command, p *bool) {
 	cmd.Flags().BoolVarP(p, "quiet", "q", false, "enable quiet mode")
 }
 
+func addBackupFlag(cmd *cobra.Command, p *bool) {
+	cmd.Flags().BoolVar(p, "backup", false, "back up the integrity file as zip before it is changed")
+}
+
+func addWaitFlag(cmd *cobra.Command, p *time.Duration) {
+	cmd.Flags().DurationVar(p, "wait", 0, "duration to wait for a lock of another process on the directory, e.g. 10m")
+}
+
+func addIOFlag(cmd *cobra.Command, p *string) {
+	cmd.Flags().StringVar(p, "io", "", "io strategy: auto, sequential for hdds or parallel for ssds, defaults to auto")
+}
+
+type reportFlags struct {
+	metricsFile string
+	hooks       []string
+	webhooks    []string
+}
+
+func addReportFlags(cmd *cobra.Command, f *reportFlags) {
+	cmd.Flags().StringVar(&f.metricsFile, "metrics-file", "", "prometheus textfile updated with the summary, e.g. /var/lib/node_exporter/fileintegrity.prom")
+	addHookFlags(cmd, &f.hooks, &f.webhooks)
+}
+
+func addHookFlags(cmd *cobra.Command, hooks *[]string, webhooks *[]string) {
+	cmd.Flags().StringArrayVar(hooks, "hook", nil, "command run with the summary as json on stdin on an event: finished, failed, invalid, duplicates or style, e.g. invalid='mail -s integrity root'")
+	cmd.Flags().StringArrayVar(webhooks, "webhook", nil, "url the summary is posted to as json on an event, e.g. finished=https://example.com/hook")
+}
+
+// Runs the command, updates the metrics of the directory within the textfile and runs the hooks afterwards.
+// A failed command runs the hooks of the failed event and exits.
+func (f reportFlags) run(cmd *cobra.Command, dir string, o *fileintegrity.Options, run func() error) {
+	hooks := parseHooks(cmd, f.hooks, f.webhooks)
+	var registry *metrics.Registry
+	observers := ilog.Observers{}
+	if f.metricsFile != "" {
+		registry = metrics.NewRegistry()
+		if err := registry.Load(f.metricsFile); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		observers = append(observers, registry.Collector(dir))
+	}
+	notifier := hook.NewNotifier(hooks, dir)
+	if len(hooks) > 0 {
+		observers = append(observers, notifier)
+	}
+	if len(observers) > 0 {
+		o.Observer = observers
+	}
+	if err := run(); err != nil {
+		if hookErr := notifier.Fail(cmd.Name(), err); hookErr != nil {
+			cmd.PrintErrln(hookErr)
+		}
+		exitOnError(cmd, err)
+	}
+	if registry != nil {
+		if err := registry.WriteFile(f.metricsFile); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+	}
+	if err := notifier.Notify(); err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+}
+
+func parseHooks(cmd *cobra.Command, commands []string, webhooks []string) []hook.Hook {
+	hooks := []hook.Hook{}
+	for _, value := range commands {
+		h, err := hook.ParseCommand(value)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		hooks = append(hooks, h)
+	}
+	for _, value := range webhooks {
+		h, err := hook.ParseWebhook(value)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks
+}
+
+type throttleFlags struct {
+	rate       string
+	workers    int
+	nice       int
+	idleIO     bool
+	bufferSize string
+}
+
+func addThrottleFlags(cmd *cobra.Command, f *throttleFlags) {
+	cmd.Flags().StringVar(&f.rate, "rate", "0", "read throughput limit per second, e.g. 50MB")
+	cmd.Flags().IntVar(&f.workers, "workers", 0, "maximum number of concurrent file readers")
+	cmd.Flags().IntVar(&f.nice, "nice", 0, "process niceness between 1 and 19")
+	cmd.Flags().BoolVar(&f.idleIO, "idle-io", false, "idle io scheduling class, linux only")
+	cmd.Flags().StringVar(&f.bufferSize, "buffer-size", "0", "read buffer size per file reader, e.g. 8MiB, defaults to 30MiB")
+}
+
+func (f throttleFlags) apply(cmd *cobra.Command, o *fileintegrity.Options) {
+	o.ReadRate = parseBytes(cmd, f.rate)
+	o.Workers = f.workers
+	o.Nice = f.nice
+	o.IdleIO = f.idleIO
+	o.BufferSize = parseBytes(cmd, f.bufferSize)
+}
+
+// Loads the config of the directory and overrides its settings by the flags set on the command line
+func loadConfig(cmd *cobra.Command, dir string) *fileintegrity.Config {
+	c, err := fileintegrity.LoadConfig(dir)
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		value := f.Value.String()
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			value = strings.Join(slice.GetSlice(), ",")
+		}
+		if err == nil {
+			err = c.Set(f.Name, value)
+		}
+	})
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+	return c
+}
+
+func exitOnError(cmd *cobra.Command, err error) {
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+}
+
+func parseBytes(cmd *cobra.Command, value string) int64 {
+	bytes, err := humanize.ParseBytes(value)
+	if err != nil {
+		cmd.PrintErr(err)
+		os.Exit(1)
+	}
+	return int64(bytes)
+}
+
 func options(quiet *bool) fileintegrity.Options {
 	return fileintegrity.LogOptions(quiet)
 }
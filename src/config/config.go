@@ -0,0 +1,225 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/device"
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/dustin/go-humanize"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Name    = "config" // Filename within the integrity directory
+	Version = 1        // Current version of the config format
+)
+
+// Config contains the settings of an archive, which are stored within .integrity/config. Settings of the
+// command line take precedence over the config.
+type Config struct {
+	Version    int        `yaml:"version"`
+	Hash       string     `yaml:"hash"`   // Hash algorithm sha256, sha384 or sha512, fixed once the integrity file exists
+	Ignore     []string   `yaml:"ignore"` // Glob patterns of files and directories which are not walked
+	Upsert     Upsert     `yaml:"upsert"`
+	IO         IO         `yaml:"io"`
+	Style      Style      `yaml:"style"`
+	Duplicates Duplicates `yaml:"duplicates"`
+	Log        Log        `yaml:"log"`
+	Retention  Retention  `yaml:"retention"`
+}
+
+type Upsert struct {
+	Redundancy int      `yaml:"redundancy"` // Percentage of recovery data, zero disables the creation
+	ChunkSize  Bytes    `yaml:"chunkSize"`  // Size of chunk hashes of larger files, zero disables the creation
+	AppendOnly bool     `yaml:"appendOnly"` // Grown files with chunk hashes are resumed, only the tail is hashed
+	Symlinks   string   `yaml:"symlinks"`   // Symlink policy ignore, record or follow
+	Metadata   bool     `yaml:"metadata"`   // Captures mode bits and ownership of files
+	Xattrs     []string `yaml:"xattrs"`     // Extended attributes captured with the metadata
+}
+
+type IO struct {
+	Strategy   string `yaml:"strategy"`   // I/O strategy auto, sequential or parallel
+	Rate       Bytes  `yaml:"rate"`       // Read throughput limit per second, zero disables the limit
+	Workers    int    `yaml:"workers"`    // Maximum number of concurrent file readers, zero uses the strategy default
+	BufferSize Bytes  `yaml:"bufferSize"` // Read buffer size per file reader, zero defaults to 30MiB
+}
+
+type Style struct {
+	MaxPathLength int `yaml:"maxPathLength"` // Maximum length of relative paths
+	MaxDirLength  int `yaml:"maxDirLength"`  // Maximum length of directory names
+}
+
+type Duplicates struct {
+	IgnoreSize Bytes `yaml:"ignoreSize"` // Files up to this size are not checked for duplicates
+}
+
+type Log struct {
+	FlushSize int `yaml:"flushSize"` // Number of buffered log entries, zero keeps the default of the command
+}
+
+// Retention of the logs and the backups within the integrity directory, by default all files are kept
+type Retention struct {
+	Logs    Policy `yaml:"logs"` // Applied to the logs of each command separately
+	Backups Policy `yaml:"backups"`
+}
+
+type Policy struct {
+	KeepLast    int   `yaml:"keepLast"`    // Number of newest files
+	KeepDaily   int   `yaml:"keepDaily"`   // Number of days, the newest file of each day is kept
+	KeepWeekly  int   `yaml:"keepWeekly"`  // Number of weeks, the newest file of each week is kept
+	KeepMonthly int   `yaml:"keepMonthly"` // Number of months, the newest file of each month is kept
+	MaxSize     Bytes `yaml:"maxSize"`     // Total size of the kept files, zero disables the limit
+}
+
+func (p Policy) valid() bool {
+	return p.KeepLast >= 0 && p.KeepDaily >= 0 && p.KeepWeekly >= 0 && p.KeepMonthly >= 0
+}
+
+// Bytes is a size in bytes, which is written human-readable, e.g. 16MiB
+type Bytes int64
+
+func (b Bytes) MarshalYAML() (any, error) {
+	return humanize.IBytes(uint64(b)), nil
+}
+
+func (b *Bytes) UnmarshalYAML(value *yaml.Node) error {
+	bytes, err := humanize.ParseBytes(value.Value)
+	if err != nil {
+		return fmt.Errorf("invalid size %q in line %d", value.Value, value.Line)
+	}
+	*b = Bytes(bytes)
+	return nil
+}
+
+// Default returns the config used without config file
+func Default() *Config {
+	return &Config{
+		Version: Version,
+		Hash:    string(digest.SHA256),
+		Ignore:  []string{},
+		Upsert: Upsert{
+			Symlinks: string(path.RECORD),
+			Xattrs:   []string{},
+		},
+		IO: IO{
+			Strategy: string(device.AUTO),
+		},
+		Style: Style{
+			MaxPathLength: 260,
+			MaxDirLength:  60,
+		},
+		Duplicates: Duplicates{
+			IgnoreSize: 100,
+		},
+	}
+}
+
+// Filename returns the path of the config within the integrity directory
+func Filename(basePath string) string {
+	return filepath.Join(basePath, dir.Name, Name)
+}
+
+// Load reads the config of the directory, settings missing in the file keep their default.
+// Without config file the default config is returned.
+func Load(basePath string) (*Config, error) {
+	config := Default()
+	content, err := os.ReadFile(Filename(basePath))
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", Filename(basePath), err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", Filename(basePath), err)
+	}
+	return config, nil
+}
+
+// Save writes the config into the integrity directory, which must exist
+func (c *Config) Save(basePath string) error {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return dir.WriteFile(Filename(basePath), content)
+}
+
+// Validate checks the version and the values of the settings
+func (c *Config) Validate() error {
+	if c.Version > Version {
+		return fmt.Errorf("unsupported version %d, supported up to %d", c.Version, Version)
+	}
+	if _, err := digest.Parse(c.Hash); err != nil {
+		return err
+	}
+	if _, err := path.ParseIgnore(c.Ignore); err != nil {
+		return err
+	}
+	if _, err := path.ParseSymlinks(c.Upsert.Symlinks); err != nil {
+		return err
+	}
+	if _, err := device.Parse(c.IO.Strategy); err != nil {
+		return err
+	}
+	if c.Upsert.Redundancy < 0 || c.IO.Workers < 0 || c.Log.FlushSize < 0 || !c.Retention.Logs.valid() || !c.Retention.Backups.valid() {
+		return errors.New("negative values are not supported")
+	}
+	if c.Style.MaxPathLength <= 0 || c.Style.MaxDirLength <= 0 {
+		return errors.New("style limits must be positive")
+	}
+	return nil
+}
+
+// Set overrides a setting by the value of the command line flag with the same name. Unknown flags are ignored.
+func (c *Config) Set(flag string, value string) error {
+	var err error
+	switch flag {
+	case "redundancy":
+		c.Upsert.Redundancy, err = strconv.Atoi(value)
+	case "chunk-size":
+		err = c.Upsert.ChunkSize.set(value)
+	case "append-only":
+		c.Upsert.AppendOnly, err = strconv.ParseBool(value)
+	case "symlinks":
+		c.Upsert.Symlinks = value
+	case "metadata":
+		c.Upsert.Metadata, err = strconv.ParseBool(value)
+	case "xattrs":
+		c.Upsert.Xattrs = split(value)
+	case "io":
+		c.IO.Strategy = value
+	case "rate":
+		err = c.IO.Rate.set(value)
+	case "workers":
+		c.IO.Workers, err = strconv.Atoi(value)
+	case "buffer-size":
+		err = c.IO.BufferSize.set(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q of %s", value, flag)
+	}
+	return c.Validate()
+}
+
+func (b *Bytes) set(value string) error {
+	bytes, err := humanize.ParseBytes(value)
+	*b = Bytes(bytes)
+	return err
+}
+
+func split(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad_default(t *testing.T) {
+	config, err := Load(t.TempDir())
+	assert.Nil(t, err)
+	assert.Equal(t, Default(), config)
+}
+
+func TestLoad(t *testing.T) {
+	basePath := writeConfig(t, `
+version: 1
+hash: sha512
+ignore: ["*.tmp", "node_modules/"]
+upsert:
+  chunkSize: 16MiB
+style:
+  maxPathLength: 200
+duplicates:
+  ignoreSize: 1KiB
+retention:
+  logs:
+    keepDaily: 7
+    maxSize: 10MB
+`)
+	config, err := Load(basePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "sha512", config.Hash)
+	assert.Equal(t, []string{"*.tmp", "node_modules/"}, config.Ignore)
+	assert.Equal(t, Bytes(16*1024*1024), config.Upsert.ChunkSize)
+	assert.Equal(t, "record", config.Upsert.Symlinks)
+	assert.Equal(t, 200, config.Style.MaxPathLength)
+	assert.Equal(t, 60, config.Style.MaxDirLength)
+	assert.Equal(t, Bytes(1024), config.Duplicates.IgnoreSize)
+	assert.Equal(t, Policy{KeepDaily: 7, MaxSize: 10 * 1000 * 1000}, config.Retention.Logs)
+	assert.Equal(t, Policy{}, config.Retention.Backups)
+}
+
+func TestLoad_invalid(t *testing.T) {
+	for _, content := range []string{
+		"version: 2",
+		"hash: md5",
+		"ignore: ['[']",
+		"upsert:\n  chunkSize: large",
+		"io:\n  strategy: fast",
+		"style:\n  maxDirLength: 0",
+		"retention:\n  backups:\n    keepLast: -1",
+		"version: [",
+	} {
+		_, err := Load(writeConfig(t, content))
+		assert.NotNil(t, err, content)
+	}
+}
+
+func TestSave(t *testing.T) {
+	basePath := writeConfig(t, "")
+	config := Default()
+	config.Upsert.ChunkSize = 4 * 1024 * 1024
+	config.Ignore = []string{"*.bak"}
+	assert.Nil(t, config.Save(basePath))
+	loaded, err := Load(basePath)
+	assert.Nil(t, err)
+	assert.Equal(t, config, loaded)
+}
+
+func TestSet(t *testing.T) {
+	config := Default()
+	assert.Nil(t, config.Set("redundancy", "10"))
+	assert.Nil(t, config.Set("chunk-size", "1MB"))
+	assert.Nil(t, config.Set("xattrs", "user.a,user.b"))
+	assert.Nil(t, config.Set("buffer-size", "4MiB"))
+	assert.Nil(t, config.Set("append-only", "true"))
+	assert.Nil(t, config.Set("quiet", "true"))
+	assert.Equal(t, 10, config.Upsert.Redundancy)
+	assert.Equal(t, Bytes(1000*1000), config.Upsert.ChunkSize)
+	assert.Equal(t, []string{"user.a", "user.b"}, config.Upsert.Xattrs)
+	assert.Equal(t, Bytes(4*1024*1024), config.IO.BufferSize)
+	assert.True(t, config.Upsert.AppendOnly)
+	assert.NotNil(t, config.Set("workers", "many"))
+	assert.NotNil(t, config.Set("symlinks", "skip"))
+}
+
+func writeConfig(t *testing.T, content string) string {
+	basePath := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(basePath, dir.Name), 0755))
+	assert.Nil(t, os.WriteFile(Filename(basePath), []byte(content), 0644))
+	return basePath
+}
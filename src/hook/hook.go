@@ -0,0 +1,198 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+const timeout = time.Minute
+
+// Events returns the events triggered by a summary, other entries trigger no event. Invalid files and style
+// issues trigger their event on every execution, duplicates only if a new duplicate group was found.
+func Events(summary any) []Event {
+	if !ilog.IsSummary(summary) {
+		return nil
+	}
+	triggered := []Event{FINISHED}
+	switch s := summary.(type) {
+	case ilog.VerifySummary:
+		if s.InvalidFiles > 0 {
+			triggered = append(triggered, INVALID)
+		}
+	case ilog.DuplicateSummary:
+		if s.NewDuplicateGroups > 0 {
+			triggered = append(triggered, DUPLICATES)
+		}
+	case ilog.FsckSummary:
+		if s.Issues() > 0 {
+			triggered = append(triggered, INVALID)
+		}
+	case ilog.StyleSummary:
+		if s.HierarchyIssues+s.NamingIssues+s.LengthIssues+s.PortabilityIssues > 0 {
+			triggered = append(triggered, STYLE)
+		}
+	}
+	return triggered
+}
+
+// Run executes the hooks of the events triggered by the summary. All hooks are executed, even if a hook fails.
+func Run(hooks []Hook, path string, summary any) error {
+	var errs []error
+	finished := time.Now()
+	for _, event := range Events(summary) {
+		payload := Payload{
+			Event:    event,
+			Kind:     kindOf(summary),
+			Path:     path,
+			Finished: finished,
+			Summary:  summary,
+		}
+		if err := notify(hooks, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Failed executes the hooks of the failed event for an execution of the kind, which failed without summary
+func Failed(hooks []Hook, path string, kind string, failure error) error {
+	payload := Payload{
+		Event:    FAILED,
+		Kind:     kind,
+		Path:     path,
+		Finished: time.Now(),
+		Error:    failure.Error(),
+	}
+	return notify(hooks, payload)
+}
+
+func notify(hooks []Hook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, hook := range hooks {
+		if hook.Event != payload.Event {
+			continue
+		}
+		if hook.Command != "" {
+			err = runCommand(hook.Command, payload, body)
+		} else {
+			err = post(hook.URL, body)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s hook failed: %w", payload.Event, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runCommand(command string, payload Payload, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "FILEINTEGRITY_EVENT="+string(payload.Event), "FILEINTEGRITY_PATH="+payload.Path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func post(url string, body []byte) error {
+	client := http.Client{Timeout: timeout}
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New("webhook responded with " + response.Status)
+	}
+	return nil
+}
+
+func kindOf(summary any) string {
+	switch summary.(type) {
+	case ilog.UpsertSummary:
+		return "upsert"
+	case ilog.VerifySummary:
+		return "verify"
+	case ilog.DuplicateSummary:
+		return "duplicates"
+	case ilog.StyleSummary:
+		return "style"
+	case ilog.ContainedSummary:
+		return "contains"
+	case ilog.ExtensionStatsSummary:
+		return "extensions"
+	case ilog.DiffSummary:
+		return "diff"
+	case ilog.SyncSummary:
+		return "sync"
+	case ilog.CopySummary:
+		return "cp"
+	case ilog.RepairSummary:
+		return "repair"
+	case ilog.WatchSummary:
+		return "watch"
+	case ilog.RetentionSummary:
+		return "gc"
+	case ilog.FsckSummary:
+		return "fsck"
+	}
+	return ""
+}
+
+// Notifier observes an execution and keeps its summary, so the hooks are run once the execution is finished
+type Notifier struct {
+	hooks   []Hook
+	path    string
+	summary any
+}
+
+func NewNotifier(hooks []Hook, path string) *Notifier {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &Notifier{hooks: hooks, path: path}
+}
+
+func (n *Notifier) Entry(entry any) {
+	if ilog.IsSummary(entry) {
+		n.summary = entry
+	}
+}
+
+func (n *Notifier) Progress(done int64, total int64) {}
+
+// Notify runs the hooks of the observed summary, nothing is run without a summary
+func (n *Notifier) Notify() error {
+	if n.summary == nil {
+		return nil
+	}
+	return Run(n.hooks, n.path, n.summary)
+}
+
+// Fail runs the hooks of the failed event for the observed execution of the kind
+func (n *Notifier) Fail(kind string, failure error) error {
+	return Failed(n.hooks, n.path, kind, failure)
+}
@@ -0,0 +1,110 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	hook, err := ParseCommand("invalid=mail -s integrity root")
+	assert.Nil(t, err)
+	assert.Equal(t, Hook{Event: INVALID, Command: "mail -s integrity root"}, hook)
+	hook, err = ParseWebhook("finished=http://localhost/hook?a=b")
+	assert.Nil(t, err)
+	assert.Equal(t, Hook{Event: FINISHED, URL: "http://localhost/hook?a=b"}, hook)
+
+	for _, value := range []string{"invalid", "invalid=", "aborted=true"} {
+		_, err = ParseCommand(value)
+		assert.NotNil(t, err)
+	}
+	_, err = ParseWebhook("finished=localhost/hook")
+	assert.NotNil(t, err)
+}
+
+func TestEvents(t *testing.T) {
+	assert.Equal(t, []Event{FINISHED}, Events(ilog.VerifySummary{ValidFiles: 2}))
+	assert.Equal(t, []Event{FINISHED, INVALID}, Events(ilog.VerifySummary{ValidFiles: 2, InvalidFiles: 1}))
+	assert.Equal(t, []Event{FINISHED, DUPLICATES}, Events(ilog.DuplicateSummary{DuplicateFiles: 1, NewDuplicateGroups: 1}))
+	assert.Equal(t, []Event{FINISHED}, Events(ilog.DuplicateSummary{DuplicateFiles: 1}))
+	assert.Equal(t, []Event{FINISHED, STYLE}, Events(ilog.StyleSummary{NamingIssues: 1}))
+	assert.Equal(t, []Event{FINISHED, STYLE}, Events(ilog.StyleSummary{PortabilityIssues: 1}))
+	assert.Nil(t, Events(ilog.VerifyLog{Status: ilog.ERROR}))
+}
+
+func TestRun_webhook(t *testing.T) {
+	var payloads []map[string]any
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+	}))
+	defer webhook.Close()
+	hooks := []Hook{{Event: FINISHED, URL: webhook.URL}, {Event: INVALID, URL: webhook.URL}, {Event: STYLE, URL: webhook.URL}}
+
+	assert.Nil(t, Run(hooks, "/data", ilog.VerifySummary{ValidFiles: 2, InvalidFiles: 1}))
+	assert.Len(t, payloads, 2)
+	assert.Equal(t, "finished", payloads[0]["Event"])
+	assert.Equal(t, "invalid", payloads[1]["Event"])
+	assert.Equal(t, "verify", payloads[1]["Kind"])
+	assert.Equal(t, "/data", payloads[1]["Path"])
+	assert.Equal(t, float64(1), payloads[1]["Summary"].(map[string]any)["InvalidFiles"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	hooks = []Hook{{Event: FINISHED, URL: failing.URL}, {Event: FINISHED, URL: webhook.URL}}
+	assert.NotNil(t, Run(hooks, "/data", ilog.UpsertSummary{}))
+	assert.Len(t, payloads, 3)
+}
+
+func TestFailed(t *testing.T) {
+	var payloads []Payload
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+	}))
+	defer webhook.Close()
+	hooks := []Hook{{Event: FINISHED, URL: webhook.URL}, {Event: FAILED, URL: webhook.URL}}
+
+	assert.Nil(t, NewNotifier(hooks, "/data").Fail("verify", errors.New("integrity directory is locked")))
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, FAILED, payloads[0].Event)
+	assert.Equal(t, "verify", payloads[0].Kind)
+	assert.Equal(t, "integrity directory is locked", payloads[0].Error)
+	assert.Nil(t, payloads[0].Summary)
+}
+
+func TestNotifier_command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+	output := filepath.Join(t.TempDir(), "payload.json")
+	notifier := NewNotifier([]Hook{{Event: INVALID, Command: `cat > "` + output + `"`}}, "/data")
+	assert.Nil(t, notifier.Notify())
+	notifier.Entry(ilog.VerifyLog{Status: ilog.ERROR})
+	notifier.Entry(ilog.VerifySummary{InvalidFiles: 1})
+	assert.Nil(t, notifier.Notify())
+
+	var payload Payload
+	content, err := os.ReadFile(output)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(content, &payload))
+	assert.Equal(t, INVALID, payload.Event)
+	assert.Equal(t, "/data", payload.Path)
+
+	notifier = NewNotifier([]Hook{{Event: FINISHED, Command: "echo failed >&2; exit 3"}}, "/data")
+	notifier.Entry(ilog.UpsertSummary{})
+	err = notifier.Notify()
+	assert.ErrorContains(t, err, "failed")
+}
@@ -0,0 +1,74 @@
+package hook
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event triggering a hook, derived from the summary of an execution
+type Event string
+
+// Invalid files and style issues are triggered by each execution with findings, duplicates by new duplicates only
+const (
+	FINISHED   Event = "finished"   // Any execution finished
+	FAILED     Event = "failed"     // Any execution failed without summary, e.g. due to a held lock
+	INVALID    Event = "invalid"    // Verify found invalid files or fsck invalid records
+	DUPLICATES Event = "duplicates" // Duplicates check found new duplicate files
+	STYLE      Event = "style"      // Style check found issues
+)
+
+var events = []Event{FINISHED, FAILED, INVALID, DUPLICATES, STYLE}
+
+// Hook runs a shell command or posts to a webhook on an event. The payload is passed as JSON on stdin
+// of the command or as body of the request.
+type Hook struct {
+	Event   Event
+	Command string
+	URL     string
+}
+
+// Payload passed to the hooks
+type Payload struct {
+	Event    Event
+	Kind     string // Executed command, e.g. verify
+	Path     string
+	Finished time.Time
+	Summary  any    // Concluding summary, e.g. ilog.VerifySummary, nil for failed executions
+	Error    string // Failure of a failed execution
+}
+
+// ParseCommand parses a command hook like "invalid=mail -s integrity admin@example.com"
+func ParseCommand(value string) (Hook, error) {
+	event, command, err := parse(value)
+	if err != nil {
+		return Hook{}, err
+	}
+	return Hook{Event: event, Command: command}, nil
+}
+
+// ParseWebhook parses a webhook like "finished=https://example.com/hook"
+func ParseWebhook(value string) (Hook, error) {
+	event, target, err := parse(value)
+	if err != nil {
+		return Hook{}, err
+	}
+	if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return Hook{}, errors.New("invalid webhook url: " + target)
+	}
+	return Hook{Event: event, URL: target}, nil
+}
+
+func parse(value string) (Event, string, error) {
+	event, target, found := strings.Cut(value, "=")
+	if !found || target == "" {
+		return "", "", errors.New("invalid hook, expected <event>=<target>: " + value)
+	}
+	for _, e := range events {
+		if Event(event) == e {
+			return e, target, nil
+		}
+	}
+	return "", "", errors.New("unknown hook event: " + event)
+}
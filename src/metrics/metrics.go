@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+type definition struct {
+	name string
+	help string
+}
+
+// All metrics are gauges of the last execution per directory, which is labeled by its path
+var definitions = []definition{
+	{"fileintegrity_upsert_hashed_files", "Number of new and updated files hashed by the last upsert."},
+	{"fileintegrity_upsert_hashed_bytes", "Number of bytes hashed by the last upsert."},
+	{"fileintegrity_upsert_hash_rate_bytes", "Hash rate of the last upsert in bytes per second."},
+	{"fileintegrity_upsert_last_timestamp_seconds", "Completion time of the last upsert."},
+	{"fileintegrity_verify_valid_files", "Number of valid files of the last verify."},
+	{"fileintegrity_verify_invalid_files", "Number of invalid files of the last verify."},
+	{"fileintegrity_verify_hashed_bytes", "Number of bytes hashed by the last verify."},
+	{"fileintegrity_verify_hash_rate_bytes", "Hash rate of the last verify in bytes per second."},
+	{"fileintegrity_verify_last_timestamp_seconds", "Completion time of the last verify."},
+	{"fileintegrity_verify_last_success_timestamp_seconds", "Completion time of the last verify without invalid files."},
+	{"fileintegrity_duplicate_files", "Number of duplicate files of the last duplicates check."},
+	{"fileintegrity_duplicate_bytes", "Number of duplicate bytes of the last duplicates check."},
+	{"fileintegrity_duplicate_last_timestamp_seconds", "Completion time of the last duplicates check."},
+}
+
+type sample struct {
+	name string
+	path string
+}
+
+// Registry holds the metrics of the summaries of upsert, verify and the duplicates check
+type Registry struct {
+	mu      sync.Mutex
+	samples map[sample]float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{samples: map[sample]float64{}}
+}
+
+// Observe updates the metrics of the directory with a summary, other entries are ignored
+func (r *Registry) Observe(path string, entry any, finished time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	timestamp := float64(finished.Unix())
+	switch s := entry.(type) {
+	case ilog.UpsertSummary:
+		r.set("fileintegrity_upsert_hashed_files", path, float64(s.NewFiles+s.UpdatedFiles))
+		r.set("fileintegrity_upsert_hashed_bytes", path, float64(s.HashedBytes))
+		r.set("fileintegrity_upsert_hash_rate_bytes", path, float64(s.HashRateInS()))
+		r.set("fileintegrity_upsert_last_timestamp_seconds", path, timestamp)
+	case ilog.VerifySummary:
+		r.set("fileintegrity_verify_valid_files", path, float64(s.ValidFiles))
+		r.set("fileintegrity_verify_invalid_files", path, float64(s.InvalidFiles))
+		r.set("fileintegrity_verify_hashed_bytes", path, float64(s.TotalBytes))
+		r.set("fileintegrity_verify_hash_rate_bytes", path, float64(s.HashRateInS()))
+		r.set("fileintegrity_verify_last_timestamp_seconds", path, timestamp)
+		if s.InvalidFiles == 0 {
+			r.set("fileintegrity_verify_last_success_timestamp_seconds", path, timestamp)
+		}
+	case ilog.DuplicateSummary:
+		r.set("fileintegrity_duplicate_files", path, float64(s.DuplicateFiles))
+		r.set("fileintegrity_duplicate_bytes", path, float64(s.DuplicateBytes))
+		r.set("fileintegrity_duplicate_last_timestamp_seconds", path, timestamp)
+	}
+}
+
+func (r *Registry) set(name string, path string, value float64) {
+	r.samples[sample{name: name, path: path}] = value
+}
+
+// Write renders the metrics in the Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, d := range definitions {
+		paths := []string{}
+		for s := range r.samples {
+			if s.name == d.name {
+				paths = append(paths, s.path)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		sort.Strings(paths)
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", d.name, d.help, d.name)
+		for _, path := range paths {
+			value := r.samples[sample{name: d.name, path: path}]
+			fmt.Fprintf(bw, "%s{path=\"%s\"} %s\n", d.name, escape(path), strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+// Load reads the metrics of a former textfile, so metrics of other commands and directories are kept.
+// A missing file is ignored.
+func (r *Registry) Load(filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, value, err := parse(line)
+		if err != nil {
+			return err
+		}
+		r.samples[s] = value
+	}
+	return scanner.Err()
+}
+
+// WriteFile replaces the textfile atomically, so the textfile collector never reads a partial file
+func (r *Registry) WriteFile(filename string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := r.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Handler serves the metrics for a Prometheus scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.Write(w)
+	})
+}
+
+// Collector observes the summaries of an execution on a directory
+func (r *Registry) Collector(path string) ilog.Observer {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return collector{registry: r, path: path}
+}
+
+type collector struct {
+	registry *Registry
+	path     string
+}
+
+func (c collector) Entry(entry any) {
+	c.registry.Observe(c.path, entry, time.Now())
+}
+
+func (c collector) Progress(done int64, total int64) {}
+
+// Parses a sample line like fileintegrity_verify_invalid_files{path="/data"} 0
+func parse(line string) (sample, float64, error) {
+	name, rest, found := strings.Cut(line, `{path="`)
+	if !found {
+		return sample{}, 0, errors.New("invalid metrics line: " + line)
+	}
+	end := strings.LastIndex(rest, `"} `)
+	if end < 0 {
+		return sample{}, 0, errors.New("invalid metrics line: " + line)
+	}
+	value, err := strconv.ParseFloat(rest[end+3:], 64)
+	if err != nil {
+		return sample{}, 0, errors.New("invalid metrics value: " + line)
+	}
+	return sample{name: name, path: unescape(rest[:end])}, value, nil
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var unescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func unescape(value string) string {
+	return unescaper.Replace(value)
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/stretchr/testify/assert"
+)
+
+var finished = time.Unix(1700000000, 0)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	r.Observe("/data", ilog.UpsertSummary{ExecutionTime: 2 * time.Second, HashedBytes: 2000, NewFiles: 2, UpdatedFiles: 1}, finished)
+	r.Observe("/data", ilog.VerifySummary{ExecutionTime: time.Second, TotalBytes: 500, ValidFiles: 4, InvalidFiles: 1}, finished)
+	r.Observe(`/my "data"`, ilog.DuplicateSummary{DuplicateFiles: 3, DuplicateBytes: 300}, finished)
+	r.Observe("/data", ilog.VerifyLog{Status: ilog.ERROR}, finished)
+
+	var b bytes.Buffer
+	assert.Nil(t, r.Write(&b))
+	expected := `# HELP fileintegrity_upsert_hashed_files Number of new and updated files hashed by the last upsert.
+# TYPE fileintegrity_upsert_hashed_files gauge
+fileintegrity_upsert_hashed_files{path="/data"} 3
+# HELP fileintegrity_upsert_hashed_bytes Number of bytes hashed by the last upsert.
+# TYPE fileintegrity_upsert_hashed_bytes gauge
+fileintegrity_upsert_hashed_bytes{path="/data"} 2000
+# HELP fileintegrity_upsert_hash_rate_bytes Hash rate of the last upsert in bytes per second.
+# TYPE fileintegrity_upsert_hash_rate_bytes gauge
+fileintegrity_upsert_hash_rate_bytes{path="/data"} 1000
+# HELP fileintegrity_upsert_last_timestamp_seconds Completion time of the last upsert.
+# TYPE fileintegrity_upsert_last_timestamp_seconds gauge
+fileintegrity_upsert_last_timestamp_seconds{path="/data"} 1700000000
+# HELP fileintegrity_verify_valid_files Number of valid files of the last verify.
+# TYPE fileintegrity_verify_valid_files gauge
+fileintegrity_verify_valid_files{path="/data"} 4
+# HELP fileintegrity_verify_invalid_files Number of invalid files of the last verify.
+# TYPE fileintegrity_verify_invalid_files gauge
+fileintegrity_verify_invalid_files{path="/data"} 1
+# HELP fileintegrity_verify_hashed_bytes Number of bytes hashed by the last verify.
+# TYPE fileintegrity_verify_hashed_bytes gauge
+fileintegrity_verify_hashed_bytes{path="/data"} 500
+# HELP fileintegrity_verify_hash_rate_bytes Hash rate of the last verify in bytes per second.
+# TYPE fileintegrity_verify_hash_rate_bytes gauge
+fileintegrity_verify_hash_rate_bytes{path="/data"} 500
+# HELP fileintegrity_verify_last_timestamp_seconds Completion time of the last verify.
+# TYPE fileintegrity_verify_last_timestamp_seconds gauge
+fileintegrity_verify_last_timestamp_seconds{path="/data"} 1700000000
+# HELP fileintegrity_duplicate_files Number of duplicate files of the last duplicates check.
+# TYPE fileintegrity_duplicate_files gauge
+fileintegrity_duplicate_files{path="/my \"data\""} 3
+# HELP fileintegrity_duplicate_bytes Number of duplicate bytes of the last duplicates check.
+# TYPE fileintegrity_duplicate_bytes gauge
+fileintegrity_duplicate_bytes{path="/my \"data\""} 300
+# HELP fileintegrity_duplicate_last_timestamp_seconds Completion time of the last duplicates check.
+# TYPE fileintegrity_duplicate_last_timestamp_seconds gauge
+fileintegrity_duplicate_last_timestamp_seconds{path="/my \"data\""} 1700000000
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestRegistry_WriteFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fileintegrity.prom")
+	r := NewRegistry()
+	r.Observe("/data", ilog.VerifySummary{ValidFiles: 5}, finished)
+	assert.Nil(t, r.WriteFile(filename))
+
+	// A failed verify keeps the last success of the former execution
+	r = NewRegistry()
+	assert.Nil(t, r.Load(filename))
+	r.Observe("/data", ilog.VerifySummary{ValidFiles: 4, InvalidFiles: 1}, finished.Add(time.Hour))
+	assert.Nil(t, r.WriteFile(filename))
+
+	r = NewRegistry()
+	assert.Nil(t, r.Load(filename))
+	assert.Equal(t, float64(1700000000), r.samples[sample{"fileintegrity_verify_last_success_timestamp_seconds", "/data"}])
+	assert.Equal(t, float64(1700003600), r.samples[sample{"fileintegrity_verify_last_timestamp_seconds", "/data"}])
+	assert.Equal(t, float64(1), r.samples[sample{"fileintegrity_verify_invalid_files", "/data"}])
+
+	os.WriteFile(filename, []byte("fileintegrity_verify_invalid_files 1\n"), 0644)
+	assert.NotNil(t, NewRegistry().Load(filename))
+	assert.Nil(t, NewRegistry().Load(filepath.Join(t.TempDir(), "missing.prom")))
+}
@@ -0,0 +1,186 @@
+package serve
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const eventInterval = 500 * time.Millisecond
+
+type registerRequest struct {
+	Path     string
+	Schedule map[string]string // Interval per job kind, e.g. {"verify": "24h"}
+}
+
+type startRequest struct {
+	Path string
+	Kind Kind
+}
+
+// Handler provides the JSON API of the server:
+//
+//	GET    /api/directories              status of all registered directories
+//	POST   /api/directories              registers {"path": "/data", "schedule": {"verify": "24h"}}
+//	DELETE /api/directories?path=/data   unregisters a directory
+//	GET    /api/jobs?path=/data          recent jobs, optionally of a single directory
+//	POST   /api/jobs                     starts {"path": "/data", "kind": "verify"}
+//	GET    /api/jobs/<id>                job with summary and entries
+//	GET    /api/jobs/<id>/events         server-sent events with the job progress until the job is finished
+//	GET    /metrics                      Prometheus metrics of the last executions per directory
+//
+// With a token, the API requires the header "Authorization: Bearer <token>", the metrics remain public. POST
+// requests require the content type application/json, which browsers do not send cross-origin without preflight.
+func (s *Server) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metrics.Handler())
+	mux.Handle("/api/directories", authorize(token, s.handleDirectories))
+	mux.Handle("/api/jobs", authorize(token, s.handleJobs))
+	mux.Handle("/api/jobs/", authorize(token, s.handleJob))
+	return mux
+}
+
+// Rejects requests without the bearer token, an empty token disables the authorization
+func authorize(token string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := []byte("Bearer " + token)
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func (s *Server) handleDirectories(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Directories())
+	case http.MethodPost:
+		var request registerRequest
+		if status, err := decodeJSON(r, &request); err != nil {
+			writeError(w, status, err)
+			return
+		}
+		schedule, err := ParseSchedule(request.Schedule)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.Register(request.Path, schedule); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := s.Unregister(r.URL.Query().Get("path")); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Jobs(r.URL.Query().Get("path")))
+	case http.MethodPost:
+		var request startRequest
+		if status, err := decodeJSON(r, &request); err != nil {
+			writeError(w, status, err)
+			return
+		}
+		job, err := s.Start(request.Path, request.Kind)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	id, events := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/events")
+	job, exists := s.Job(id)
+	if !exists {
+		writeError(w, http.StatusNotFound, errors.New("unknown job: "+id))
+		return
+	}
+	if !events {
+		writeJSON(w, http.StatusOK, job)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	ticker := time.NewTicker(eventInterval)
+	defer ticker.Stop()
+	for {
+		job, _ = s.Job(id)
+		job.Entries = nil
+		data, err := json.Marshal(job)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+		if job.Finished != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Decodes the JSON body of the request and returns the status of a failure. Other content types are rejected.
+func decodeJSON(r *http.Request, value any) (int, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, errors.New("content type application/json is required")
+	}
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownDirectory):
+		return http.StatusNotFound
+	case errors.Is(err, ErrBusy):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,312 @@
+package serve
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity"
+	"github.com/aicirt2012/fileintegrity/src/hook"
+	"github.com/aicirt2012/fileintegrity/src/metrics"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+const (
+	maxJobs      = 100  // Number of finished jobs kept in memory
+	maxEntries   = 1000 // Number of entries kept per job, further entries are only counted
+	scheduleTick = time.Second
+)
+
+// Server manages registered directories and runs at most one job per directory at a time. Jobs are started
+// on request or according to the schedule of the directory. The logs are written into the integrity
+// directories like for the command line, besides they are kept in memory for the most recent jobs. The summaries
+// of upsert, verify and the duplicates check are provided as Prometheus metrics.
+type Server struct {
+	options     fileintegrity.Options
+	runners     map[Kind]runner
+	mu          sync.Mutex
+	directories map[string]*directory
+	jobs        []*Job // Ordered by start
+	nextID      int64
+	wg          sync.WaitGroup
+	metrics     *metrics.Registry
+	hooks       []hook.Hook
+}
+
+// New creates a server, which executes the jobs with the options and runs the hooks once a job is finished.
+// Console logs and progress bars are disabled. Process priorities are disabled as well, since concurrent jobs
+// would restore them for each other, hence the server itself is started with the desired priority, e.g. by nice.
+func New(options fileintegrity.Options, hooks []hook.Hook) *Server {
+	options.LogConsole = false
+	options.ProgressBar = false
+	options.Nice = 0
+	options.IdleIO = false
+	return &Server{
+		options:     options,
+		runners:     runners,
+		directories: map[string]*directory{},
+		metrics:     metrics.NewRegistry(),
+		hooks:       hooks,
+	}
+}
+
+// Register adds a directory or replaces the schedule of a registered directory. Scheduled jobs are due after
+// their first interval.
+func (s *Server) Register(path string, schedule Schedule) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := dir.CheckDir(path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, exists := s.directories[path]
+	if !exists {
+		d = &directory{path: path, last: map[Kind]*Job{}}
+		s.directories[path] = d
+	}
+	now := time.Now()
+	d.schedule = schedule
+	d.next = map[Kind]time.Time{}
+	for kind, interval := range schedule {
+		d.next[kind] = now.Add(interval)
+	}
+	return nil
+}
+
+// Unregister removes a directory, a running job is finished nevertheless
+func (s *Server) Unregister(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.directory(path)
+	if err != nil {
+		return err
+	}
+	delete(s.directories, d.path)
+	return nil
+}
+
+// Start runs a job of the kind in the background, unless a job of the directory is running
+func (s *Server) Start(path string, kind Kind) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.directory(path)
+	if err != nil {
+		return Job{}, err
+	}
+	job, err := s.start(d, kind, false)
+	if err != nil {
+		return Job{}, err
+	}
+	return job.snapshot(false), nil
+}
+
+// Schedule starts the due jobs until stop is closed
+func (s *Server) Schedule(stop <-chan bool) {
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.startDue(now)
+		}
+	}
+}
+
+// Starts the first due job of each idle directory. The next run is scheduled one interval after the start.
+func (s *Server) startDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.directories {
+		if d.running != nil {
+			continue
+		}
+		for _, kind := range kinds {
+			next, scheduled := d.next[kind]
+			if !scheduled || next.After(now) {
+				continue
+			}
+			d.next[kind] = now.Add(d.schedule[kind])
+			s.start(d, kind, true)
+			break
+		}
+	}
+}
+
+// Job returns the job with all entries
+func (s *Server) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return job.snapshot(true), true
+		}
+	}
+	return Job{}, false
+}
+
+// Jobs returns the recent jobs of the directory without entries, all jobs for an empty path
+func (s *Server) Jobs(path string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path != "" {
+		path, _ = filepath.Abs(path)
+	}
+	jobs := []Job{}
+	for _, job := range s.jobs {
+		if path == "" || job.Path == path {
+			jobs = append(jobs, job.snapshot(false))
+		}
+	}
+	return jobs
+}
+
+// Directories returns the status of all registered directories
+func (s *Server) Directories() []DirectoryStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	directories := []DirectoryStatus{}
+	for _, d := range s.directories {
+		status := DirectoryStatus{
+			Path:     d.path,
+			Schedule: d.schedule.format(),
+			Next:     map[Kind]time.Time{},
+			Last:     map[Kind]Job{},
+		}
+		for kind, next := range d.next {
+			status.Next[kind] = next
+		}
+		if d.running != nil {
+			running := d.running.snapshot(false)
+			status.Running = &running
+		}
+		for kind, job := range d.last {
+			status.Last[kind] = job.snapshot(false)
+		}
+		directories = append(directories, status)
+	}
+	return directories
+}
+
+// Wait blocks until all running jobs are finished
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Server) directory(path string) (*directory, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	d, exists := s.directories[path]
+	if !exists {
+		return nil, ErrUnknownDirectory
+	}
+	return d, nil
+}
+
+// Starts the job, the lock must be held
+func (s *Server) start(d *directory, kind Kind, scheduled bool) (*Job, error) {
+	run, exists := s.runners[kind]
+	if !exists {
+		return nil, ErrUnknownKind
+	}
+	if d.running != nil {
+		return nil, ErrBusy
+	}
+	s.nextID++
+	job := &Job{
+		ID:        strconv.FormatInt(s.nextID, 10),
+		Path:      d.path,
+		Kind:      kind,
+		Status:    RUNNING,
+		Scheduled: scheduled,
+		Started:   time.Now(),
+		Progress:  Progress{Total: -1},
+	}
+	d.running = job
+	s.jobs = append(s.jobs, job)
+	s.trim()
+
+	options := s.options
+	options.Observer = &observer{server: s, job: job}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := run(d.path, options)
+		s.mu.Lock()
+		summary := job.Summary
+		s.mu.Unlock()
+		var hookErr error
+		if err != nil {
+			hookErr = hook.Failed(s.hooks, d.path, string(kind), err)
+		} else {
+			hookErr = hook.Run(s.hooks, d.path, summary)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		finished := time.Now()
+		job.Finished = &finished
+		job.Status = SUCCEEDED
+		if err != nil {
+			job.Status = FAILED
+			job.Error = err.Error()
+		}
+		if hookErr != nil {
+			job.HookError = hookErr.Error()
+		}
+		d.running = nil
+		d.last[kind] = job
+	}()
+	return job, nil
+}
+
+// Removes the oldest finished jobs exceeding the limit, the lock must be held
+func (s *Server) trim() {
+	for i := 0; len(s.jobs) > maxJobs && i < len(s.jobs); {
+		if s.jobs[i].Finished == nil {
+			i++
+			continue
+		}
+		s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+	}
+}
+
+func (job *Job) snapshot(withEntries bool) Job {
+	snapshot := *job
+	if !withEntries {
+		snapshot.Entries = nil
+	}
+	return snapshot
+}
+
+// Collects the entries and the progress of a running job
+type observer struct {
+	server *Server
+	job    *Job
+}
+
+func (o *observer) Entry(entry any) {
+	o.server.mu.Lock()
+	defer o.server.mu.Unlock()
+	if ilog.IsSummary(entry) {
+		o.job.Summary = entry
+		o.server.metrics.Observe(o.job.Path, entry, time.Now())
+	} else if len(o.job.Entries) < maxEntries {
+		o.job.Entries = append(o.job.Entries, entry)
+	} else {
+		o.job.Truncated++
+	}
+}
+
+func (o *observer) Progress(done int64, total int64) {
+	o.server.mu.Lock()
+	defer o.server.mu.Unlock()
+	o.job.Progress = Progress{Done: done, Total: total}
+}
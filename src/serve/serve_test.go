@@ -0,0 +1,199 @@
+package serve
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity"
+	"github.com/aicirt2012/fileintegrity/src/hook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	schedule, err := ParseSchedule(map[string]string{"upsert": "1h", "verify": "24h", "style": "0s"})
+	assert.Nil(t, err)
+	assert.Equal(t, Schedule{UPSERT: time.Hour, VERIFY: 24 * time.Hour}, schedule)
+
+	for _, intervals := range []map[string]string{{"backup": "1h"}, {"verify": "daily"}, {"verify": "-1h"}} {
+		_, err = ParseSchedule(intervals)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestServer_api(t *testing.T) {
+	basePath := t.TempDir()
+	os.WriteFile(filepath.Join(basePath, "a.txt"), []byte("a"), 0644)
+	var notified []hook.Payload
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload hook.Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		notified = append(notified, payload)
+	}))
+	defer webhook.Close()
+	server := New(fileintegrity.DefaultOptions(), []hook.Hook{{Event: hook.FINISHED, URL: webhook.URL}})
+	api := httptest.NewServer(server.Handler(""))
+	defer api.Close()
+
+	response := request(t, http.MethodPost, api.URL+"/api/directories", `{"path": "`+basePath+`", "schedule": {"verify": "24h"}}`)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	response = request(t, http.MethodPost, api.URL+"/api/jobs", `{"path": "`+basePath+`", "kind": "verify"}`)
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+	server.Wait()
+	job := decode[[]Job](t, request(t, http.MethodGet, api.URL+"/api/jobs?path="+basePath, ""))[0]
+	assert.Equal(t, FAILED, job.Status)
+	assert.Contains(t, job.Error, "upsert is required first")
+
+	response = request(t, http.MethodPost, api.URL+"/api/jobs", `{"path": "`+basePath+`", "kind": "upsert"}`)
+	job = decode[Job](t, response)
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+	assert.Equal(t, RUNNING, job.Status)
+
+	// Events are streamed until the job is finished
+	response = request(t, http.MethodGet, api.URL+"/api/jobs/"+job.ID+"/events", "")
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	var last Job
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		if data, found := strings.CutPrefix(scanner.Text(), "data: "); found {
+			assert.Nil(t, json.Unmarshal([]byte(data), &last))
+		}
+	}
+	assert.Equal(t, SUCCEEDED, last.Status)
+	server.Wait()
+
+	job = decode[Job](t, request(t, http.MethodGet, api.URL+"/api/jobs/"+job.ID, ""))
+	assert.Equal(t, SUCCEEDED, job.Status)
+	assert.Equal(t, int64(1), job.Progress.Done)
+	assert.Equal(t, float64(1), job.Summary.(map[string]any)["NewFiles"])
+	assert.Len(t, job.Entries, 1)
+	assert.Len(t, notified, 1)
+	assert.Equal(t, "upsert", notified[0].Kind)
+
+	response = request(t, http.MethodGet, api.URL+"/metrics", "")
+	content, _ := io.ReadAll(response.Body)
+	assert.Contains(t, string(content), `fileintegrity_upsert_hashed_files{path="`+basePath+`"} 1`)
+
+	directories := decode[[]DirectoryStatus](t, request(t, http.MethodGet, api.URL+"/api/directories", ""))
+	assert.Len(t, directories, 1)
+	assert.Equal(t, map[Kind]string{VERIFY: "24h0m0s"}, directories[0].Schedule)
+	assert.Equal(t, job.ID, directories[0].Last[UPSERT].ID)
+
+	response = request(t, http.MethodGet, api.URL+"/api/jobs/unknown", "")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	response = request(t, http.MethodPost, api.URL+"/api/jobs", `{"path": "`+basePath+`", "kind": "backup"}`)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	response = request(t, http.MethodDelete, api.URL+"/api/directories?path="+basePath, "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	response = request(t, http.MethodPost, api.URL+"/api/jobs", `{"path": "`+basePath+`", "kind": "verify"}`)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestServer_oneJobPerDirectory(t *testing.T) {
+	release := make(chan bool)
+	server := New(fileintegrity.DefaultOptions(), nil)
+	server.runners = map[Kind]runner{
+		UPSERT: func(path string, options fileintegrity.Options) error {
+			<-release
+			return nil
+		},
+		VERIFY: func(path string, options fileintegrity.Options) error {
+			return nil
+		},
+	}
+	a, b := t.TempDir(), t.TempDir()
+	assert.Nil(t, server.Register(a, Schedule{VERIFY: time.Hour}))
+	assert.Nil(t, server.Register(b, Schedule{}))
+	assert.NotNil(t, server.Register(filepath.Join(a, "missing"), Schedule{}))
+
+	_, err := server.Start(a, UPSERT)
+	assert.Nil(t, err)
+	_, err = server.Start(a, VERIFY)
+	assert.ErrorIs(t, err, ErrBusy)
+	_, err = server.Start(b, UPSERT)
+	assert.Nil(t, err)
+
+	// Due jobs of busy directories are started once the directory is idle
+	server.startDue(time.Now().Add(2 * time.Hour))
+	assert.Len(t, server.Jobs(a), 1)
+	close(release)
+	server.Wait()
+	server.startDue(time.Now().Add(2 * time.Hour))
+	server.Wait()
+	jobs := server.Jobs(a)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, VERIFY, jobs[1].Kind)
+	assert.True(t, jobs[1].Scheduled)
+}
+
+func TestServer_lockedDirectory(t *testing.T) {
+	basePath := t.TempDir()
+	os.WriteFile(filepath.Join(basePath, "a.txt"), []byte("a"), 0644)
+	options := fileintegrity.DisabledOptions()
+	assert.Nil(t, fileintegrity.Upsert(basePath, options))
+	lockFile := filepath.Join(basePath, ".integrity", "lock")
+	os.WriteFile(lockFile, []byte(`{"pid":42,"host":"other","acquired":"2024-01-01T12:00:00Z"}`), 0644)
+	server := New(options, nil)
+	assert.Nil(t, server.Register(basePath, Schedule{}))
+
+	// A held lock fails the job instead of the server
+	for _, kind := range []Kind{DUPLICATES, STYLE} {
+		_, err := server.Start(basePath, kind)
+		assert.Nil(t, err)
+		server.Wait()
+		job := server.Jobs(basePath)[len(server.Jobs(basePath))-1]
+		assert.Equal(t, FAILED, job.Status)
+		assert.Contains(t, job.Error, "locked (exclusive) by pid 42")
+	}
+}
+
+func TestServer_authorization(t *testing.T) {
+	basePath := t.TempDir()
+	server := New(fileintegrity.DisabledOptions(), nil)
+	api := httptest.NewServer(server.Handler("secret"))
+	defer api.Close()
+	send := func(authorization string, contentType string) int {
+		r, _ := http.NewRequest(http.MethodPost, api.URL+"/api/directories", strings.NewReader(`{"path": "`+basePath+`"}`))
+		r.Header.Set("Authorization", authorization)
+		r.Header.Set("Content-Type", contentType)
+		response, err := http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("", "application/json"))
+	assert.Equal(t, http.StatusUnauthorized, send("Bearer wrong", "application/json"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, send("Bearer secret", "text/plain"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, send("Bearer secret", "application/x-www-form-urlencoded"))
+	assert.Equal(t, http.StatusNoContent, send("Bearer secret", "application/json; charset=utf-8"))
+	response, err := http.Get(api.URL + "/metrics")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func request(t *testing.T, method string, url string, body string) *http.Response {
+	t.Helper()
+	r, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	r.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(r)
+	assert.Nil(t, err)
+	return response
+}
+
+func decode[T any](t *testing.T, response *http.Response) T {
+	t.Helper()
+	defer response.Body.Close()
+	var value T
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&value))
+	return value
+}
@@ -0,0 +1,114 @@
+package serve
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity"
+)
+
+// Kind of a job, which executes the corresponding public function
+type Kind string
+
+const (
+	UPSERT     Kind = "upsert"
+	VERIFY     Kind = "verify"
+	DUPLICATES Kind = "duplicates"
+	STYLE      Kind = "style"
+	EXTENSIONS Kind = "extensions"
+)
+
+// Order of due jobs of a directory, so the integrity file is up to date before it is verified or checked
+var kinds = []Kind{UPSERT, VERIFY, DUPLICATES, STYLE, EXTENSIONS}
+
+type runner func(path string, options fileintegrity.Options) error
+
+var runners = map[Kind]runner{
+	UPSERT:     fileintegrity.Upsert,
+	VERIFY:     fileintegrity.Verify,
+	DUPLICATES: fileintegrity.CheckDuplicates,
+	STYLE:      fileintegrity.CheckStyleIssues,
+	EXTENSIONS: fileintegrity.CheckExtensionStats,
+}
+
+type Status string
+
+const (
+	RUNNING   Status = "running"
+	SUCCEEDED Status = "succeeded"
+	FAILED    Status = "failed"
+)
+
+var (
+	ErrUnknownDirectory = errors.New("directory is not registered")
+	ErrUnknownKind      = errors.New("unknown job kind")
+	ErrBusy             = errors.New("a job of the directory is running")
+)
+
+// Schedule contains the interval per job kind, kinds without interval are only started on request
+type Schedule map[Kind]time.Duration
+
+// ParseSchedule parses intervals like "24h" per job kind
+func ParseSchedule(intervals map[string]string) (Schedule, error) {
+	schedule := Schedule{}
+	for kind, interval := range intervals {
+		if _, ok := runners[Kind(kind)]; !ok {
+			return nil, errors.New("unknown job kind: " + kind)
+		}
+		d, err := time.ParseDuration(interval)
+		if err != nil || d < 0 {
+			return nil, errors.New("invalid interval: " + interval)
+		}
+		if d > 0 {
+			schedule[Kind(kind)] = d
+		}
+	}
+	return schedule, nil
+}
+
+func (s Schedule) format() map[Kind]string {
+	intervals := map[Kind]string{}
+	for kind, d := range s {
+		intervals[kind] = d.String()
+	}
+	return intervals
+}
+
+type Progress struct {
+	Done  int64 // Processed bytes
+	Total int64 // Total bytes, negative if unknown
+}
+
+// Job is a single execution of a public function on a registered directory
+type Job struct {
+	ID        string
+	Path      string
+	Kind      Kind
+	Status    Status
+	Scheduled bool
+	Started   time.Time
+	Finished  *time.Time `json:",omitempty"`
+	Error     string     `json:",omitempty"`
+	HookError string     `json:",omitempty"` // Failure of the hooks run after the job
+	Progress  Progress
+	Summary   any   `json:",omitempty"` // Concluding summary, e.g. ilog.VerifySummary
+	Entries   []any `json:",omitempty"` // Entries visible on the console, e.g. invalid files of verify
+	Truncated int64 `json:",omitempty"` // Number of entries exceeding the limit
+}
+
+type directory struct {
+	path     string
+	schedule Schedule
+	next     map[Kind]time.Time
+	running  *Job
+	last     map[Kind]*Job
+}
+
+// DirectoryStatus of a registered directory
+type DirectoryStatus struct {
+	Path     string
+	Schedule map[Kind]string
+	Next     map[Kind]time.Time
+	Running  *Job         `json:",omitempty"`
+	Last     map[Kind]Job // Last finished job per kind without entries
+}
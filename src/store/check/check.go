@@ -8,8 +8,8 @@ import (
 	"github.com/aicirt2012/fileintegrity/src/store/check/style"
 )
 
-func Contained(basePath string, externalPath string, fix bool, options store.Options) {
-	contain.Check(basePath, externalPath, fix, options)
+func Contained(basePath string, externalPath string, fix bool, options store.Options) error {
+	return contain.Check(basePath, externalPath, fix, options)
 }
 
 func Duplicates(basePath string, options store.Options) {
@@ -3,9 +3,9 @@ package contain
 import (
 	"log"
 	"os"
-	"path/filepath"
 	"time"
 
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
 	"github.com/aicirt2012/fileintegrity/src/store"
 	"github.com/aicirt2012/fileintegrity/src/store/check/duplicate"
 	"github.com/aicirt2012/fileintegrity/src/store/file"
@@ -13,18 +13,17 @@ import (
 	"golang.org/x/exp/maps"
 )
 
-func Check(basePath string, externalPath string, fix bool, options store.Options) {
+func Check(basePath string, externalPath string, fix bool, options store.Options) error {
 	start := time.Now()
 	summary := ilog.ContainedSummary{}
 	logBuffer := ilog.NewAutomaticLogBuffer(basePath, ilog.Contains, 10000, options.Log)
 
 	options.Backup = true
-	err := store.Upsert(externalPath, options)
-	if err != nil {
-		log.Fatal(err)
+	if err := store.Upsert(externalPath, options); err != nil {
+		return err
 	}
-	baseM, _, _ := duplicate.CalcHashSizeMap(file.LoadContent(basePath))
-	externalM, tf, tb := duplicate.CalcHashSizeMap(file.LoadContent(externalPath))
+	baseM, _, _ := duplicate.CalcHashSizeMap(file.LoadContent(basePath), options.DuplicateIgnoreSize)
+	externalM, tf, tb := duplicate.CalcHashSizeMap(file.LoadContent(externalPath), options.DuplicateIgnoreSize)
 	summary.TotalFiles = tf
 	summary.TotalBytes = tb
 
@@ -42,6 +41,7 @@ func Check(basePath string, externalPath string, fix bool, options store.Options
 
 	summary.ExecutionTime = time.Since(start)
 	logBuffer.Append(summary).Flush()
+	return nil
 }
 
 func analyze(baseM duplicate.UniqueMap, externalM duplicate.UniqueMap, logBuffer *ilog.LogFileBuffer) (int64, int64, []string) {
@@ -69,8 +69,8 @@ func analyze(baseM duplicate.UniqueMap, externalM duplicate.UniqueMap, logBuffer
 
 func removeFiles(basePath string, relativePaths []string) {
 	for _, relativePath := range relativePaths {
-		path := filepath.Join(basePath, relativePath)
-		err := os.Remove(path)
+		filename := path.Absolute(basePath, relativePath)
+		err := os.Remove(filename)
 		if err != nil {
 			log.Fatal("could not remove contained or duplicate file: ", err)
 		}
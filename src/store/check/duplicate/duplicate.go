@@ -9,23 +9,30 @@ import (
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
 )
 
+// Check logs the groups of files with an equal content. Groups with a file, which was no duplicate of the content
+// at the previous check, are counted as new.
 func Check(basePath string, options store.Options) {
 	start := time.Now()
 	summary := ilog.DuplicateSummary{}
 	logBuffer := ilog.NewAutomaticLogBuffer(basePath, ilog.Duplicates, 10000, options.Log)
 
-	m, tf, tb := CalcHashSizeMap(file.LoadContent(basePath))
+	m, tf, tb := CalcHashSizeMap(file.LoadContent(basePath), options.DuplicateIgnoreSize)
 	summary.TotalFiles = tf
 	summary.TotalBytes = tb
 
 	df, db, _ := Analyze(m, &logBuffer)
 	summary.DuplicateFiles = df
 	summary.DuplicateBytes = db
+	summary.HardlinkFiles = countHardlinks(m)
+	newGroups, current := loadKnown(basePath).compare(m)
+	summary.NewDuplicateGroups = newGroups
+	current.save(basePath)
 
 	summary.ExecutionTime = time.Since(start)
 	logBuffer.Append(summary).Flush()
 }
 
+// Hardlinks of an already listed file are logged, but not counted as duplicates, since they use no extra space
 func Analyze(m UniqueMap, logBuffer *ilog.LogFileBuffer) (int64, int64, []string) {
 	var relPaths []string
 	var files, bytes int64
@@ -37,6 +44,10 @@ func Analyze(m UniqueMap, logBuffer *ilog.LogFileBuffer) (int64, int64, []string
 			Hash: fileHashes[0].Hash,
 		}
 		for i, fileHash := range fileHashes {
+			if isHardlink(fileHashes[:i], fileHash) {
+				log.AddHardlink(fileHash.RelativePath)
+				continue
+			}
 			log.AddRelativePath(fileHash.RelativePath)
 			if i > 0 {
 				files++
@@ -49,14 +60,41 @@ func Analyze(m UniqueMap, logBuffer *ilog.LogFileBuffer) (int64, int64, []string
 	return files, bytes, relPaths
 }
 
-// Create map with hash and size as key, ignore small files as well as git files
-func CalcHashSizeMap(fileHashs []file.FileHash) (UniqueMap, int64, int64) {
+func countHardlinks(m UniqueMap) (files int64) {
+	for _, fileHashes := range m {
+		for i, fileHash := range fileHashes {
+			if isHardlink(fileHashes[:i], fileHash) {
+				files++
+			}
+		}
+	}
+	return files
+}
+
+// A file is a hardlink, if a previous file shares the same inode
+func isHardlink(previous file.FileHashs, fh file.FileHash) bool {
+	if fh.Inode == "" {
+		return false
+	}
+	for _, p := range previous {
+		if p.Inode == fh.Inode {
+			return true
+		}
+	}
+	return false
+}
+
+// Create map with hash and size as key, ignore directories, files up to the ignore size as well as git files
+func CalcHashSizeMap(fileHashs []file.FileHash, ignoreSize int64) (UniqueMap, int64, int64) {
 	m := UniqueMap{}
 	var totalFiles, totalBytes int64
 	for _, fh := range fileHashs {
+		if fh.Dir {
+			continue
+		}
 		totalFiles++
 		totalBytes += fh.Size
-		if fh.Size <= 100 || strings.Contains(fh.RelativePath, ".git") {
+		if fh.Size <= ignoreSize || strings.Contains(fh.RelativePath, ".git") {
 			continue
 		}
 		m.add(fh)
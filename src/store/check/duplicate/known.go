@@ -0,0 +1,67 @@
+package duplicate
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+// The known file .integrity/duplicates keeps the duplicates of the previous check, one content key and relative
+// path per line. Hence, only groups with a file, which was no duplicate of the same content before, are new.
+const knownFilename = "duplicates"
+
+type known map[string]bool
+
+func loadKnown(basePath string) known {
+	k := known{}
+	content, err := os.ReadFile(filepath.Join(basePath, dir.Name, knownFilename))
+	if err != nil {
+		return k
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			k[line] = true
+		}
+	}
+	return k
+}
+
+func (k known) save(basePath string) {
+	lines := make([]string, 0, len(k))
+	for line := range k {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	content := strings.Join(lines, "\n")
+	if err := dir.WriteFile(filepath.Join(basePath, dir.Name, knownFilename), []byte(content)); err != nil {
+		log.Printf("could not save known duplicates: %v", err)
+	}
+}
+
+// Counts the duplicate groups with a file unknown to the previous check and returns the duplicates of this check
+func (k known) compare(m UniqueMap) (int64, known) {
+	var groups int64
+	current := known{}
+	for _, fileHashes := range m.orderedValues() {
+		if len(fileHashes) <= 1 {
+			continue
+		}
+		isNew := false
+		for i, fileHash := range fileHashes {
+			if isHardlink(fileHashes[:i], fileHash) {
+				continue
+			}
+			line := key(fileHash) + " " + fileHash.RelativePath
+			current[line] = true
+			isNew = isNew || !k[line]
+		}
+		if isNew {
+			groups++
+		}
+	}
+	return groups, current
+}
@@ -42,6 +42,9 @@ func calcExtensionMap(fileHashs []file.FileHash) (extMap, int64) {
 	m := extMap{}
 	var totalBytes int64
 	for _, fh := range fileHashs {
+		if fh.Dir {
+			continue
+		}
 		totalBytes += fh.Size
 		name := strings.ToLower(filepath.Ext(fh.RelativePath))
 		if entry, ok := m[name]; ok {
@@ -2,20 +2,24 @@ package common
 
 import (
 	"fmt"
-	"path/filepath"
 	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
 )
 
-func SplitPath(path string) []string {
-	return strings.Split(path, string(filepath.Separator))
+// Separator of the canonical relative paths
+const Separator = string(path.Separator)
+
+func SplitPath(relativePath string) []string {
+	return path.Split(relativePath)
 }
 
-func SplitDirs(path string) []string {
-	return SplitPath(filepath.Dir(path))
+func SplitDirs(relativePath string) []string {
+	return SplitPath(path.Dir(relativePath))
 }
 
 func MinimalPath(i int, sections []string) string {
-	return strings.Join(sections[:i], string(filepath.Separator))
+	return strings.Join(sections[:i], Separator)
 }
 
 func JoinWithAnd(item []string) string {
@@ -27,14 +27,14 @@ func TestSplitPath(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			actual := SplitPath(common.NormalizePath(c.input))
+			actual := SplitPath(common.CanonicalPath(c.input))
 			assert.Equal(t, c.expected, actual)
 		})
 	}
 }
 
 func TestSplitDirs(t *testing.T) {
-	input := common.NormalizePath("root/sub/f.txt")
+	input := common.CanonicalPath("root/sub/f.txt")
 	actual := SplitDirs(input)
 	expected := []string{"root", "sub"}
 	assert.Equal(t, expected, actual)
@@ -57,13 +57,13 @@ func TestMinimalPath(t *testing.T) {
 			name:     "Partial path",
 			i:        2,
 			sections: []string{"root", "sub", "x"},
-			expected: common.NormalizePath("root/sub"),
+			expected: common.CanonicalPath("root/sub"),
 		},
 		{
 			name:     "Full part",
 			i:        2,
 			sections: []string{"root", "sub"},
-			expected: common.NormalizePath("root/sub"),
+			expected: common.CanonicalPath("root/sub"),
 		},
 	}
 
@@ -2,9 +2,9 @@ package hierarchy
 
 import (
 	"fmt"
-	"path/filepath"
 	"strings"
 
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
 	"github.com/aicirt2012/fileintegrity/src/store/check/style/common"
 	"github.com/aicirt2012/fileintegrity/src/store/file"
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
@@ -43,7 +43,7 @@ func duplicateWords(path string) []string {
 
 func pathWords(path string) []string {
 	path = strings.ToLower(path)
-	path = strings.ReplaceAll(path, string(filepath.Separator), " ")
+	path = strings.ReplaceAll(path, common.Separator, " ")
 	words := []string{}
 	for _, w := range strings.Split(path, " ") {
 		if w != "" {
@@ -59,7 +59,7 @@ func minimalPath(path string, duplicates []string) string {
 		currentIndex := iLastIndex(path, duplicate) + len(duplicate)
 		index = max(index, currentIndex)
 	}
-	index = firstIndexAfter(path, string(filepath.Separator), index)
+	index = firstIndexAfter(path, common.Separator, index)
 	if index == -1 {
 		return path
 	}
@@ -87,7 +87,7 @@ func firstIndexAfter(s string, subStr string, idx int) int {
 func uniqueDirPaths(fhs file.FileHashs) []string {
 	m := make(map[string]bool)
 	for _, fh := range fhs {
-		m[filepath.Dir(fh.RelativePath)] = true
+		m[path.Dir(fh.RelativePath)] = true
 	}
 	values := maps.Keys(m)
 	return values
@@ -11,9 +11,9 @@ import (
 
 func TestCheck(t *testing.T) {
 	fhs := file.FileHashs{
-		{RelativePath: common.NormalizePath("root/simple path/f.txt")},
-		{RelativePath: common.NormalizePath("root/linux ubuntu/another linux/ubuntu/f1.txt")},
-		{RelativePath: common.NormalizePath("root/linux ubuntu/another linux/ubuntu/f2.txt")},
+		{RelativePath: common.CanonicalPath("root/simple path/f.txt")},
+		{RelativePath: common.CanonicalPath("root/linux ubuntu/another linux/ubuntu/f1.txt")},
+		{RelativePath: common.CanonicalPath("root/linux ubuntu/another linux/ubuntu/f2.txt")},
 	}
 	logBuffer := ilog.NewManualLogBuffer("", ilog.Style, ilog.Options{})
 	actual := Check(fhs, &logBuffer)
@@ -45,7 +45,7 @@ func TestDuplicateWords(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			actual := duplicateWords(common.NormalizePath(c.input))
+			actual := duplicateWords(common.CanonicalPath(c.input))
 			assert.Equal(t, c.expected, actual)
 		})
 	}
@@ -71,7 +71,7 @@ func TestPathWords(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			actual := pathWords(common.NormalizePath(c.input))
+			actual := pathWords(common.CanonicalPath(c.input))
 			assert.Equal(t, c.expected, actual)
 		})
 	}
@@ -100,8 +100,8 @@ func TestMinimalPath(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			actual := minimalPath(common.NormalizePath(c.path), c.duplicates)
-			assert.Equal(t, common.NormalizePath(c.expected), actual)
+			actual := minimalPath(common.CanonicalPath(c.path), c.duplicates)
+			assert.Equal(t, common.CanonicalPath(c.expected), actual)
 		})
 	}
 }
@@ -154,10 +154,10 @@ func TestFirstIndexAfter(t *testing.T) {
 
 func TestUniqueDirPaths(t *testing.T) {
 	input := file.FileHashs{
-		{RelativePath: common.NormalizePath("root/sub/f1.txt")},
-		{RelativePath: common.NormalizePath("root/sub/f2.txt")},
+		{RelativePath: common.CanonicalPath("root/sub/f1.txt")},
+		{RelativePath: common.CanonicalPath("root/sub/f2.txt")},
 	}
-	expected := []string{common.NormalizePath("root/sub")}
+	expected := []string{common.CanonicalPath("root/sub")}
 	actual := uniqueDirPaths(input)
 	assert.Equal(t, expected, actual)
 }
@@ -8,33 +8,50 @@ import (
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
 )
 
-const maxPathLen = 260
-const maxDirLen = 60
+const DefaultMaxPathLen = 260
+const DefaultMaxDirLen = 60
 
-func Check(fhs file.FileHashs, logBuffer *ilog.LogFileBuffer) int {
+// Limits of the path and directory name lengths, zero values default to the windows limits
+type Limits struct {
+	MaxPathLen int
+	MaxDirLen  int
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxPathLen <= 0 {
+		l.MaxPathLen = DefaultMaxPathLen
+	}
+	if l.MaxDirLen <= 0 {
+		l.MaxDirLen = DefaultMaxDirLen
+	}
+	return l
+}
+
+func Check(fhs file.FileHashs, limits Limits, logBuffer *ilog.LogFileBuffer) int {
+	limits = limits.withDefaults()
 	m := make(common.LogStyleMap)
 	for _, fh := range fhs {
-		m.PutAll(findPathIssues(fh.RelativePath))
+		m.PutAll(findPathIssues(fh.RelativePath, limits))
 	}
 	return m.WriteTo(logBuffer)
 }
 
-func findPathIssues(path string) []ilog.StyleLog {
+func findPathIssues(path string, limits Limits) []ilog.StyleLog {
 	logs := []ilog.StyleLog{}
 	dirNames := common.SplitDirs(path)
 	for i, dirName := range dirNames {
-		if len(dirName) > maxDirLen {
+		if len(dirName) > limits.MaxDirLen {
 			logs = append(logs, ilog.StyleLog{
 				IssueType:    ilog.LENGTH_ISSUE,
-				Reason:       fmt.Sprintf("Maximum directory length of %v characters exceeded by %v characters", maxDirLen, len(dirName)-maxDirLen),
+				Reason:       fmt.Sprintf("Maximum directory length of %v characters exceeded by %v characters", limits.MaxDirLen, len(dirName)-limits.MaxDirLen),
 				RelativePath: common.MinimalPath(i+1, dirNames),
 			})
 		}
 	}
-	if len(path) > maxPathLen {
+	if len(path) > limits.MaxPathLen {
 		logs = append(logs, ilog.StyleLog{
 			IssueType:    ilog.LENGTH_ISSUE,
-			Reason:       fmt.Sprintf("Maximum path length of %v characters exceeded by %v characters", maxPathLen, len(path)-maxPathLen),
+			Reason:       fmt.Sprintf("Maximum path length of %v characters exceeded by %v characters", limits.MaxPathLen, len(path)-limits.MaxPathLen),
 			RelativePath: path,
 		})
 	}
@@ -13,11 +13,15 @@ import (
 
 func TestCheck(t *testing.T) {
 	fhs := file.FileHashs{
-		{RelativePath: extendTo("root/l#ng dir name/f.txt", maxPathLen+1)},
+		{RelativePath: extendTo("root/l#ng dir name/f.txt", DefaultMaxPathLen+1)},
 	}
 	logBuffer := ilog.NewManualLogBuffer("", ilog.Style, ilog.Options{})
-	actual := Check(fhs, &logBuffer)
+	actual := Check(fhs, Limits{}, &logBuffer)
 	assert.Equal(t, 2, actual)
+
+	logBuffer = ilog.NewManualLogBuffer("", ilog.Style, ilog.Options{})
+	actual = Check(fhs, Limits{MaxPathLen: 300, MaxDirLen: 300}, &logBuffer)
+	assert.Equal(t, 0, actual)
 }
 
 func TestFindPathIssues(t *testing.T) {
@@ -28,33 +32,33 @@ func TestFindPathIssues(t *testing.T) {
 	}{
 		{
 			name:     "Max valid section len",
-			path:     extendBy("root/#/f.txt", maxDirLen),
+			path:     extendBy("root/#/f.txt", DefaultMaxDirLen),
 			expected: []ilog.StyleLog{},
 		},
 		{
 			name: "Section exceeds max len",
-			path: extendBy("root/#/f.txt", maxDirLen+1),
+			path: extendBy("root/#/f.txt", DefaultMaxDirLen+1),
 			expected: []ilog.StyleLog{
 				{
 					IssueType:    ilog.LENGTH_ISSUE,
-					Reason:       fmt.Sprintf("Maximum directory length of %v characters exceeded by 1 characters", maxDirLen),
-					RelativePath: common.NormalizePath(extendBy("root/#", maxDirLen+1)),
+					Reason:       fmt.Sprintf("Maximum directory length of %v characters exceeded by 1 characters", DefaultMaxDirLen),
+					RelativePath: common.CanonicalPath(extendBy("root/#", DefaultMaxDirLen+1)),
 				},
 			},
 		},
 		{
 			name:     "Max valid path len",
-			path:     extendTo("root/#/#/#/#/#/f.txt", maxPathLen),
+			path:     extendTo("root/#/#/#/#/#/f.txt", DefaultMaxPathLen),
 			expected: []ilog.StyleLog{},
 		},
 		{
 			name: "Path exceeds max len",
-			path: extendTo("root/#/#/#/#/#/f.txt", maxPathLen+1),
+			path: extendTo("root/#/#/#/#/#/f.txt", DefaultMaxPathLen+1),
 			expected: []ilog.StyleLog{
 				{
 					IssueType:    ilog.LENGTH_ISSUE,
-					Reason:       fmt.Sprintf("Maximum path length of %v characters exceeded by 1 characters", maxPathLen),
-					RelativePath: common.NormalizePath(extendTo("root/#/#/#/#/#/f.txt", maxPathLen+1)),
+					Reason:       fmt.Sprintf("Maximum path length of %v characters exceeded by 1 characters", DefaultMaxPathLen),
+					RelativePath: common.CanonicalPath(extendTo("root/#/#/#/#/#/f.txt", DefaultMaxPathLen+1)),
 				},
 			},
 		},
@@ -62,7 +66,7 @@ func TestFindPathIssues(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			actual := findPathIssues(common.NormalizePath(c.path))
+			actual := findPathIssues(common.CanonicalPath(c.path), Limits{}.withDefaults())
 			assert.Equal(t, c.expected, actual)
 		})
 	}
@@ -11,10 +11,10 @@ import (
 
 func TestCheck(t *testing.T) {
 	fhs := file.FileHashs{
-		{RelativePath: common.NormalizePath("root/s/a .txt")},
-		{RelativePath: common.NormalizePath("root/s/a.txt.txt")},
-		{RelativePath: common.NormalizePath("root/x (1)/c.txt")},
-		{RelativePath: common.NormalizePath("root/x (1)/b.txt")},
+		{RelativePath: common.CanonicalPath("root/s/a .txt")},
+		{RelativePath: common.CanonicalPath("root/s/a.txt.txt")},
+		{RelativePath: common.CanonicalPath("root/x (1)/c.txt")},
+		{RelativePath: common.CanonicalPath("root/x (1)/b.txt")},
 	}
 	logBuffer := ilog.NewManualLogBuffer("", ilog.Style, ilog.Options{})
 	actual := Check(fhs, &logBuffer)
@@ -34,7 +34,7 @@ func TestFindPathIssues(t *testing.T) {
 				{
 					IssueType:    ilog.NAMING_ISSUE,
 					Reason:       "Path contains copy or rename postfix ' - Copy (1)'",
-					RelativePath: common.NormalizePath("root/sub - Copy (1)"),
+					RelativePath: common.CanonicalPath("root/sub - Copy (1)"),
 				},
 			},
 		},
@@ -45,12 +45,12 @@ func TestFindPathIssues(t *testing.T) {
 				{
 					IssueType:    ilog.NAMING_ISSUE,
 					Reason:       "Path contains copy or rename postfix ' (1)'",
-					RelativePath: common.NormalizePath("root/s (1)"),
+					RelativePath: common.CanonicalPath("root/s (1)"),
 				},
 				{
 					IssueType:    ilog.NAMING_ISSUE,
 					Reason:       "Path contains copy or rename postfix ' (2)'",
-					RelativePath: common.NormalizePath("root/s (1)/name (2).txt"),
+					RelativePath: common.CanonicalPath("root/s (1)/name (2).txt"),
 				},
 			},
 		},
@@ -61,12 +61,12 @@ func TestFindPathIssues(t *testing.T) {
 				{
 					IssueType:    ilog.NAMING_ISSUE,
 					Reason:       "Path contains copy or rename postfix ' (1)'",
-					RelativePath: common.NormalizePath("root/s (1)"),
+					RelativePath: common.CanonicalPath("root/s (1)"),
 				},
 				{
 					IssueType:    ilog.NAMING_ISSUE,
 					Reason:       "File name contains space postfix",
-					RelativePath: common.NormalizePath("root/s (1)/name .txt"),
+					RelativePath: common.CanonicalPath("root/s (1)/name .txt"),
 				},
 			},
 		},
@@ -77,12 +77,12 @@ func TestFindPathIssues(t *testing.T) {
 				{
 					IssueType:    ilog.NAMING_ISSUE,
 					Reason:       "Path contains copy or rename postfix ' (1)'",
-					RelativePath: common.NormalizePath("root/s (1)"),
+					RelativePath: common.CanonicalPath("root/s (1)"),
 				},
 				{
 					IssueType:    ilog.NAMING_ISSUE,
 					Reason:       "File name contains repeated extension",
-					RelativePath: common.NormalizePath("root/s (1)/name.txt.txt"),
+					RelativePath: common.CanonicalPath("root/s (1)/name.txt.txt"),
 				},
 			},
 		},
@@ -90,7 +90,7 @@ func TestFindPathIssues(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			input := common.NormalizePath(c.path)
+			input := common.CanonicalPath(c.path)
 			actual := findPathIssues(input)
 			assert.Equal(t, c.expected, actual)
 		})
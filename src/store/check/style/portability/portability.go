@@ -0,0 +1,168 @@
+package portability
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/store/check/style/common"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Names reserved by windows independent of the extension, e.g. nul.txt
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Characters forbidden in names on windows besides control characters, backslashes are separators
+const forbiddenCharacters = `<>:"|?*\`
+
+// Check reports paths, which collide or are invalid on other file systems. Collisions of names differing only in
+// the Unicode normalisation share the canonical relative path, hence they are detected within the directories
+// on disk.
+func Check(basePath string, fhs file.FileHashs, logBuffer *ilog.LogFileBuffer) int {
+	m := make(common.LogStyleMap)
+	for _, fh := range fhs {
+		m.PutAll(findPathIssues(fh.RelativePath, fh.Dir))
+	}
+	m.PutAll(findCaseCollisions(fhs))
+	m.PutAll(findNormalisationCollisions(basePath, fhs))
+	return m.WriteTo(logBuffer)
+}
+
+func findPathIssues(relativePath string, isDir bool) (logs []ilog.StyleLog) {
+	sections := common.SplitPath(relativePath)
+	for i, section := range sections {
+		minimalPath := common.MinimalPath(i+1, sections)
+		if isReserved(section) {
+			logs = append(logs, ilog.StyleLog{
+				IssueType:    ilog.RESERVED_NAME_ISSUE,
+				Reason:       fmt.Sprintf("Name '%v' is reserved on windows", section),
+				RelativePath: minimalPath,
+			})
+		}
+		if characters := forbidden(section); len(characters) > 0 {
+			logs = append(logs, ilog.StyleLog{
+				IssueType:    ilog.FORBIDDEN_CHARACTER_ISSUE,
+				Reason:       fmt.Sprintf("Name contains the character%v %v forbidden on windows", common.PluralS(characters), common.JoinWithAnd(common.Quote(characters))),
+				RelativePath: minimalPath,
+			})
+		}
+		if i == len(sections)-1 && !isDir {
+			continue
+		}
+		if strings.HasSuffix(section, ".") {
+			logs = append(logs, ilog.StyleLog{
+				IssueType:    ilog.TRAILING_CHARACTER_ISSUE,
+				Reason:       "Directory name ends with a dot",
+				RelativePath: minimalPath,
+			})
+		} else if strings.HasSuffix(section, " ") {
+			logs = append(logs, ilog.StyleLog{
+				IssueType:    ilog.TRAILING_CHARACTER_ISSUE,
+				Reason:       "Directory name ends with a space",
+				RelativePath: minimalPath,
+			})
+		}
+	}
+	return logs
+}
+
+// Reserved names are matched case-insensitive without extension and trailing spaces, e.g. 'Con .txt'
+func isReserved(name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	return reservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// Returns the distinct forbidden characters of the name, control characters are escaped
+func forbidden(name string) []string {
+	characters := []string{}
+	for _, r := range name {
+		character := ""
+		if strings.ContainsRune(forbiddenCharacters, r) {
+			character = string(r)
+		} else if r < ' ' {
+			character = fmt.Sprintf(`\x%02x`, r)
+		}
+		if character != "" && !slices.Contains(characters, character) {
+			characters = append(characters, character)
+		}
+	}
+	return characters
+}
+
+// Siblings whose names differ only in case collide on case-insensitive file systems like NTFS or APFS. The
+// first path in order is kept, the others are reported.
+func findCaseCollisions(fhs file.FileHashs) (logs []ilog.StyleLog) {
+	visited := make(map[string]bool)
+	siblings := make(map[string][]string) // Folded name within the parent to paths
+	for _, fh := range fhs {
+		sections := common.SplitPath(fh.RelativePath)
+		for i := range sections {
+			minimalPath := common.MinimalPath(i+1, sections)
+			if visited[minimalPath] {
+				continue
+			}
+			visited[minimalPath] = true
+			key := common.MinimalPath(i, sections) + common.Separator + strings.ToLower(sections[i])
+			siblings[key] = append(siblings[key], minimalPath)
+		}
+	}
+	for _, paths := range siblings {
+		sort.Strings(paths)
+		for _, p := range paths[1:] {
+			logs = append(logs, ilog.StyleLog{
+				IssueType:    ilog.CASE_COLLISION_ISSUE,
+				Reason:       fmt.Sprintf("Path collides with '%v' on case-insensitive file systems", paths[0]),
+				RelativePath: p,
+			})
+		}
+	}
+	return logs
+}
+
+// Names of the directories on disk, which differ only in the Unicode normalisation, e.g. NFC and NFD. These
+// collide on file systems which normalise names and with the canonical relative paths of the integrity file.
+func findNormalisationCollisions(basePath string, fhs file.FileHashs) (logs []ilog.StyleLog) {
+	for _, dir := range uniqueDirPaths(fhs) {
+		entries, err := os.ReadDir(path.Absolute(basePath, dir))
+		if err != nil {
+			continue
+		}
+		names := make(map[string]int) // Canonical name to number of names on disk
+		for _, entry := range entries {
+			names[path.Canonical(entry.Name())]++
+		}
+		for name, count := range names {
+			if count > 1 {
+				logs = append(logs, ilog.StyleLog{
+					IssueType:    ilog.NORMALISATION_COLLISION_ISSUE,
+					Reason:       fmt.Sprintf("Path is shared by %v names differing only in the Unicode normalisation", count),
+					RelativePath: path.Join(dir, name),
+				})
+			}
+		}
+	}
+	return logs
+}
+
+// Returns the directories of all paths including their parents, the base directory is empty
+func uniqueDirPaths(fhs file.FileHashs) []string {
+	m := map[string]bool{"": true}
+	for _, fh := range fhs {
+		sections := common.SplitDirs(fh.RelativePath)
+		for i := range sections {
+			if sections[i] != "." {
+				m[common.MinimalPath(i+1, sections)] = true
+			}
+		}
+	}
+	return maps.Keys(m)
+}
@@ -0,0 +1,118 @@
+package portability
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	fhs := file.FileHashs{
+		{RelativePath: "root/Foo.txt"},
+		{RelativePath: "root/foo.txt"},
+		{RelativePath: "root/nul.txt"},
+		{RelativePath: "root/a:b.txt"},
+		{RelativePath: "root/dir./f.txt"},
+	}
+	logBuffer := ilog.NewManualLogBuffer("", ilog.Style, ilog.Options{})
+	actual := Check(t.TempDir(), fhs, &logBuffer)
+	assert.Equal(t, 4, actual)
+}
+
+func TestFindPathIssues(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		isDir    bool
+		expected []ilog.StyleLog
+	}{
+		{
+			name:     "Portable path",
+			path:     "root/con tact/console.txt",
+			expected: nil,
+		},
+		{
+			name: "Reserved names",
+			path: "root/COM1/Aux .tar.gz",
+			expected: []ilog.StyleLog{
+				{IssueType: ilog.RESERVED_NAME_ISSUE, Reason: "Name 'COM1' is reserved on windows", RelativePath: "root/COM1"},
+				{IssueType: ilog.RESERVED_NAME_ISSUE, Reason: "Name 'Aux .tar.gz' is reserved on windows", RelativePath: "root/COM1/Aux .tar.gz"},
+			},
+		},
+		{
+			name: "Forbidden characters",
+			path: "root/what?/a<b>:c?.txt",
+			expected: []ilog.StyleLog{
+				{IssueType: ilog.FORBIDDEN_CHARACTER_ISSUE, Reason: "Name contains the character '?' forbidden on windows", RelativePath: "root/what?"},
+				{IssueType: ilog.FORBIDDEN_CHARACTER_ISSUE, Reason: "Name contains the characters '<', '>', ':' and '?' forbidden on windows", RelativePath: "root/what?/a<b>:c?.txt"},
+			},
+		},
+		{
+			name: "Control characters",
+			path: "root/a\tb.txt",
+			expected: []ilog.StyleLog{
+				{IssueType: ilog.FORBIDDEN_CHARACTER_ISSUE, Reason: `Name contains the character '\x09' forbidden on windows`, RelativePath: "root/a\tb.txt"},
+			},
+		},
+		{
+			name: "Trailing characters of directories",
+			path: "root/dir./sub /file.",
+			expected: []ilog.StyleLog{
+				{IssueType: ilog.TRAILING_CHARACTER_ISSUE, Reason: "Directory name ends with a dot", RelativePath: "root/dir."},
+				{IssueType: ilog.TRAILING_CHARACTER_ISSUE, Reason: "Directory name ends with a space", RelativePath: "root/dir./sub "},
+			},
+		},
+		{
+			name:  "Trailing characters of empty directories",
+			path:  "root/empty ",
+			isDir: true,
+			expected: []ilog.StyleLog{
+				{IssueType: ilog.TRAILING_CHARACTER_ISSUE, Reason: "Directory name ends with a space", RelativePath: "root/empty "},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, findPathIssues(c.path, c.isDir))
+		})
+	}
+}
+
+func TestFindCaseCollisions(t *testing.T) {
+	fhs := file.FileHashs{
+		{RelativePath: "Root/a.txt"},
+		{RelativePath: "root/A.txt"},
+		{RelativePath: "root/a.txt"},
+		{RelativePath: "root/b/c.txt"},
+		{RelativePath: "root/B/c.txt"},
+	}
+
+	actual := findCaseCollisions(fhs)
+
+	assert.ElementsMatch(t, []ilog.StyleLog{
+		{IssueType: ilog.CASE_COLLISION_ISSUE, Reason: "Path collides with 'Root' on case-insensitive file systems", RelativePath: "root"},
+		{IssueType: ilog.CASE_COLLISION_ISSUE, Reason: "Path collides with 'root/A.txt' on case-insensitive file systems", RelativePath: "root/a.txt"},
+		{IssueType: ilog.CASE_COLLISION_ISSUE, Reason: "Path collides with 'root/B' on case-insensitive file systems", RelativePath: "root/b"},
+	}, actual)
+}
+
+func TestFindNormalisationCollisions(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, "root"), 0755)
+	os.WriteFile(filepath.Join(basePath, "root", "\u00e9.txt"), []byte("nfc"), 0644)
+	os.WriteFile(filepath.Join(basePath, "root", "e\u0301.txt"), []byte("nfd"), 0644)
+	if entries, _ := os.ReadDir(filepath.Join(basePath, "root")); len(entries) < 2 {
+		t.Skip("file system normalises names")
+	}
+	fhs := file.FileHashs{{RelativePath: "root/\u00e9.txt"}}
+
+	actual := findNormalisationCollisions(basePath, fhs)
+
+	assert.Equal(t, []ilog.StyleLog{
+		{IssueType: ilog.NORMALISATION_COLLISION_ISSUE, Reason: "Path is shared by 2 names differing only in the Unicode normalisation", RelativePath: "root/\u00e9.txt"},
+	}, actual)
+}
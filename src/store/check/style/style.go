@@ -7,6 +7,7 @@ import (
 	"github.com/aicirt2012/fileintegrity/src/store/check/style/hierarchy"
 	"github.com/aicirt2012/fileintegrity/src/store/check/style/length"
 	"github.com/aicirt2012/fileintegrity/src/store/check/style/naming"
+	"github.com/aicirt2012/fileintegrity/src/store/check/style/portability"
 	"github.com/aicirt2012/fileintegrity/src/store/file"
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
 )
@@ -19,7 +20,8 @@ func Check(basePath string, options store.Options) {
 	fileHashes := file.LoadContent(basePath)
 	summary.HierarchyIssues = hierarchy.Check(fileHashes, &logBuffer)
 	summary.NamingIssues = naming.Check(fileHashes, &logBuffer)
-	summary.LengthIssues = length.Check(fileHashes, &logBuffer)
+	summary.LengthIssues = length.Check(fileHashes, length.Limits{MaxPathLen: options.MaxPathLength, MaxDirLen: options.MaxDirLength}, &logBuffer)
+	summary.PortabilityIssues = portability.Check(basePath, fileHashes, &logBuffer)
 
 	summary.ExecutionTime = time.Since(start)
 	logBuffer.Append(summary).Flush()
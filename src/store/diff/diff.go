@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"golang.org/x/exp/maps"
+)
+
+// Compare compares the integrity files of two directories without reading any file content.
+// The log is written into the integrity directory of basePath (A).
+func Compare(basePath string, otherPath string, options store.Options) {
+	dir.AssertIntegrityDir(basePath)
+	dir.AssertIntegrityDir(otherPath)
+	start := time.Now()
+	logBuffer := ilog.NewAutomaticLogBuffer(basePath, ilog.Diff, 10000, options.Log)
+	a := file.LoadContent(basePath).DefragmentedMap()
+	b := file.LoadContent(otherPath).DefragmentedMap()
+	report(a, b, start, &logBuffer)
+}
+
+// CompareBackup compares the backup of the timestamp (A) with the current integrity file (B) of the directory
+func CompareBackup(basePath string, timestamp string, options store.Options) error {
+	dir.AssertIntegrityDir(basePath)
+	start := time.Now()
+	backup, err := file.LoadBackup(basePath, timestamp)
+	if err != nil {
+		return err
+	}
+	logBuffer := ilog.NewAutomaticLogBuffer(basePath, ilog.Diff, 10000, options.Log)
+	report(backup.DefragmentedMap(), file.LoadContent(basePath).DefragmentedMap(), start, &logBuffer)
+	return nil
+}
+
+func report(a file.FileHashMap, b file.FileHashMap, start time.Time, logBuffer *ilog.LogFileBuffer) {
+	result := Analyze(a, b)
+
+	for _, pair := range result.Modified {
+		logBuffer.Append(ilog.DiffLog{Status: ilog.MODIFIED, RelativePath: pair.A.RelativePath})
+	}
+	for _, pair := range result.Moved {
+		logBuffer.Append(ilog.DiffLog{Status: ilog.MOVED, RelativePath: pair.A.RelativePath, TargetPath: pair.B.RelativePath})
+	}
+	for _, fh := range result.OnlyA {
+		logBuffer.Append(ilog.DiffLog{Status: ilog.ONLY_A, RelativePath: fh.RelativePath})
+	}
+	for _, fh := range result.OnlyB {
+		logBuffer.Append(ilog.DiffLog{Status: ilog.ONLY_B, RelativePath: fh.RelativePath})
+	}
+
+	logBuffer.Append(ilog.DiffSummary{
+		ExecutionTime: time.Since(start),
+		FilesA:        int64(len(a)),
+		FilesB:        int64(len(b)),
+		BytesA:        file.FileHashs(maps.Values(a)).TotalBytes(),
+		BytesB:        file.FileHashs(maps.Values(b)).TotalBytes(),
+		EqualFiles:    int64(len(result.Equal)),
+		OnlyAFiles:    int64(len(result.OnlyA)),
+		OnlyBFiles:    int64(len(result.OnlyB)),
+		ModifiedFiles: int64(len(result.Modified)),
+		MovedFiles:    int64(len(result.Moved)),
+	}).Flush()
+}
+
+// Analyze classifies the entries of two defragmented integrity files. Entries are matched by relative path
+// first. The remaining entries are matched by content to detect moved files. Empty directories and links have no
+// content, hence they are never considered moved.
+func Analyze(a file.FileHashMap, b file.FileHashMap) Result {
+	result := Result{}
+	unmatchedA := file.FileHashs{}
+	for _, fhA := range a {
+		fhB, exists := b[fhA.RelativePath]
+		if !exists && !movable(fhA) {
+			result.OnlyA = append(result.OnlyA, fhA)
+		} else if !exists {
+			unmatchedA = append(unmatchedA, fhA)
+		} else if fhA.Hash == fhB.Hash && fhA.Size == fhB.Size {
+			result.Equal = append(result.Equal, fhA)
+		} else {
+			result.Modified = append(result.Modified, Pair{A: fhA, B: fhB})
+		}
+	}
+
+	// Index unmatched entries of B by content, ordered by path for a deterministic move detection
+	unmatchedB := map[string]file.FileHashs{}
+	for _, fhB := range b {
+		if a.Has(fhB.RelativePath) {
+			continue
+		}
+		if !movable(fhB) {
+			result.OnlyB = append(result.OnlyB, fhB)
+		} else {
+			unmatchedB[key(fhB)] = append(unmatchedB[key(fhB)], fhB)
+		}
+	}
+	for _, fhs := range unmatchedB {
+		sort.Sort(fhs)
+	}
+	sort.Sort(unmatchedA)
+
+	for _, fhA := range unmatchedA {
+		candidates := unmatchedB[key(fhA)]
+		if len(candidates) == 0 {
+			result.OnlyA = append(result.OnlyA, fhA)
+			continue
+		}
+		result.Moved = append(result.Moved, Pair{A: fhA, B: candidates[0]})
+		unmatchedB[key(fhA)] = candidates[1:]
+	}
+	for _, fhs := range unmatchedB {
+		result.OnlyB = append(result.OnlyB, fhs...)
+	}
+
+	result.sort()
+	return result
+}
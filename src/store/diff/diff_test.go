@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	a := file.FileHashs{
+		{Hash: "h1", Size: 10, RelativePath: "equal.txt"},
+		{Hash: "h2", Size: 10, RelativePath: "modified.txt"},
+		{Hash: "h3", Size: 10, RelativePath: "old/moved.txt"},
+		{Hash: "h4", Size: 10, RelativePath: "only a.txt"},
+	}.DefragmentedMap()
+	b := file.FileHashs{
+		{Hash: "h1", Size: 10, RelativePath: "equal.txt"},
+		{Hash: "h5", Size: 12, RelativePath: "modified.txt"},
+		{Hash: "h3", Size: 10, RelativePath: "new/moved.txt"},
+		{Hash: "h6", Size: 10, RelativePath: "only b.txt"},
+	}.DefragmentedMap()
+
+	actual := Analyze(a, b)
+
+	assert.Equal(t, file.FileHashs{a["equal.txt"]}, actual.Equal)
+	assert.Equal(t, Pairs{{A: a["modified.txt"], B: b["modified.txt"]}}, actual.Modified)
+	assert.Equal(t, Pairs{{A: a["old/moved.txt"], B: b["new/moved.txt"]}}, actual.Moved)
+	assert.Equal(t, file.FileHashs{a["only a.txt"]}, actual.OnlyA)
+	assert.Equal(t, file.FileHashs{b["only b.txt"]}, actual.OnlyB)
+}
+
+func TestAnalyze_movedDuplicates(t *testing.T) {
+	a := file.FileHashs{
+		{Hash: "h1", Size: 10, RelativePath: "a1.txt"},
+		{Hash: "h1", Size: 10, RelativePath: "a2.txt"},
+	}.DefragmentedMap()
+	b := file.FileHashs{
+		{Hash: "h1", Size: 10, RelativePath: "b1.txt"},
+	}.DefragmentedMap()
+
+	actual := Analyze(a, b)
+
+	assert.Equal(t, Pairs{{A: a["a1.txt"], B: b["b1.txt"]}}, actual.Moved)
+	assert.Equal(t, file.FileHashs{a["a2.txt"]}, actual.OnlyA)
+	assert.Empty(t, actual.OnlyB)
+}
+
+func TestAnalyze_unrelatedDirsAndLinks(t *testing.T) {
+	a := file.FileHashs{
+		{Hash: hash.DirHash, RelativePath: "empty a", Dir: true},
+		{Hash: "l1", RelativePath: "link a", Target: "x.txt"},
+	}.DefragmentedMap()
+	b := file.FileHashs{
+		{Hash: hash.DirHash, RelativePath: "empty b", Dir: true},
+		{Hash: "l1", RelativePath: "link b", Target: "x.txt"},
+	}.DefragmentedMap()
+
+	actual := Analyze(a, b)
+
+	assert.Empty(t, actual.Moved)
+	assert.Equal(t, file.FileHashs{a["empty a"], a["link a"]}, actual.OnlyA)
+	assert.Equal(t, file.FileHashs{b["empty b"], b["link b"]}, actual.OnlyB)
+}
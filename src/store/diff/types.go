@@ -0,0 +1,47 @@
+package diff
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+)
+
+type Pair struct {
+	A file.FileHash
+	B file.FileHash
+}
+
+type Pairs []Pair
+
+func (ps Pairs) sort() {
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].A.RelativePath < ps[j].A.RelativePath
+	})
+}
+
+type Result struct {
+	Equal    file.FileHashs
+	OnlyA    file.FileHashs
+	OnlyB    file.FileHashs
+	Modified Pairs
+	Moved    Pairs
+}
+
+func (r *Result) sort() {
+	sort.Sort(r.Equal)
+	sort.Sort(r.OnlyA)
+	sort.Sort(r.OnlyB)
+	r.Modified.sort()
+	r.Moved.sort()
+}
+
+// Empty directories share the same hash and links are only equal by target, hence only files are matched by content
+func movable(fh file.FileHash) bool {
+	return !fh.Dir && !fh.IsLink()
+}
+
+// Files with an equal hash and size are considered to have an equal content
+func key(fh file.FileHash) string {
+	return fh.Hash + strconv.FormatInt(fh.Size, 10)
+}
@@ -0,0 +1,41 @@
+package dir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces the file atomically. The content is written into a temporary file, which is synced
+// and renamed afterwards, hence a crash leaves either the previous or the new file.
+func WriteFile(filename string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	return Commit(tmp, filename)
+}
+
+// Commit syncs and closes the written temporary file and renames it to the filename. The parent directory
+// is synced, so the rename survives a power loss. The temporary file is removed on failure.
+func Commit(tmp *os.File, filename string) error {
+	err := tmp.Sync()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return SyncDir(filepath.Dir(filename))
+}
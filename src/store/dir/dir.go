@@ -1,6 +1,7 @@
 package dir
 
 import (
+	"errors"
 	"log"
 	"os"
 	"path/filepath"
@@ -24,16 +25,50 @@ func AssertIntegrityDir(basePath string) {
 	AssertDir(filepath.Join(basePath, Name))
 }
 
+// CheckDir returns an error, if the path is not an existing directory
+func CheckDir(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return errors.New("directory does not exist: " + path)
+	} else if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory: " + path)
+	}
+	return nil
+}
+
+// CheckIntegrityDir returns an error, if the directory or its integrity directory does not exist
+func CheckIntegrityDir(basePath string) error {
+	if err := CheckDir(basePath); err != nil {
+		return err
+	}
+	if err := CheckDir(filepath.Join(basePath, Name)); err != nil {
+		return errors.New("integrity directory does not exist, upsert is required first: " + basePath)
+	}
+	return nil
+}
+
 func UpsertIntegrityDir(basePath string) {
+	if err := CreateIntegrityDir(basePath); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// CreateIntegrityDir creates the hidden integrity directory, if it does not exist yet. A directory created
+// concurrently by another process is used as well.
+func CreateIntegrityDir(basePath string) error {
 	path := filepath.Join(basePath, Name)
 	if info, err := os.Stat(path); os.IsNotExist(err) {
-		if err := os.Mkdir(path, 0644); err != nil {
-			log.Fatal("could not create integrity dir", err)
+		if err := os.Mkdir(path, 0644); err != nil && !os.IsExist(err) {
+			return errors.New("could not create integrity dir: " + err.Error())
 		}
 		if err = hideFile(path); err != nil {
-			log.Fatal("could not hide integrity dir", err)
+			return errors.New("could not hide integrity dir: " + err.Error())
 		}
 	} else if err != nil || !info.IsDir() {
-		log.Fatal("could not ensure integrity dir", err)
+		return errors.New("could not ensure integrity dir: " + path)
 	}
+	return nil
 }
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package dir
+
+import "os"
+
+// SyncDir flushes the directory entries, e.g. after a rename
+func SyncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package dir
+
+// SyncDir is not supported on windows, where renames are flushed by the file system
+func SyncDir(path string) error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package file
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+const backupSuffix = name + ".zip"
+
+// BackupInfo describes a backup of the integrity file, which is identified by the timestamp of the
+// modification time of the backed up file, e.g. 240301.120000
+type BackupInfo struct {
+	Timestamp string
+	Modified  time.Time
+	Size      int64 // Size of the zip archive
+}
+
+// Backups lists the backups within the integrity directory ordered by timestamp
+func Backups(basePath string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(basePath, dir.Name))
+	if err != nil {
+		return nil, err
+	}
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		timestamp, found := strings.CutSuffix(entry.Name(), backupSuffix)
+		if !found || entry.IsDir() {
+			continue
+		}
+		modified, err := time.ParseInLocation(ilog.TimeFormat, timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, BackupInfo{Timestamp: timestamp, Modified: modified, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Modified.Before(backups[j].Modified)
+	})
+	return backups, nil
+}
+
+// LoadBackup reads and validates the integrity file within the backup of the timestamp. The checksum of the
+// archive and all entries are validated.
+func LoadBackup(basePath string, timestamp string) (FileHashs, error) {
+	content, err := readBackup(basePath, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return parseBackup(content)
+}
+
+// RestoreBackup replaces the integrity file by the validated backup of the timestamp. The current integrity
+// file is backed up before, hence a restore can be reverted. Backups of legacy integrity files are restored
+// with canonical relative paths. Chunk hashes and recovery data are not restored.
+func RestoreBackup(basePath string, timestamp string) error {
+	fileHashs, err := LoadBackup(basePath, timestamp)
+	if err != nil {
+		return err
+	}
+	Backup(basePath)
+	mu.Lock()
+	defer mu.Unlock()
+	return replace(basePath, fileHashs)
+}
+
+func readBackup(basePath string, timestamp string) ([]byte, error) {
+	zipFilename := filepath.Join(basePath, dir.Name, timestamp+backupSuffix)
+	r, err := zip.OpenReader(zipFilename)
+	if os.IsNotExist(err) {
+		return nil, errors.New("backup does not exist: " + timestamp)
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", timestamp, err)
+	}
+	defer r.Close()
+	if len(r.File) != 1 || r.File[0].Name != name {
+		return nil, fmt.Errorf("invalid backup %s: unexpected content", timestamp)
+	}
+	f, err := r.File[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", timestamp, err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", timestamp, err)
+	}
+	return content, nil
+}
+
+// Backups do not contain the format file, hence legacy integrity files are detected by their content
+func parseBackup(content []byte) (FileHashs, error) {
+	d := detectDecoder(bytes.NewReader(content))
+	fileHashes := FileHashs{}
+	reader := newCSVReader(bytes.NewReader(content))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return fileHashes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup entry: %w", err)
+		}
+		fileHash, err := unmarshal(record, d)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup entry: %w", err)
+		}
+		fileHashes = append(fileHashes, fileHash)
+	}
+}
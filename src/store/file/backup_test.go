@@ -0,0 +1,53 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreBackup(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	now := time.Now().UTC().Truncate(time.Second)
+	original := FileHashs{{Hash: "a", Created: now, ModTime: now, Size: 1, RelativePath: "a.txt"}}
+	Append(basePath, original)
+	modified := now.Add(-time.Hour)
+	os.Chtimes(filename(basePath), modified, modified)
+	Backup(basePath)
+
+	backups, err := Backups(basePath)
+	assert.Nil(t, err)
+	assert.Len(t, backups, 1)
+	timestamp := modified.Local().Format(ilog.TimeFormat)
+	assert.Equal(t, timestamp, backups[0].Timestamp)
+	loaded, err := LoadBackup(basePath, timestamp)
+	assert.Nil(t, err)
+	assert.Equal(t, original, loaded)
+
+	Append(basePath, FileHashs{{Hash: "b", Created: now, ModTime: now, Size: 1, RelativePath: "b.txt"}})
+	assert.Nil(t, RestoreBackup(basePath, timestamp))
+	assert.Equal(t, original, LoadContent(basePath))
+
+	// The replaced integrity file is backed up
+	backups, _ = Backups(basePath)
+	assert.Len(t, backups, 2)
+	restored, _ := LoadBackup(basePath, backups[1].Timestamp)
+	assert.Len(t, restored, 2)
+}
+
+func TestRestoreBackup_invalid(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	Append(basePath, FileHashs{{Hash: "a", RelativePath: "a.txt"}})
+	os.WriteFile(filepath.Join(basePath, dir.Name, "240101.120000"+backupSuffix), []byte("no zip"), 0644)
+
+	assert.ErrorContains(t, RestoreBackup(basePath, "240101.120000"), "invalid backup")
+	assert.ErrorContains(t, RestoreBackup(basePath, "240102.120000"), "backup does not exist")
+	assert.Len(t, LoadContent(basePath), 1)
+}
@@ -0,0 +1,134 @@
+package file
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entries are serialized as csv rows without headers: hash, created, mod, size, relativePath. Optional
+// attributes are appended as key=value columns, hence entries without attributes keep five columns.
+const columns = 5
+
+const (
+	inodeAttribute  = "inode"
+	targetAttribute = "target"
+	modeAttribute   = "mode"
+	uidAttribute    = "uid"
+	gidAttribute    = "gid"
+	xattrsAttribute = "xattrs"
+	typeAttribute   = "type"
+)
+
+// Type of empty directory entries, files have no type attribute
+const dirType = "dir"
+
+type writer struct {
+	buf *bufio.Writer
+	csv *csv.Writer
+}
+
+func newWriter(w io.Writer) *writer {
+	buf := bufio.NewWriter(w)
+	return &writer{buf: buf, csv: csv.NewWriter(buf)}
+}
+
+func (w *writer) Write(fileHash FileHash) error {
+	return w.csv.Write(marshal(fileHash))
+}
+
+func (w *writer) Flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return reader
+}
+
+func marshal(fh FileHash) []string {
+	record := []string{
+		fh.Hash,
+		fh.Created.Format(time.RFC3339Nano),
+		fh.ModTime.Format(time.RFC3339Nano),
+		strconv.FormatInt(fh.Size, 10),
+		fh.RelativePath,
+	}
+	record = appendAttribute(record, inodeAttribute, fh.Inode)
+	record = appendAttribute(record, targetAttribute, fh.Target)
+	record = appendAttribute(record, modeAttribute, fh.Metadata.Mode)
+	record = appendAttribute(record, uidAttribute, fh.Metadata.UID)
+	record = appendAttribute(record, gidAttribute, fh.Metadata.GID)
+	record = appendAttribute(record, xattrsAttribute, fh.Metadata.Xattrs)
+	if fh.Dir {
+		record = appendAttribute(record, typeAttribute, dirType)
+	}
+	return record
+}
+
+// Empty attributes are omitted
+func appendAttribute(record []string, key string, value string) []string {
+	if value == "" {
+		return record
+	}
+	return append(record, key+"="+value)
+}
+
+func unmarshal(record []string, d decoder) (FileHash, error) {
+	if len(record) < columns {
+		return FileHash{}, errors.New("invalid number of columns")
+	}
+	created, err := time.Parse(time.RFC3339Nano, record[1])
+	if err != nil {
+		return FileHash{}, err
+	}
+	modTime, err := time.Parse(time.RFC3339Nano, record[2])
+	if err != nil {
+		return FileHash{}, err
+	}
+	size, err := strconv.ParseInt(record[3], 10, 64)
+	if err != nil {
+		return FileHash{}, err
+	}
+	fileHash := FileHash{
+		Hash:         record[0],
+		Created:      created,
+		ModTime:      modTime,
+		Size:         size,
+		RelativePath: d.relativePath(record[4]),
+	}
+	// Unknown attributes are ignored to read stores of newer versions
+	for _, attribute := range record[columns:] {
+		key, value, ok := strings.Cut(attribute, "=")
+		if !ok {
+			return FileHash{}, errors.New("invalid attribute: " + attribute)
+		}
+		switch key {
+		case inodeAttribute:
+			fileHash.Inode = value
+		case targetAttribute:
+			fileHash.Target = value
+		case modeAttribute:
+			fileHash.Metadata.Mode = value
+		case uidAttribute:
+			fileHash.Metadata.UID = value
+		case gidAttribute:
+			fileHash.Metadata.GID = value
+		case xattrsAttribute:
+			fileHash.Metadata.Xattrs = value
+		case typeAttribute:
+			fileHash.Dir = value == dirType
+		}
+	}
+	return fileHash, nil
+}
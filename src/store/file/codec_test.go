@@ -0,0 +1,59 @@
+package file
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshal(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name    string
+		in      FileHash
+		columns int
+	}{
+		{
+			name:    "Without attributes",
+			in:      FileHash{Hash: "a", Created: now, ModTime: now, Size: 1, RelativePath: "a.txt"},
+			columns: 5,
+		},
+		{
+			name:    "With inode",
+			in:      FileHash{Hash: "a", Created: now, ModTime: now, Size: 1, RelativePath: "a.txt", Inode: "2049:1234"},
+			columns: 6,
+		},
+		{
+			name:    "With metadata",
+			in:      FileHash{Hash: "a", Created: now, ModTime: now, Size: 1, RelativePath: "a.txt", Metadata: meta.Metadata{Mode: "0644", UID: "1000", GID: "1000", Xattrs: "user.a:1a2b|user.b:"}},
+			columns: 9,
+		},
+		{
+			name:    "Empty directory",
+			in:      FileHash{Hash: "a", Created: now, ModTime: now, RelativePath: "a", Dir: true},
+			columns: 6,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			record := marshal(c.in)
+			assert.Equal(t, c.columns, len(record))
+			actual, err := unmarshal(record, decoder{})
+			assert.NoError(t, err)
+			assert.True(t, c.in.Equal(actual), "%v != %v", c.in, actual)
+		})
+	}
+}
+
+func TestUnmarshal_attributes(t *testing.T) {
+	record := []string{"a", "2023-05-06T15:12:00+02:00", "2022-05-06T00:40:21+02:00", "1", "a=b.txt", "unknown=value", "inode=1:2"}
+	actual, err := unmarshal(record, decoder{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a=b.txt", actual.RelativePath)
+	assert.Equal(t, "1:2", actual.Inode)
+
+	_, err = unmarshal(append(record, "invalid"), decoder{})
+	assert.Error(t, err)
+}
@@ -2,18 +2,15 @@ package file
 
 import (
 	"archive/zip"
+	"bytes"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
 	"sync"
 
 	"github.com/aicirt2012/fileintegrity/src/store/dir"
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
-	"github.com/mitchellh/hashstructure/v2"
-
-	"github.com/gocarina/gocsv"
-	"golang.org/x/exp/maps"
 )
 
 const name string = ".integrity"
@@ -27,48 +24,75 @@ func LoadContent(basePath string) FileHashs {
 func Append(basePath string, fileHashs FileHashs) {
 	mu.Lock()
 	defer mu.Unlock()
+	if legacy(basePath) {
+		migrate(basePath)
+	}
 	f := openOrCreateFile(basePath)
 	defer f.Close()
-	line, err := gocsv.MarshalStringWithoutHeaders(&fileHashs)
-	if err != nil {
-		log.Fatal("could not serialize file hash", err)
+	w := newWriter(f)
+	for _, fileHash := range fileHashs {
+		if err := w.Write(fileHash); err != nil {
+			log.Fatal("could not serialize file hash", err)
+		}
 	}
-	_, err = f.WriteString(line)
-	if err != nil {
+	if err := w.Flush(); err != nil {
 		log.Fatal("could not write integrity file", err)
 	}
+	if err := f.Sync(); err != nil {
+		log.Fatal("could not sync integrity file", err)
+	}
 }
 
 // During execution new hashes are only appended in the integrity file due to performance reasons.
-// This may leads to duplicate entries which are eliminated in a final step.
+// This may leads to duplicate entries which are eliminated in a final step. The sorted runs of the
+// integrity file are merged into a temporary file, which replaces the integrity file afterwards.
 func Defragment(basePath string) {
 	mu.Lock()
 	defer mu.Unlock()
-	filename := filepath.Join(basePath, dir.Name, name)
-	fileHashs := loadContentInternal(basePath, false)
-	hashsBefore := hash(fileHashs)
+	if legacy(basePath) {
+		migrate(basePath)
+		return
+	}
+	defragment(basePath, false)
+}
 
-	// Remove duplicated and deleted file entries
-	m := fileHashs.DefragmentedMap()
-	uniqueFileHashes := maps.Values(m)
-	sort.Sort(FileHashs(uniqueFileHashes))
+// Rewrites the legacy integrity file with canonical relative paths and marks its format afterwards
+func migrate(basePath string) {
+	defragment(basePath, true)
+	if err := writeFormat(basePath); err != nil {
+		log.Fatal("could not write format of integrity file", err)
+	}
+}
+
+func defragment(basePath string, force bool) {
+	r := newReader(basePath)
+	defer r.Close()
 
 	// Detect unchanged content to prevent change of modification date
-	if hashsBefore == hash(uniqueFileHashes) {
+	if r.Defragmented() && !force {
 		return
 	}
 
-	// Override existing file with new serialized content
-	integrityFile, err := os.Create(filename)
+	tmpFilename := filename(basePath) + ".tmp"
+	tmpFile, err := os.Create(tmpFilename)
 	if err != nil {
 		log.Fatal("could not open integrity file", err)
 	}
-	defer integrityFile.Close()
-
-	err = gocsv.MarshalWithoutHeaders(&uniqueFileHashes, integrityFile)
-	if err != nil {
+	defer os.Remove(tmpFilename)
+	defer tmpFile.Close()
+	w := newWriter(tmpFile)
+	for fileHash, ok := r.Next(); ok; fileHash, ok = r.Next() {
+		if err := w.Write(fileHash); err != nil {
+			log.Fatal("could not serialize integrity file", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
 		log.Fatal("could not serialize integrity file", err)
 	}
+	r.Close()
+	if err := dir.Commit(tmpFile, filename(basePath)); err != nil {
+		log.Fatal("could not replace integrity file", err)
+	}
 }
 
 func Backup(basePath string) {
@@ -79,31 +103,37 @@ func Backup(basePath string) {
 	if os.IsNotExist(err) {
 		return // if not exist, an backup is not required
 	}
-	integrityFileContent, err := os.ReadFile(integrityFilename)
+	integrityFile, err := os.Open(integrityFilename)
 	if err != nil {
 		log.Fatal("could not backup integrity file", err)
 	}
+	defer integrityFile.Close()
 
 	zipFilename := filepath.Join(basePath, dir.Name,
 		integrityInfo.ModTime().Format(ilog.TimeFormat)+name+".zip")
-	zipFile, err := os.Create(zipFilename)
+	zipFile, err := os.CreateTemp(filepath.Dir(zipFilename), filepath.Base(zipFilename)+".*.tmp")
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer os.Remove(zipFile.Name())
 	defer zipFile.Close()
 
 	wr := zip.NewWriter(zipFile)
-	defer wr.Close()
-
 	f, err := wr.Create(name)
 	if err != nil {
 		log.Fatal("could not add integrity file to zip", err)
 	}
 
-	_, err = f.Write(integrityFileContent)
+	_, err = io.Copy(f, integrityFile)
 	if err != nil {
 		log.Fatal("could not write data to the integrity zip file", err)
 	}
+	if err := wr.Close(); err != nil {
+		log.Fatal("could not write the integrity zip file", err)
+	}
+	if err := dir.Commit(zipFile, zipFilename); err != nil {
+		log.Fatal("could not write the integrity zip file", err)
+	}
 }
 
 func loadContentInternal(basePath string, lock bool) FileHashs {
@@ -114,35 +144,123 @@ func loadContentInternal(basePath string, lock bool) FileHashs {
 	fileHashes := FileHashs{}
 	f := openOrCreateFile(basePath)
 	defer f.Close()
-	info, err := f.Stat()
-	if err != nil {
-		log.Fatal("could not get stats of integrity file", err)
-	}
-	if info.Size() > 0 {
-		err = gocsv.UnmarshalWithoutHeaders(f, &fileHashes)
+	d := newDecoder(basePath)
+	reader := newCSVReader(f)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal("could not deserialize integrity file, check it with fsck: ", err)
+		}
+		fileHash, err := unmarshal(record, d)
 		if err != nil {
-			log.Fatal("could not deserialize integrity file", err)
+			log.Fatal("could not deserialize integrity file, check it with fsck: ", err)
 		}
+		fileHashes = append(fileHashes, fileHash)
 	}
 	return fileHashes
 }
 
 func openOrCreateFile(basePath string) *os.File {
-	filename := filepath.Join(basePath, dir.Name, name)
+	filename := filename(basePath)
 	f, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND, 0644)
 	if os.IsNotExist(err) {
 		f, err = os.Create(filename)
+		if err == nil {
+			err = writeFormat(basePath)
+		}
+		if err == nil {
+			err = dir.SyncDir(filepath.Dir(filename))
+		}
 	}
 	if err != nil {
 		log.Fatal("could not create or open integrity file", err)
 	}
+	if err := recoverTornLine(f); err != nil {
+		log.Fatal("could not recover integrity file", err)
+	}
 	return f
 }
 
-func hash(i interface{}) uint64 {
-	hash, err := hashstructure.Hash(i, hashstructure.FormatV2, nil)
-	if err != nil {
-		log.Fatal("could not hash", err)
+// Removes an incomplete last entry of an interrupted append. A line break within a quoted path does not end an
+// entry, hence the tail is parsed as csv from the first entry start, which leads to complete entries. Entries
+// are at most maxRecordSize long, hence the tail contains at least one entry start.
+func recoverTornLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	end := info.Size()
+	start := max(end-2*maxRecordSize, 0)
+	tail := make([]byte, end-start)
+	if _, err := f.ReadAt(tail, start); err != nil {
+		return err
+	}
+	for i := range tail {
+		if i == 0 && start > 0 || i > 0 && tail[i-1] != '\n' {
+			continue
+		}
+		if valid, ok := completeEntries(tail[i:]); ok {
+			return truncate(f, end, start+int64(i)+valid)
+		}
+	}
+	// Malformed entries are kept for fsck, only an incomplete last line is removed
+	return truncate(f, end, lastLineEnd(f, end))
+}
+
+// Returns the end of the last line, which ends with a line break
+func lastLineEnd(f *os.File, end int64) int64 {
+	buf := make([]byte, 4096)
+	for offset := end; offset > 0; {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := f.ReadAt(buf[:n], offset); err != nil {
+			return end
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return offset + int64(i) + 1
+		}
+	}
+	return 0
+}
+
+// Returns the length of the complete entries and false, if an entry except the last one is malformed
+func completeEntries(data []byte) (int64, bool) {
+	reader := newCSVReader(bytes.NewReader(data))
+	valid := int64(0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return valid, true
+		}
+		offset := reader.InputOffset()
+		if err == nil {
+			_, err = unmarshal(record, decoder{})
+		}
+		if err == nil && data[offset-1] == '\n' {
+			valid = offset
+			continue
+		}
+		// The malformed entry is torn, if it is the last one
+		if _, err := reader.Read(); err != io.EOF {
+			return 0, false
+		}
+		return valid, true
+	}
+}
+
+func truncate(f *os.File, size int64, valid int64) error {
+	if valid == size {
+		return nil
+	}
+	log.Printf("removed %d bytes of an incomplete entry at the end of the integrity file", size-valid)
+	if err := f.Truncate(valid); err != nil {
+		return err
 	}
-	return hash
+	return f.Sync()
 }
@@ -0,0 +1,73 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppend_tornLine(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	a := FileHash{Hash: "a", Created: now, ModTime: now, Size: 1, RelativePath: "a.txt"}
+	b := FileHash{Hash: "b", Created: now, ModTime: now, Size: 1, RelativePath: "b.txt"}
+	cases := []struct {
+		name string
+		torn string
+	}{
+		{name: "Partial entry", torn: "c,2024-01-01T00:00:00Z,2024-01"},
+		{name: "Zero filled tail", torn: strings.Repeat("\x00", 5000)},
+		{name: "Partial quoted path", torn: "c,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,\"c\nc"},
+		{name: "Partial quoted path ending with line break", torn: "c,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,\"c\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			basePath := t.TempDir()
+			os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+			Append(basePath, FileHashs{a})
+			f, _ := os.OpenFile(filename(basePath), os.O_WRONLY|os.O_APPEND, 0644)
+			f.WriteString(c.torn)
+			f.Close()
+
+			Append(basePath, FileHashs{b})
+
+			content, _ := os.ReadFile(filename(basePath))
+			assert.True(t, strings.HasSuffix(string(content), "\n"))
+			assert.Equal(t, FileHashs{a, b}, LoadContent(basePath))
+		})
+	}
+}
+
+func TestAppend_tornLineAfterQuotedPath(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	now := time.Now().UTC().Truncate(time.Second)
+	a := FileHash{Hash: "a", Created: now, ModTime: now, Size: 1, RelativePath: "a\n\"a\".txt"}
+	b := FileHash{Hash: "b", Created: now, ModTime: now, Size: 1, RelativePath: "b.txt"}
+	Append(basePath, FileHashs{a})
+	f, _ := os.OpenFile(filename(basePath), os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString("c,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,\"c\n")
+	f.Close()
+
+	Append(basePath, FileHashs{b})
+
+	assert.Equal(t, FileHashs{a, b}, LoadContent(basePath))
+}
+
+func TestDefragment_atomic(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	now := time.Now().UTC().Truncate(time.Second)
+	Append(basePath, FileHashs{{Hash: "b", Created: now, ModTime: now, RelativePath: "b.txt"}})
+	Append(basePath, FileHashs{{Hash: "a", Created: now, ModTime: now, RelativePath: "a.txt"}})
+
+	Defragment(basePath)
+
+	assert.Len(t, LoadContent(basePath), 2)
+	entries, _ := os.ReadDir(filepath.Join(basePath, dir.Name))
+	assert.Len(t, entries, 2) // Integrity file and format file without temporary files
+}
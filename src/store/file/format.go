@@ -0,0 +1,79 @@
+package file
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+// Integrity files of former versions store relative paths with the separator and the Unicode normalisation of
+// the creating platform. The format file .integrity/format marks integrity files, which store relative paths
+// canonically with forward slashes and NFC. Legacy integrity files are converted while reading and migrated by
+// the next write, hence the same store verifies on every platform.
+const formatFilename = "format"
+const formatVersion = "2"
+
+// Converts stored relative paths into the canonical form
+type decoder struct {
+	backslash bool // Backslashes are separators of windows
+}
+
+func (d decoder) relativePath(relativePath string) string {
+	if d.backslash {
+		relativePath = strings.ReplaceAll(relativePath, `\`, string(path.Separator))
+	}
+	return path.Canonical(relativePath)
+}
+
+// Returns the decoder of the integrity file, legacy integrity files are detected by their content
+func newDecoder(basePath string) decoder {
+	if !legacy(basePath) {
+		return decoder{}
+	}
+	f, err := os.Open(filename(basePath))
+	if err != nil {
+		return decoder{}
+	}
+	defer f.Close()
+	return detectDecoder(f)
+}
+
+// Windows prohibits slashes in names, linux stores of nested directories contain slashes. Hence, backslashes
+// are separators, if no relative path contains a slash.
+func detectDecoder(r io.Reader) decoder {
+	reader := newCSVReader(r)
+	backslash := false
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			return decoder{backslash: backslash}
+		}
+		if len(record) < columns {
+			continue
+		}
+		if strings.ContainsRune(record[4], '/') {
+			return decoder{}
+		}
+		backslash = backslash || strings.ContainsRune(record[4], '\\')
+	}
+}
+
+// Returns true, if the integrity file exists without format file
+func legacy(basePath string) bool {
+	if _, err := os.Stat(formatFile(basePath)); err == nil {
+		return false
+	}
+	return Exists(basePath)
+}
+
+func writeFormat(basePath string) error {
+	return dir.WriteFile(formatFile(basePath), []byte(formatVersion+"\n"))
+}
+
+func formatFile(basePath string) string {
+	return filepath.Join(basePath, dir.Name, formatFilename)
+}
@@ -0,0 +1,62 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectDecoder(t *testing.T) {
+	cases := []struct {
+		name      string
+		paths     []string
+		backslash bool
+	}{
+		{name: "Windows", paths: []string{`a\a1.txt`, "b.txt"}, backslash: true},
+		{name: "Ambiguous", paths: []string{`a\a1.txt`}, backslash: true},
+		{name: "Linux", paths: []string{`a\a1.txt`, "b/b1.txt"}, backslash: false},
+		{name: "Flat", paths: []string{"a.txt", "b.txt"}, backslash: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			content := ""
+			for _, p := range c.paths {
+				content += "h,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1," + p + "\n"
+			}
+			assert.Equal(t, decoder{backslash: c.backslash}, detectDecoder(strings.NewReader(content)))
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	legacyContent := "a,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,b.txt\n" +
+		"b,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,a\\e\u0301.txt\n"
+	os.WriteFile(filename(basePath), []byte(legacyContent), 0644)
+	created, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+	nfc := FileHash{Hash: "b", Created: created, ModTime: created, Size: 1, RelativePath: "a/\u00e9.txt"}
+
+	// Legacy integrity files are converted while reading without change
+	assert.True(t, legacy(basePath))
+	assert.Contains(t, LoadContent(basePath), nfc)
+	content, _ := os.ReadFile(filename(basePath))
+	assert.Equal(t, legacyContent, string(content))
+
+	Append(basePath, FileHashs{{Hash: "c", Created: created, ModTime: created, Size: 1, RelativePath: "c.txt"}})
+
+	assert.False(t, legacy(basePath))
+	content, _ = os.ReadFile(filename(basePath))
+	assert.NotContains(t, string(content), `\`)
+	assert.Contains(t, string(content), "a/\u00e9.txt")
+	assert.Equal(t, 3, len(LoadContent(basePath)))
+	r := NewReader(basePath)
+	defer r.Close()
+	first, _ := r.Next()
+	assert.Equal(t, nfc, first)
+}
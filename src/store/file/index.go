@@ -0,0 +1,139 @@
+package file
+
+import (
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+)
+
+// Number of entries of a sorted run per indexed entry, bounds the entries read by a lookup within each run
+const indexStride = 128
+
+// Index locates entries within the sorted runs of the integrity file. Every indexStride-th entry of each run is
+// kept with its offset, hence a lookup reads only the entries near the relative path instead of the whole
+// integrity file. Update indexes the appended runs only, a replaced integrity file, e.g. by a defragmentation,
+// is indexed again.
+type Index struct {
+	basePath string
+	decoder  decoder
+	info     os.FileInfo
+	runs     []indexedRun
+	previous string // Relative path of the last indexed entry
+	count    int    // Number of entries of the last run
+}
+
+type indexedRun struct {
+	keys []indexKey // The first key is the start of the run
+	end  int64
+}
+
+type indexKey struct {
+	relativePath string
+	offset       int64
+}
+
+// NewIndex creates the index of the integrity file, which is read by the first update
+func NewIndex(basePath string) *Index {
+	return &Index{basePath: basePath}
+}
+
+// Update indexes the entries appended since the last update
+func (ix *Index) Update() {
+	mu.Lock()
+	defer mu.Unlock()
+	f := openOrCreateFile(ix.basePath)
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Fatal("could not read integrity file", err)
+	}
+	if ix.info == nil || !os.SameFile(ix.info, info) || info.Size() < ix.end() {
+		*ix = Index{basePath: ix.basePath, decoder: newDecoder(ix.basePath)}
+	}
+	ix.info = info
+	start := ix.end()
+	reader := newCSVReader(io.NewSectionReader(f, start, info.Size()-start))
+	for {
+		offset := start + reader.InputOffset()
+		record, err := reader.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatal("could not deserialize integrity file, check it with fsck: ", err)
+		}
+		if len(record) < columns {
+			log.Fatal("could not deserialize integrity file, check it with fsck: invalid number of columns")
+		}
+		relativePath := ix.decoder.relativePath(record[4])
+		if len(ix.runs) == 0 || path.Compare(relativePath, ix.previous) < 0 {
+			ix.runs = append(ix.runs, indexedRun{})
+			ix.count = 0
+		}
+		run := &ix.runs[len(ix.runs)-1]
+		if ix.count%indexStride == 0 {
+			run.keys = append(run.keys, indexKey{relativePath: relativePath, offset: offset})
+		}
+		run.end = start + reader.InputOffset()
+		ix.previous = relativePath
+		ix.count++
+	}
+}
+
+// Lookup returns the defragmented entries of the relative paths like Reader. Trees include all entries below
+// the relative path.
+func (ix *Index) Lookup(relativePaths map[string]bool) map[string]FileHash {
+	mu.Lock()
+	defer mu.Unlock()
+	entries := map[string]FileHash{}
+	if len(ix.runs) == 0 {
+		return entries
+	}
+	f, err := os.Open(filename(ix.basePath))
+	if err != nil {
+		log.Fatal("could not open integrity file", err)
+	}
+	defer f.Close()
+	for relativePath, tree := range relativePaths {
+		r := ix.reader(f, relativePath)
+		for fileHash, ok := r.Next(); ok; fileHash, ok = r.Next() {
+			c := path.Compare(fileHash.RelativePath, relativePath)
+			if c < 0 {
+				continue
+			}
+			if c > 0 && !(tree && strings.HasPrefix(fileHash.RelativePath, relativePath+string(path.Separator))) {
+				break
+			}
+			entries[fileHash.RelativePath] = fileHash
+		}
+	}
+	return entries
+}
+
+// Returns a reader of the runs starting at the last indexed entry before the relative path. Entries before the
+// relative path are not resolved across all runs.
+func (ix *Index) reader(f *os.File, relativePath string) *Reader {
+	r := &Reader{}
+	for _, ir := range ix.runs {
+		i := sort.Search(len(ir.keys), func(i int) bool {
+			return path.Compare(ir.keys[i].relativePath, relativePath) >= 0
+		})
+		start := ir.keys[max(i-1, 0)].offset
+		rr := &run{csv: newCSVReader(io.NewSectionReader(f, start, ir.end-start)), decoder: ix.decoder}
+		rr.advance()
+		r.runs = append(r.runs, rr)
+	}
+	return r
+}
+
+// Returns the end of the indexed entries
+func (ix *Index) end() int64 {
+	if len(ix.runs) == 0 {
+		return 0
+	}
+	return ix.runs[len(ix.runs)-1].end
+}
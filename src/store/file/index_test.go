@@ -0,0 +1,57 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex_lookup(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	now := time.Now().UTC().Truncate(time.Second)
+	entry := func(hash string, created int, relativePath string) FileHash {
+		return FileHash{Hash: hash, Created: now.Add(time.Duration(created) * time.Second), ModTime: now, Size: 1, RelativePath: relativePath}
+	}
+	fileHashes := FileHashs{}
+	for i := 0; i < 3*indexStride; i++ {
+		fileHashes = append(fileHashes, entry("a", 0, fmt.Sprintf("a/%04d.txt", i)))
+	}
+	fileHashes = append(fileHashes, entry("b", 0, "b/b1.txt"), entry("b", 0, "b/b2.txt"), entry("b", 0, "b.txt"))
+	Append(basePath, fileHashes)
+	index := NewIndex(basePath)
+	index.Update()
+
+	// Appended runs are indexed by the update
+	Append(basePath, FileHashs{entry("x", 1, "a/0200.txt"), entry(EmptyHash, 1, "a/0300.txt"), entry("c", 1, "c.txt")})
+	index.Update()
+	assert.Len(t, index.runs, 2)
+
+	lookup := map[string]bool{"a/0200.txt": false, "a/0300.txt": false, "b": true, "c.txt": false, "d.txt": false}
+	expected := map[string]FileHash{
+		"a/0200.txt": entry("x", 1, "a/0200.txt"),
+		"b/b1.txt":   entry("b", 0, "b/b1.txt"),
+		"b/b2.txt":   entry("b", 0, "b/b2.txt"),
+		"c.txt":      entry("c", 1, "c.txt"),
+	}
+	assert.Equal(t, expected, index.Lookup(lookup))
+
+	// A defragmented integrity file is indexed again
+	Defragment(basePath)
+	index.Update()
+	assert.Len(t, index.runs, 1)
+	assert.Equal(t, expected, index.Lookup(lookup))
+
+	// All entries of a tree are found across the indexed entries
+	tree := index.Lookup(map[string]bool{"a": true})
+	assert.Len(t, tree, 3*indexStride-1)
+	for relativePath := range tree {
+		assert.True(t, strings.HasPrefix(relativePath, "a/"))
+	}
+}
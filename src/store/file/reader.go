@@ -0,0 +1,191 @@
+package file
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"golang.org/x/exp/maps"
+)
+
+// Stores with more sorted runs are defragmented in memory, e.g. integrity files of former versions
+const maxRuns = 64
+
+// Iterator provides entries one by one
+type Iterator interface {
+	Next() (FileHash, bool)
+}
+
+// Reader streams the defragmented entries of the integrity file ordered by relative path with bounded memory.
+// The integrity file consists of sorted runs, the defragmented content and the appended entries of each
+// execution. The runs are merged, duplicated entries are resolved by the creation date and deleted entries are
+// skipped. Entries appended after the creation of the reader are not considered.
+type Reader struct {
+	file     *os.File
+	runs     []*run
+	clean    bool
+	fallback Iterator
+}
+
+type section struct {
+	start int64
+	end   int64
+}
+
+type run struct {
+	csv     *csv.Reader
+	decoder decoder
+	head    FileHash
+	ok      bool
+}
+
+func NewReader(basePath string) *Reader {
+	mu.Lock()
+	defer mu.Unlock()
+	return newReader(basePath)
+}
+
+func newReader(basePath string) *Reader {
+	d := newDecoder(basePath)
+	f := openOrCreateFile(basePath)
+	sections, clean := scanRuns(f, d)
+	if len(sections) > maxRuns {
+		f.Close()
+		fileHashes := maps.Values(loadContentInternal(basePath, false).DefragmentedMap())
+		sort.Slice(fileHashes, func(i, j int) bool {
+			return path.Compare(fileHashes[i].RelativePath, fileHashes[j].RelativePath) < 0
+		})
+		return &Reader{fallback: FileHashs(fileHashes).Iterator()}
+	}
+	r := &Reader{file: f, clean: clean}
+	for _, s := range sections {
+		rr := &run{csv: newCSVReader(io.NewSectionReader(f, s.start, s.end-s.start)), decoder: d}
+		rr.advance()
+		r.runs = append(r.runs, rr)
+	}
+	return r
+}
+
+// Next returns the entry with the next relative path
+func (r *Reader) Next() (FileHash, bool) {
+	if r.fallback != nil {
+		return r.fallback.Next()
+	}
+	for {
+		var next *FileHash
+		for _, rr := range r.runs {
+			if rr.ok && (next == nil || path.Compare(rr.head.RelativePath, next.RelativePath) < 0) {
+				next = &rr.head
+			}
+		}
+		if next == nil {
+			return FileHash{}, false
+		}
+		// Resolve all entries of the relative path like DefragmentedMap
+		relativePath := next.RelativePath
+		var winner FileHash
+		found, deleted := false, false
+		for _, rr := range r.runs {
+			for rr.ok && rr.head.RelativePath == relativePath {
+				if !found || rr.head.Created.After(winner.Created) {
+					winner = rr.head
+					found = true
+				}
+				deleted = deleted || rr.head.Hash == EmptyHash
+				rr.advance()
+			}
+		}
+		if !deleted {
+			return winner, true
+		}
+	}
+}
+
+func (r *Reader) Close() {
+	if r.file != nil {
+		r.file.Close()
+	}
+}
+
+// Defragmented reports whether the integrity file consists of a single sorted run without duplicated or
+// deleted entries
+func (r *Reader) Defragmented() bool {
+	return r.file != nil && len(r.runs) <= 1 && r.clean
+}
+
+func (rr *run) advance() {
+	record, err := rr.csv.Read()
+	if err == io.EOF {
+		rr.ok = false
+		return
+	}
+	if err != nil {
+		log.Fatal("could not deserialize integrity file, check it with fsck: ", err)
+	}
+	rr.head, err = unmarshal(record, rr.decoder)
+	if err != nil {
+		log.Fatal("could not deserialize integrity file, check it with fsck: ", err)
+	}
+	rr.ok = true
+}
+
+// Splits the integrity file into sections, which are sorted by canonical relative path
+func scanRuns(f *os.File, d decoder) ([]section, bool) {
+	reader := newCSVReader(f)
+	sections := []section{}
+	clean := true
+	previous := ""
+	for {
+		offset := reader.InputOffset()
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal("could not deserialize integrity file, check it with fsck: ", err)
+		}
+		if len(record) < columns {
+			log.Fatal("could not deserialize integrity file, check it with fsck: invalid number of columns")
+		}
+		relativePath := d.relativePath(record[4])
+		c := path.Compare(relativePath, previous)
+		if len(sections) == 0 || c < 0 {
+			sections = append(sections, section{start: offset})
+		} else if c == 0 {
+			clean = false
+		}
+		if record[0] == EmptyHash {
+			clean = false
+		}
+		sections[len(sections)-1].end = reader.InputOffset()
+		previous = relativePath
+	}
+	return sections, clean
+}
+
+// Totals returns the number of entries and the total size of the defragmented integrity file
+func Totals(basePath string) (int64, int64) {
+	r := NewReader(basePath)
+	defer r.Close()
+	files, bytes := int64(0), int64(0)
+	for fh, ok := r.Next(); ok; fh, ok = r.Next() {
+		files++
+		bytes += fh.Size
+	}
+	return files, bytes
+}
+
+// Exists reports whether the integrity file of the directory exists
+func Exists(basePath string) bool {
+	_, err := os.Stat(filename(basePath))
+	return err == nil
+}
+
+func filename(basePath string) string {
+	return filepath.Join(basePath, dir.Name, name)
+}
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	entry := func(hash string, created int, relativePath string) FileHash {
+		return FileHash{
+			Hash:         hash,
+			Created:      now.Add(time.Duration(created) * time.Second),
+			ModTime:      now,
+			Size:         int64(len(relativePath)),
+			RelativePath: filepath.FromSlash(relativePath),
+		}
+	}
+	cases := []struct {
+		name         string
+		in           FileHashs
+		expected     FileHashs
+		defragmented bool
+	}{
+		{
+			name:         "Empty",
+			in:           FileHashs{},
+			expected:     FileHashs{},
+			defragmented: true,
+		},
+		{
+			name:         "Single sorted run",
+			in:           FileHashs{entry("a", 0, "a/b/c.txt"), entry("b", 0, "a/b.txt"), entry("c", 0, "b.txt")},
+			expected:     FileHashs{entry("a", 0, "a/b/c.txt"), entry("b", 0, "a/b.txt"), entry("c", 0, "b.txt")},
+			defragmented: true,
+		},
+		{
+			name:         "Appended run with update, new and deleted entry",
+			in:           FileHashs{entry("a", 0, "a.txt"), entry("b", 0, "b.txt"), entry("c", 0, "c.txt"), entry("x", 1, "a.txt"), entry(EmptyHash, 1, "b.txt"), entry("d", 1, "d.txt")},
+			expected:     FileHashs{entry("x", 1, "a.txt"), entry("c", 0, "c.txt"), entry("d", 1, "d.txt")},
+			defragmented: false,
+		},
+		{
+			name:         "Duplicated entry within a run",
+			in:           FileHashs{entry("a", 0, "a.txt"), entry("b", 1, "a.txt")},
+			expected:     FileHashs{entry("b", 1, "a.txt")},
+			defragmented: false,
+		},
+		{
+			name:         "Unsorted entries of former versions",
+			in:           FileHashs{entry("d", 0, "d.txt"), entry("c", 0, "c.txt"), entry("b", 0, "b.txt"), entry("a", 0, "a.txt")},
+			expected:     FileHashs{entry("a", 0, "a.txt"), entry("b", 0, "b.txt"), entry("c", 0, "c.txt"), entry("d", 0, "d.txt")},
+			defragmented: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			basePath := t.TempDir()
+			os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+			Append(basePath, c.in)
+
+			r := NewReader(basePath)
+			actual := FileHashs{}
+			for fh, ok := r.Next(); ok; fh, ok = r.Next() {
+				actual = append(actual, fh)
+			}
+			r.Close()
+			assertFileHashs(t, c.expected, actual)
+			assert.Equal(t, c.defragmented, r.Defragmented())
+
+			Defragment(basePath)
+			assertFileHashs(t, c.expected, LoadContent(basePath))
+			r = NewReader(basePath)
+			assert.True(t, r.Defragmented())
+			r.Close()
+		})
+	}
+}
+
+func TestReader_manyRuns(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	fileHashs := FileHashs{}
+	for i := maxRuns + 10; i > 0; i-- {
+		fileHashs = append(fileHashs, FileHash{Hash: "a", Created: time.Now(), ModTime: time.Now(), RelativePath: fmt.Sprintf("%04d.txt", i)})
+	}
+	Append(basePath, fileHashs)
+
+	r := NewReader(basePath)
+	defer r.Close()
+	previous := ""
+	count := 0
+	for fh, ok := r.Next(); ok; fh, ok = r.Next() {
+		assert.Less(t, previous, fh.RelativePath)
+		previous = fh.RelativePath
+		count++
+	}
+	assert.Equal(t, len(fileHashs), count)
+	assert.False(t, r.Defragmented())
+}
+
+func assertFileHashs(t *testing.T, expected FileHashs, actual FileHashs) {
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		if i < len(actual) {
+			assert.True(t, expected[i].Equal(actual[i]), "%v != %v", expected[i], actual[i])
+		}
+	}
+}
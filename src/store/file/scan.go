@@ -0,0 +1,117 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+// Records larger than this limit are malformed, e.g. a quoted field which is never closed
+const maxRecordSize = 1024 * 1024
+
+// Record is a raw entry of the integrity file. Malformed records contain the parse error instead of the entry.
+type Record struct {
+	Line     int // Line number of the first line of the record
+	FileHash FileHash
+	Err      error
+}
+
+// Scan reads the integrity file leniently without changing it. Malformed records, including an incomplete
+// last record, are passed with their error and the scan continues with the next record.
+func Scan(basePath string, visit func(Record)) error {
+	mu.Lock()
+	defer mu.Unlock()
+	f, err := os.Open(filename(basePath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := newDecoder(basePath)
+	reader := bufio.NewReader(f)
+	line := 1
+	for {
+		text, lines, err := readRecord(reader)
+		if len(text) > 0 {
+			visit(parseRecord(line, text, d))
+		}
+		line += lines
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Reads the lines of the next record. A line with an unbalanced number of quotes starts a quoted field with
+// line breaks, hence the record continues with the next line.
+func readRecord(reader *bufio.Reader) ([]byte, int, error) {
+	var text []byte
+	lines := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		text = append(text, line...)
+		if len(line) > 0 {
+			lines++
+		}
+		if err != nil || bytes.Count(text, []byte{'"'})%2 == 0 || len(text) > maxRecordSize {
+			return text, lines, err
+		}
+	}
+}
+
+func parseRecord(line int, text []byte, d decoder) Record {
+	if !bytes.HasSuffix(text, []byte{'\n'}) {
+		return Record{Line: line, Err: errors.New("incomplete record")}
+	}
+	reader := newCSVReader(bytes.NewReader(text))
+	record, err := reader.Read()
+	if err != nil {
+		return Record{Line: line, Err: err}
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		return Record{Line: line, Err: errors.New("unexpected content after record")}
+	}
+	fileHash, err := unmarshal(record, d)
+	if err != nil {
+		return Record{Line: line, Err: err}
+	}
+	return Record{Line: line, FileHash: fileHash}
+}
+
+// Replace writes the entries as integrity file, which replaces the current integrity file atomically
+func Replace(basePath string, fileHashs FileHashs) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return replace(basePath, fileHashs)
+}
+
+func replace(basePath string, fileHashs FileHashs) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename(basePath)), name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := newWriter(tmp)
+	for _, fileHash := range fileHashs {
+		if err = w.Write(fileHash); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := dir.Commit(tmp, filename(basePath)); err != nil {
+		return err
+	}
+	return writeFormat(basePath)
+}
@@ -0,0 +1,57 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	content := "a,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,a.txt\n" +
+		"b,2024-01-01T00:00:00Z,broken,1,b.txt\n" +
+		"c,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,\"c\n.txt\"\n" +
+		"d,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,\"d.txt\n" +
+		"e,2024-01-01T00:00:00Z,2024-01"
+	os.WriteFile(filename(basePath), []byte(content), 0644)
+
+	records := []Record{}
+	assert.Nil(t, Scan(basePath, func(r Record) {
+		records = append(records, r)
+	}))
+
+	assert.Len(t, records, 4)
+	assert.Equal(t, 1, records[0].Line)
+	assert.Nil(t, records[0].Err)
+	assert.Equal(t, "a.txt", records[0].FileHash.RelativePath)
+	assert.Equal(t, 2, records[1].Line)
+	assert.NotNil(t, records[1].Err)
+	assert.Equal(t, 3, records[2].Line)
+	assert.Equal(t, "c\n.txt", records[2].FileHash.RelativePath)
+	// The unclosed quote consumes the incomplete last line
+	assert.Equal(t, 5, records[3].Line)
+	assert.NotNil(t, records[3].Err)
+
+	// Scan does not change the integrity file
+	scanned, _ := os.ReadFile(filename(basePath))
+	assert.Equal(t, content, string(scanned))
+}
+
+func TestReplace(t *testing.T) {
+	basePath := t.TempDir()
+	os.Mkdir(filepath.Join(basePath, dir.Name), 0755)
+	os.WriteFile(filename(basePath), []byte("malformed"), 0644)
+	now := time.Now().UTC().Truncate(time.Second)
+	fileHashs := FileHashs{{Hash: "a", Created: now, ModTime: now, Size: 1, RelativePath: "a.txt"}}
+
+	assert.Nil(t, Replace(basePath, fileHashs))
+
+	assert.Equal(t, fileHashs, LoadContent(basePath))
+	entries, _ := os.ReadDir(filepath.Join(basePath, dir.Name))
+	assert.Len(t, entries, 2) // Integrity file and format file without temporary files
+}
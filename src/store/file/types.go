@@ -4,6 +4,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aicirt2012/fileintegrity/src/analysis/meta"
 	"github.com/dustin/go-humanize"
 )
 
@@ -15,13 +16,26 @@ type FileHash struct {
 	ModTime      time.Time `csv:"mod"`
 	Size         int64     `csv:"size"`
 	RelativePath string    `csv:"relativePath"`
+	Inode        string    `csv:"inode"`  // Hardlink group, empty for files without further hardlinks
+	Target       string    `csv:"target"` // Target of a symbolic link, empty for files
+	Metadata     meta.Metadata
+	Dir          bool `csv:"type"` // Empty directory
 }
 
 func (fh *FileHash) Equal(o FileHash) bool {
 	return fh.Hash == o.Hash &&
 		fh.ModTime.Equal(o.ModTime) &&
 		fh.Size == o.Size &&
-		fh.RelativePath == o.RelativePath
+		fh.RelativePath == o.RelativePath &&
+		fh.Inode == o.Inode &&
+		fh.Target == o.Target &&
+		fh.Metadata == o.Metadata &&
+		fh.Dir == o.Dir
+}
+
+// IsLink is true for entries of symbolic links, whose hash is computed from the target
+func (fh *FileHash) IsLink() bool {
+	return fh.Target != ""
 }
 
 type FileHashs []FileHash
@@ -50,6 +64,24 @@ func (fs FileHashs) DefragmentedMap() FileHashMap {
 	return m
 }
 
+// Iterator provides the entries one by one
+func (fs FileHashs) Iterator() Iterator {
+	return &sliceIterator{fileHashs: fs}
+}
+
+type sliceIterator struct {
+	fileHashs FileHashs
+}
+
+func (it *sliceIterator) Next() (FileHash, bool) {
+	if len(it.fileHashs) == 0 {
+		return FileHash{}, false
+	}
+	fileHash := it.fileHashs[0]
+	it.fileHashs = it.fileHashs[1:]
+	return fileHash, true
+}
+
 func (fs FileHashs) Len() int {
 	return len(fs)
 }
@@ -73,16 +105,21 @@ func (fhm *FileHashMap) Remove(relativePath string) {
 	delete(*fhm, relativePath)
 }
 
+// Limits the memory of the buffer independent of the hashed bytes
+const maxBufferedEntries = 10000
+
 type fileHashsBuffer struct {
 	basePath       string
 	fileHashs      FileHashs
+	bytes          int64
 	maxBytes       int64
 	afterFlushHook func()
 }
 
 func (fhb *fileHashsBuffer) Append(fileHash FileHash) {
 	fhb.fileHashs = append(fhb.fileHashs, fileHash)
-	if fhb.fileHashs.TotalBytes() > fhb.maxBytes {
+	fhb.bytes += fileHash.Size
+	if fhb.bytes > fhb.maxBytes || len(fhb.fileHashs) >= maxBufferedEntries {
 		fhb.Flush()
 	}
 }
@@ -93,6 +130,7 @@ func (fhb *fileHashsBuffer) Flush() {
 	}
 	Append(fhb.basePath, fhb.fileHashs)
 	fhb.fileHashs = FileHashs{}
+	fhb.bytes = 0
 	fhb.afterFlushHook()
 }
 
@@ -0,0 +1,127 @@
+package fsck
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+// Timestamps up to this duration in the future are accepted, e.g. due to clock skew between hosts
+const clockSkew = 24 * time.Hour
+
+// Check parses the integrity file leniently and reports malformed records, unresolvable duplicate paths,
+// impossible values and invalid paths. With the repair flag, the valid entries are salvaged into a new
+// defragmented integrity file and the current integrity file is backed up before.
+func Check(basePath string, repair bool, options store.Options) error {
+	dir.AssertDir(basePath)
+	dir.AssertIntegrityDir(basePath)
+	start := time.Now()
+	logBuffer := ilog.NewManualLogBuffer(basePath, ilog.Fsck, options.Log)
+	summary := ilog.FsckSummary{}
+	salvaged := file.FileHashs{}
+	lines := map[string]int{} // Line of the first entry per relative path and creation date
+
+	err := file.Scan(basePath, func(r file.Record) {
+		summary.TotalRecords++
+		issue, reason := validate(r, options.Algorithm, start)
+		if issue == "" {
+			key := r.FileHash.RelativePath + "\x00" + r.FileHash.Created.String()
+			if line, exists := lines[key]; exists {
+				issue, reason = ilog.DUPLICATE, fmt.Sprintf("equal creation date as line %d", line)
+			} else {
+				lines[key] = r.Line
+			}
+		}
+		switch issue {
+		case "":
+			summary.ValidRecords++
+			salvaged = append(salvaged, r.FileHash)
+			return
+		case ilog.MALFORMED:
+			summary.MalformedLines++
+		case ilog.DUPLICATE:
+			summary.DuplicatePaths++
+		case ilog.INVALID_VALUE:
+			summary.InvalidValues++
+		case ilog.INVALID_PATH:
+			summary.InvalidPaths++
+		}
+		logBuffer.Append(ilog.FsckLog{Issue: issue, Line: r.Line, Reason: reason, RelativePath: r.FileHash.RelativePath})
+	})
+	if err != nil {
+		return fmt.Errorf("could not read integrity file: %w", err)
+	}
+
+	if repair && summary.Issues() > 0 {
+		file.Backup(basePath)
+		if err := file.Replace(basePath, salvaged); err != nil {
+			return fmt.Errorf("could not write salvaged integrity file: %w", err)
+		}
+		file.Defragment(basePath)
+		summary.Repaired = true
+		summary.SalvagedEntries = int64(len(salvaged))
+	}
+	summary.ExecutionTime = time.Since(start)
+	logBuffer.Append(summary).Flush()
+	return nil
+}
+
+// Returns the issue and its reason, the issue is empty for valid records
+func validate(r file.Record, algorithm digest.Algorithm, now time.Time) (ilog.FsckIssue, string) {
+	if r.Err != nil {
+		return ilog.MALFORMED, r.Err.Error()
+	}
+	fh := r.FileHash
+	if reason := invalidPath(fh.RelativePath); reason != "" {
+		return ilog.INVALID_PATH, reason
+	}
+	if fh.Size < 0 {
+		return ilog.INVALID_VALUE, "negative size"
+	}
+	if _, err := hex.DecodeString(fh.Hash); err != nil {
+		return ilog.INVALID_VALUE, "hash is not hex encoded"
+	}
+	// Deletion markers and empty directories have hashes independent of the algorithm
+	if fh.Hash != file.EmptyHash && !fh.Dir && len(fh.Hash) != algorithm.HexLen() {
+		return ilog.INVALID_VALUE, fmt.Sprintf("hash length %d differs from %s", len(fh.Hash), algorithm)
+	}
+	if fh.Created.After(now.Add(clockSkew)) {
+		return ilog.INVALID_VALUE, "creation date in the future"
+	}
+	if fh.ModTime.After(now.Add(clockSkew)) {
+		return ilog.INVALID_VALUE, "modification date in the future"
+	}
+	return "", ""
+}
+
+// Relative paths must not leave the directory, independent of the platform which created the integrity file
+func invalidPath(relativePath string) string {
+	if relativePath == "" {
+		return "empty path"
+	}
+	if strings.HasPrefix(relativePath, "/") || strings.HasPrefix(relativePath, `\`) || drive(relativePath) {
+		return "absolute path"
+	}
+	for _, segment := range strings.FieldsFunc(relativePath, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return "path outside of the directory"
+		}
+	}
+	return ""
+}
+
+// Returns true for paths starting with a windows drive, e.g. C:\data
+func drive(relativePath string) bool {
+	if len(relativePath) < 3 || relativePath[1] != ':' || (relativePath[2] != '\\' && relativePath[2] != '/') {
+		return false
+	}
+	letter := relativePath[0] | 0x20
+	return letter >= 'a' && letter <= 'z'
+}
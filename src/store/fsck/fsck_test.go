@@ -0,0 +1,96 @@
+package fsck
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/stretchr/testify/assert"
+)
+
+const hash = "85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301"
+
+type observer struct {
+	entries []any
+}
+
+func (o *observer) Entry(entry any) {
+	o.entries = append(o.entries, entry)
+}
+
+func (o *observer) Progress(done int64, total int64) {}
+
+func TestCheck(t *testing.T) {
+	basePath := writeStore(t,
+		hash+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,a.txt",
+		hash+",2024-01-01T00:00:00Z,broken,1,b.txt",
+		hash+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,a.txt",
+		hash+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,-1,c.txt",
+		strings.Repeat("z", 64)+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,d.txt",
+		hash[:40]+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,e.txt",
+		hash+",2999-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,f.txt",
+		hash+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,../g.txt",
+		hash+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,/h.txt",
+		hash+`,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,C:\i.txt`,
+		file.EmptyHash+",2024-01-02T00:00:00Z,2024-01-01T00:00:00Z,1,a.txt",
+	)
+	o := &observer{}
+
+	assert.Nil(t, Check(basePath, false, options(o)))
+
+	summary := o.entries[len(o.entries)-1].(ilog.FsckSummary)
+	assert.Equal(t, int64(11), summary.TotalRecords)
+	assert.Equal(t, int64(2), summary.ValidRecords)
+	assert.Equal(t, int64(1), summary.MalformedLines)
+	assert.Equal(t, int64(1), summary.DuplicatePaths)
+	assert.Equal(t, int64(4), summary.InvalidValues)
+	assert.Equal(t, int64(3), summary.InvalidPaths)
+	assert.False(t, summary.Repaired)
+	assert.Equal(t, ilog.FsckLog{Issue: ilog.DUPLICATE, Line: 3, Reason: "equal creation date as line 1", RelativePath: "a.txt"}, o.entries[1])
+}
+
+func TestCheck_repair(t *testing.T) {
+	basePath := writeStore(t,
+		hash+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,b.txt",
+		hash+",2024-01-01T00:00:00Z,broken,1,c.txt",
+		hash+",2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,1,a.txt",
+		hash+",2024-01-01T00:00:00Z",
+	)
+	o := &observer{}
+
+	assert.Nil(t, Check(basePath, true, options(o)))
+
+	summary := o.entries[len(o.entries)-1].(ilog.FsckSummary)
+	assert.True(t, summary.Repaired)
+	assert.Equal(t, int64(2), summary.SalvagedEntries)
+	fileHashes := file.LoadContent(basePath)
+	assert.Len(t, fileHashes, 2)
+	assert.Equal(t, "a.txt", fileHashes[0].RelativePath)
+	backups, _ := file.Backups(basePath)
+	assert.Len(t, backups, 1)
+
+	// The salvaged integrity file is consistent
+	o = &observer{}
+	assert.Nil(t, Check(basePath, true, options(o)))
+	summary = o.entries[len(o.entries)-1].(ilog.FsckSummary)
+	assert.Equal(t, int64(0), summary.Issues())
+	assert.False(t, summary.Repaired)
+}
+
+func options(o *observer) store.Options {
+	return store.Options{Algorithm: digest.SHA256, Log: ilog.Options{Observer: o}}
+}
+
+func writeStore(t *testing.T, lines ...string) string {
+	basePath := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(basePath, dir.Name), 0755))
+	content := strings.Join(lines, "\n") + "\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(basePath, dir.Name, ".integrity"), []byte(content), 0644))
+	return basePath
+}
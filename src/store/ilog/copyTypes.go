@@ -0,0 +1,40 @@
+package ilog
+
+import (
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type CopySummary struct {
+	ExecutionTime time.Duration
+	CopiedBytes   int64
+	MatchedFiles  int64
+	CopiedFiles   int64
+	ReplacedFiles int64
+	FailedFiles   int64
+}
+
+func (cs CopySummary) copyRateInS() uint64 {
+	s := cs.ExecutionTime.Abs().Seconds()
+	if s == 0 {
+		return 0
+	}
+	return uint64(float64(cs.CopiedBytes) / s)
+}
+
+func (cs CopySummary) serialize() string {
+	s := title(Copy)
+	s += line("Execution time:", "%.2f s", cs.ExecutionTime.Abs().Seconds())
+	s += line("Copied size:", "%v", humanize.Bytes(uint64(cs.CopiedBytes)))
+	s += line("Copy rate:", "%v/s", humanize.Bytes(cs.copyRateInS()))
+	s += line("Matched files:", "%v", cs.MatchedFiles)
+	s += line("Copied files:", "%v", cs.CopiedFiles)
+	s += line("Replaced files:", "%v", cs.ReplacedFiles)
+	s += line("Failed files:", "%v", cs.FailedFiles)
+	return s
+}
+
+func (cs CopySummary) visibleOnConsole() bool {
+	return true
+}
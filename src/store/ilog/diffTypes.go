@@ -0,0 +1,71 @@
+package ilog
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type DiffStatus string
+
+const (
+	ONLY_A   DiffStatus = "ONLY A"
+	ONLY_B   DiffStatus = "ONLY B"
+	MODIFIED DiffStatus = "MODIFIED"
+	MOVED    DiffStatus = "MOVED"
+)
+
+type DiffLog struct {
+	Status       DiffStatus
+	RelativePath string
+	TargetPath   string
+}
+
+func (l DiffLog) serialize() string {
+	path := l.RelativePath
+	if l.TargetPath != "" {
+		path += " -> " + l.TargetPath
+	}
+	a := []string{
+		string(l.Status),
+		path,
+	}
+	return strings.Join(a, "  ")
+}
+
+func (l DiffLog) visibleOnConsole() bool {
+	return true
+}
+
+type DiffSummary struct {
+	ExecutionTime time.Duration
+	FilesA        int64
+	FilesB        int64
+	BytesA        int64
+	BytesB        int64
+	EqualFiles    int64
+	OnlyAFiles    int64
+	OnlyBFiles    int64
+	ModifiedFiles int64
+	MovedFiles    int64
+}
+
+func (ds DiffSummary) serialize() string {
+	s := title(Diff)
+	s += line("Execution time:", "%.2f s", ds.ExecutionTime.Abs().Seconds())
+	s += line("Total files A:", "%v", ds.FilesA)
+	s += line("Total files B:", "%v", ds.FilesB)
+	s += line("Equal files:", "%v", ds.EqualFiles)
+	s += line("Only in A files:", "%v", ds.OnlyAFiles)
+	s += line("Only in B files:", "%v", ds.OnlyBFiles)
+	s += line("Modified files:", "%v", ds.ModifiedFiles)
+	s += line("Moved files:", "%v", ds.MovedFiles)
+	s += line("Total size A:", "%v", humanize.Bytes(uint64(ds.BytesA)))
+	s += line("Total size B:", "%v", humanize.Bytes(uint64(ds.BytesB)))
+	return s
+}
+
+func (ds DiffSummary) visibleOnConsole() bool {
+	return true
+}
@@ -16,6 +16,10 @@ func (l *DuplicateLog) AddRelativePath(path string) {
 	l.RelativePaths = append(l.RelativePaths, path)
 }
 
+func (l *DuplicateLog) AddHardlink(path string) {
+	l.RelativePaths = append(l.RelativePaths, path+" (hardlink)")
+}
+
 func (l DuplicateLog) serialize() string {
 	var b strings.Builder
 	b.WriteString(Duplicates.ToUpper() + " " + l.Hash + "\n")
@@ -29,11 +33,13 @@ func (l DuplicateLog) visibleOnConsole() bool {
 }
 
 type DuplicateSummary struct {
-	ExecutionTime  time.Duration
-	TotalFiles     int64
-	TotalBytes     int64
-	DuplicateFiles int64
-	DuplicateBytes int64
+	ExecutionTime      time.Duration
+	TotalFiles         int64
+	TotalBytes         int64
+	DuplicateFiles     int64
+	DuplicateBytes     int64
+	HardlinkFiles      int64
+	NewDuplicateGroups int64 // Groups with a file, which was no duplicate of the content at the previous check
 }
 
 func (ds DuplicateSummary) filePercentage() float64 {
@@ -59,6 +65,8 @@ func (ds DuplicateSummary) serialize() string {
 	s += line("Total size:", "%v", humanize.Bytes(uint64(ds.TotalBytes)))
 	s += line("Duplicate size:", "%v", humanize.Bytes(uint64(ds.DuplicateBytes)))
 	s += line("Duplicate size percentage:", "%.1f", ds.bytePercentage())
+	s += line("Hardlinked files:", "%v", ds.HardlinkFiles)
+	s += line("New duplicate groups:", "%v", ds.NewDuplicateGroups)
 	return s
 }
 
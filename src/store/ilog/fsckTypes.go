@@ -0,0 +1,75 @@
+package ilog
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FsckIssue string
+
+const (
+	MALFORMED     FsckIssue = "MALFORMED"     // Record is no valid entry
+	DUPLICATE     FsckIssue = "DUPLICATE"     // Path with an equal creation date, which cannot be resolved
+	INVALID_VALUE FsckIssue = "INVALID VALUE" // Negative size, bad hash or future timestamp
+	INVALID_PATH  FsckIssue = "INVALID PATH"  // Empty, absolute or parent relative path
+)
+
+type FsckLog struct {
+	Issue        FsckIssue
+	Line         int
+	Reason       string
+	RelativePath string
+}
+
+func (l FsckLog) serialize() string {
+	a := []string{
+		string(l.Issue),
+		"line " + strconv.Itoa(l.Line),
+		l.Reason,
+	}
+	if l.RelativePath != "" {
+		a = append(a, l.RelativePath)
+	}
+	return strings.Join(a, "  ")
+}
+
+func (l FsckLog) visibleOnConsole() bool {
+	return true
+}
+
+type FsckSummary struct {
+	ExecutionTime   time.Duration
+	TotalRecords    int64
+	ValidRecords    int64
+	MalformedLines  int64
+	DuplicatePaths  int64
+	InvalidValues   int64
+	InvalidPaths    int64
+	SalvagedEntries int64 // Entries written into the new integrity file with the repair option
+	Repaired        bool
+}
+
+// Issues is the number of invalid records
+func (fs FsckSummary) Issues() int64 {
+	return fs.MalformedLines + fs.DuplicatePaths + fs.InvalidValues + fs.InvalidPaths
+}
+
+func (fs FsckSummary) serialize() string {
+	s := title(Fsck)
+	s += line("Execution time:", "%.2f s", fs.ExecutionTime.Abs().Seconds())
+	s += line("Total records:", "%v", fs.TotalRecords)
+	s += line("Valid records:", "%v", fs.ValidRecords)
+	s += line("Malformed lines:", "%v", fs.MalformedLines)
+	s += line("Duplicate paths:", "%v", fs.DuplicatePaths)
+	s += line("Invalid values:", "%v", fs.InvalidValues)
+	s += line("Invalid paths:", "%v", fs.InvalidPaths)
+	if fs.Repaired {
+		s += line("Salvaged entries:", "%v", fs.SalvagedEntries)
+	}
+	return s
+}
+
+func (fs FsckSummary) visibleOnConsole() bool {
+	return true
+}
@@ -23,7 +23,11 @@ func NewManualLogBuffer(basePath string, category Category, options Options) Log
 	}
 }
 
+// NewAutomaticLogBuffer flushes the logs after the max items, the flush size of the options takes precedence
 func NewAutomaticLogBuffer(basePath string, category Category, maxItems uint64, options Options) LogFileBuffer {
+	if options.FlushSize > 0 {
+		maxItems = uint64(options.FlushSize)
+	}
 	return LogFileBuffer{
 		filename:  generateFilename(basePath, category),
 		maxItems:  maxItems,
@@ -32,14 +36,20 @@ func NewAutomaticLogBuffer(basePath string, category Category, maxItems uint64,
 	}
 }
 
-func ProgressBar(max int64, visible bool) *progressbar.ProgressBar {
-	return progressbar.NewOptions64(
-		max,
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionThrottle(300*time.Microsecond),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetVisibility(visible),
-	)
+// ProgressBar reports the processed bytes on the console and to the optional observer. A negative max
+// indicates an unknown total.
+func ProgressBar(max int64, visible bool, observer Observer) *Progress {
+	return &Progress{
+		bar: progressbar.NewOptions64(
+			max,
+			progressbar.OptionClearOnFinish(),
+			progressbar.OptionThrottle(300*time.Microsecond),
+			progressbar.OptionShowBytes(true),
+			progressbar.OptionSetVisibility(visible),
+		),
+		max:      max,
+		observer: observer,
+	}
 }
 
 func generateFilename(basePath string, category Category) string {
@@ -1,6 +1,8 @@
 package ilog
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -29,3 +31,39 @@ func TestPadMiddle(t *testing.T) {
 	assert.Equal(t, "rile", padMiddle("ri", "le", "-", 4))
 	assert.Equal(t, "rile", padMiddle("ri", "le", "-", -1))
 }
+
+func TestVerifyLog_MarshalJSON(t *testing.T) {
+	l := VerifyLog{Status: ERROR, RelativePath: "a.txt", Reason: errors.New("file does not exist")}
+	actual, err := json.Marshal(l)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"Created":"0001-01-01T00:00:00Z","Status":"ERROR","RelativePath":"a.txt","Reason":"file does not exist"}`, string(actual))
+}
+
+type observer struct {
+	entries  []any
+	progress []int64
+}
+
+func (o *observer) Entry(entry any) {
+	o.entries = append(o.entries, entry)
+}
+
+func (o *observer) Progress(done int64, total int64) {
+	o.progress = append(o.progress, done, total)
+}
+
+func TestObserver(t *testing.T) {
+	o := &observer{}
+	logBuffer := NewManualLogBuffer(t.TempDir(), Verify, Options{Observer: o})
+	logBuffer.AppendVerifyLog(OK, "a.txt", nil)
+	logBuffer.AppendVerifyLog(ERROR, "b.txt", errors.New("invalid hash"))
+	logBuffer.Append(VerifySummary{ValidFiles: 1, InvalidFiles: 1})
+	assert.Len(t, o.entries, 2)
+	assert.False(t, IsSummary(o.entries[0]))
+	assert.True(t, IsSummary(o.entries[1]))
+
+	progress := ProgressBar(10, false, o)
+	progress.Add64(4)
+	progress.Add64(6)
+	assert.Equal(t, []int64{4, 10, 10, 10}, o.progress)
+}
@@ -0,0 +1,78 @@
+package ilog
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type RepairStatus string
+
+const (
+	REPAIRED     RepairStatus = "REPAIRED"
+	UNREPAIRABLE RepairStatus = "UNREPAIRABLE"
+)
+
+type RepairLog struct {
+	Created      time.Time
+	Status       RepairStatus
+	RelativePath string
+	Source       string
+	Reason       error
+}
+
+func (l RepairLog) serialize() string {
+	a := []string{
+		l.Created.Format(TimeFormat),
+		string(l.Status),
+		l.RelativePath,
+	}
+	if l.Source != "" {
+		a = append(a, "from "+l.Source)
+	}
+	if l.Reason != nil {
+		a = append(a, l.Reason.Error())
+	}
+	return strings.Join(a, "  ")
+}
+
+// MarshalJSON serializes the reason as message
+func (l RepairLog) MarshalJSON() ([]byte, error) {
+	type entry RepairLog
+	return json.Marshal(struct {
+		entry
+		Reason string `json:",omitempty"`
+	}{entry(l), message(l.Reason)})
+}
+
+func (l RepairLog) visibleOnConsole() bool {
+	return true
+}
+
+type RepairSummary struct {
+	ExecutionTime     time.Duration
+	TotalBytes        int64
+	ValidFiles        int64
+	InvalidFiles      int64
+	RepairedFiles     int64
+	UnrepairableFiles int64
+	QuarantinedFiles  int64
+}
+
+func (rs RepairSummary) serialize() string {
+	s := title(Repair)
+	s += line("Execution time:", "%.2f s", rs.ExecutionTime.Abs().Seconds())
+	s += line("Total size:", "%v", humanize.Bytes(uint64(rs.TotalBytes)))
+	s += line("Verified valid files:", "%v", rs.ValidFiles)
+	s += line("Verified invalid files:", "%v", rs.InvalidFiles)
+	s += line("Repaired files:", "%v", rs.RepairedFiles)
+	s += line("Unrepairable files:", "%v", rs.UnrepairableFiles)
+	s += line("Quarantined files:", "%v", rs.QuarantinedFiles)
+	return s
+}
+
+func (rs RepairSummary) visibleOnConsole() bool {
+	return true
+}
@@ -0,0 +1,58 @@
+package ilog
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type RetentionStatus string
+
+const (
+	REMOVED RetentionStatus = "REMOVED"
+	EXPIRED RetentionStatus = "EXPIRED" // Expired, but kept within a dry run
+)
+
+type RetentionLog struct {
+	Status   RetentionStatus
+	Filename string
+	Size     int64
+}
+
+func (l RetentionLog) serialize() string {
+	a := []string{
+		string(l.Status),
+		l.Filename,
+		humanize.Bytes(uint64(l.Size)),
+	}
+	return strings.Join(a, "  ")
+}
+
+func (l RetentionLog) visibleOnConsole() bool {
+	return true
+}
+
+type RetentionSummary struct {
+	ExecutionTime  time.Duration
+	KeptFiles      int64
+	RemovedLogs    int64
+	RemovedBackups int64
+	RemovedBytes   int64
+	DryRun         bool
+}
+
+func (rs RetentionSummary) serialize() string {
+	s := title(Retention)
+	s += line("Execution time:", "%.2f s", rs.ExecutionTime.Abs().Seconds())
+	s += line("Kept files:", "%v", rs.KeptFiles)
+	s += line("Removed logs:", "%v", rs.RemovedLogs)
+	s += line("Removed backups:", "%v", rs.RemovedBackups)
+	s += line("Removed size:", "%v", humanize.Bytes(uint64(rs.RemovedBytes)))
+	s += line("Dry run:", "%v", rs.DryRun)
+	return s
+}
+
+func (rs RetentionSummary) visibleOnConsole() bool {
+	return true
+}
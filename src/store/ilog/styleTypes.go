@@ -8,9 +8,14 @@ import (
 type IssueType string
 
 const (
-	HIERARCHY_ISSUE IssueType = "HIERARCHY ISSUE"
-	NAMING_ISSUE    IssueType = "NAMING ISSUE"
-	LENGTH_ISSUE    IssueType = "LENGTH ISSUE"
+	HIERARCHY_ISSUE               IssueType = "HIERARCHY ISSUE"
+	NAMING_ISSUE                  IssueType = "NAMING ISSUE"
+	LENGTH_ISSUE                  IssueType = "LENGTH ISSUE"
+	CASE_COLLISION_ISSUE          IssueType = "CASE COLLISION ISSUE"
+	RESERVED_NAME_ISSUE           IssueType = "RESERVED NAME ISSUE"
+	FORBIDDEN_CHARACTER_ISSUE     IssueType = "FORBIDDEN CHARACTER ISSUE"
+	TRAILING_CHARACTER_ISSUE      IssueType = "TRAILING CHARACTER ISSUE"
+	NORMALISATION_COLLISION_ISSUE IssueType = "NORMALISATION COLLISION ISSUE"
 )
 
 type StyleLog struct {
@@ -36,11 +41,12 @@ func (l StyleLog) visibleOnConsole() bool {
 }
 
 type StyleSummary struct {
-	ExecutionTime   time.Duration
-	HierarchyIssues int
-	NamingIssues    int
-	LengthIssues    int
-	TotalDirs       int64
+	ExecutionTime     time.Duration
+	HierarchyIssues   int
+	NamingIssues      int
+	LengthIssues      int
+	PortabilityIssues int
+	TotalDirs         int64
 }
 
 func (ds StyleSummary) serialize() string {
@@ -49,6 +55,7 @@ func (ds StyleSummary) serialize() string {
 	s += line("Hierarchy issues:", "%v", ds.HierarchyIssues)
 	s += line("Naming issues:", "%v", ds.NamingIssues)
 	s += line("Length issues:", "%v", ds.LengthIssues)
+	s += line("Portability issues:", "%v", ds.PortabilityIssues)
 	return s
 }
 
@@ -0,0 +1,93 @@
+package ilog
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type SyncOperation string
+
+const (
+	COPY    SyncOperation = "COPY"
+	REPLACE SyncOperation = "REPLACE"
+	MOVE    SyncOperation = "MOVE"
+	REMOVE  SyncOperation = "REMOVE"
+	FAIL    SyncOperation = "FAIL"
+)
+
+type SyncLog struct {
+	Created      time.Time
+	Operation    SyncOperation
+	RelativePath string
+	TargetPath   string
+	Reason       error
+}
+
+func (l SyncLog) serialize() string {
+	path := l.RelativePath
+	if l.TargetPath != "" {
+		path += " -> " + l.TargetPath
+	}
+	a := []string{
+		l.Created.Format(TimeFormat),
+		string(l.Operation),
+		path,
+	}
+	if l.Reason != nil {
+		a = append(a, l.Reason.Error())
+	}
+	return strings.Join(a, "  ")
+}
+
+// MarshalJSON serializes the reason as message
+func (l SyncLog) MarshalJSON() ([]byte, error) {
+	type entry SyncLog
+	return json.Marshal(struct {
+		entry
+		Reason string `json:",omitempty"`
+	}{entry(l), message(l.Reason)})
+}
+
+func (l SyncLog) visibleOnConsole() bool {
+	return true
+}
+
+type SyncSummary struct {
+	ExecutionTime  time.Duration
+	CopiedBytes    int64
+	UnchangedFiles int64
+	CopiedFiles    int64
+	ReplacedFiles  int64
+	MovedFiles     int64
+	RemovedFiles   int64
+	FailedFiles    int64
+}
+
+func (ss SyncSummary) copyRateInS() uint64 {
+	s := ss.ExecutionTime.Abs().Seconds()
+	if s == 0 {
+		return 0
+	}
+	return uint64(float64(ss.CopiedBytes) / s)
+}
+
+func (ss SyncSummary) serialize() string {
+	s := title(Sync)
+	s += line("Execution time:", "%.2f s", ss.ExecutionTime.Abs().Seconds())
+	s += line("Copied size:", "%v", humanize.Bytes(uint64(ss.CopiedBytes)))
+	s += line("Copy rate:", "%v/s", humanize.Bytes(ss.copyRateInS()))
+	s += line("Unchanged files:", "%v", ss.UnchangedFiles)
+	s += line("Copied files:", "%v", ss.CopiedFiles)
+	s += line("Replaced files:", "%v", ss.ReplacedFiles)
+	s += line("Moved files:", "%v", ss.MovedFiles)
+	s += line("Removed files:", "%v", ss.RemovedFiles)
+	s += line("Failed files:", "%v", ss.FailedFiles)
+	return s
+}
+
+func (ss SyncSummary) visibleOnConsole() bool {
+	return true
+}
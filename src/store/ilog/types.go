@@ -6,6 +6,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/schollz/progressbar/v3"
 )
 
 const TimeFormat = "060102.150405"
@@ -25,6 +27,13 @@ const (
 	Contains       Category = "contains"
 	Style          Category = "style"
 	ExtensionStats Category = "extension stats"
+	Diff           Category = "diff"
+	Sync           Category = "sync"
+	Copy           Category = "copy"
+	Repair         Category = "repair"
+	Watch          Category = "watch"
+	Retention      Category = "retention"
+	Fsck           Category = "fsck"
 )
 
 func (c Category) ToUpper() string {
@@ -39,8 +48,64 @@ const (
 )
 
 type Options struct {
-	Console bool
-	File    bool
+	Console   bool
+	File      bool
+	Observer  Observer // Optional receiver of the console entries and the progress
+	FlushSize int      // Number of buffered logs of automatically flushed logs, zero keeps the default of the command
+}
+
+// Observer receives the entries visible on the console and the progress of an execution, e.g. for a service.
+// The methods are called sequentially.
+type Observer interface {
+	Entry(entry any)
+	Progress(done int64, total int64)
+}
+
+// Observers forwards the entries and the progress to multiple observers
+type Observers []Observer
+
+func (o Observers) Entry(entry any) {
+	for _, observer := range o {
+		observer.Entry(entry)
+	}
+}
+
+func (o Observers) Progress(done int64, total int64) {
+	for _, observer := range o {
+		observer.Progress(done, total)
+	}
+}
+
+// IsSummary is true for the summaries, which conclude the entries of an execution
+func IsSummary(entry any) bool {
+	switch entry.(type) {
+	case UpsertSummary, VerifySummary, DuplicateSummary, ContainedSummary, StyleSummary, ExtensionStatsSummary,
+		DiffSummary, SyncSummary, CopySummary, RepairSummary, WatchSummary, RetentionSummary, FsckSummary:
+		return true
+	}
+	return false
+}
+
+func message(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+type Progress struct {
+	bar      *progressbar.ProgressBar
+	done     int64
+	max      int64
+	observer Observer
+}
+
+func (p *Progress) Add64(n int64) {
+	p.bar.Add64(n)
+	p.done += n
+	if p.observer != nil {
+		p.observer.Progress(p.done, p.max)
+	}
 }
 
 type LogFileBuffer struct {
@@ -93,6 +158,9 @@ func (lf *LogFileBuffer) Append(log serializable) *LogFileBuffer {
 	if lf.options.Console && log.visibleOnConsole() {
 		println(log.serialize())
 	}
+	if lf.options.Observer != nil && log.visibleOnConsole() {
+		lf.options.Observer.Entry(log)
+	}
 	lf.logs = append(lf.logs, log)
 	if lf.options.File && lf.AutomaticFlush() && lf.RequiresFlush() {
 		lf.Flush()
@@ -109,6 +177,16 @@ func (lf *LogFileBuffer) AppendUpsertLog(operation UpsertOperation, relativePath
 	return lf
 }
 
+func (lf *LogFileBuffer) AppendSyncLog(operation SyncOperation, relativePath string, reason error) *LogFileBuffer {
+	lf.Append(SyncLog{
+		Created:      time.Now(),
+		Operation:    operation,
+		RelativePath: relativePath,
+		Reason:       reason,
+	})
+	return lf
+}
+
 func (lf *LogFileBuffer) AppendVerifyLog(status VerifyStatus, relativePath string, reason error) *LogFileBuffer {
 	lf.Append(VerifyLog{
 		Created:      time.Now(),
@@ -10,10 +10,11 @@ import (
 type UpsertOperation string
 
 const (
-	NEW    UpsertOperation = "NEW"
-	UPDATE UpsertOperation = "UPDATE"
-	DELETE UpsertOperation = "DELETE"
-	SKIP   UpsertOperation = "SKIP"
+	NEW      UpsertOperation = "NEW"
+	UPDATE   UpsertOperation = "UPDATE"
+	DELETE   UpsertOperation = "DELETE"
+	SKIP     UpsertOperation = "SKIP"
+	METADATA UpsertOperation = "METADATA"
 )
 
 type UpsertLog struct {
@@ -43,13 +44,18 @@ type UpsertSummary struct {
 	NewFiles      int64
 	UpdatedFiles  int64
 	DeletedFiles  int64
+	IOStrategy    string
+	LinkedFiles   int64
+	MetadataFiles int64
+	ParityFiles   int64 // Unchanged files, whose missing recovery data was created
 }
 
 func (us *UpsertSummary) AddHashedBytes(bytes int64) {
 	us.HashedBytes += bytes
 }
 
-func (us UpsertSummary) hashRateInS() uint64 {
+// HashRateInS is the hash rate in bytes per second
+func (us UpsertSummary) HashRateInS() uint64 {
 	s := us.ExecutionTime.Abs().Seconds()
 	if s == 0 {
 		return 0
@@ -61,11 +67,15 @@ func (us UpsertSummary) serialize() string {
 	s := title(Upsert)
 	s += line("Execution time:", "%.2f s", us.ExecutionTime.Abs().Seconds())
 	s += line("Total size:", "%v", humanize.Bytes(uint64(us.TotalBytes)))
-	s += line("Hash rate:", "%v/s", humanize.Bytes(us.hashRateInS()))
+	s += line("Hash rate:", "%v/s", humanize.Bytes(us.HashRateInS()))
 	s += line("Skipped files:", "%v", us.SkippedFiles)
 	s += line("New files:", "%v", us.NewFiles)
 	s += line("Updated files:", "%v", us.UpdatedFiles)
 	s += line("Deleted files:", "%v", us.DeletedFiles)
+	s += line("I/O strategy:", "%v", us.IOStrategy)
+	s += line("Hardlinked files:", "%v", us.LinkedFiles)
+	s += line("Metadata changed files:", "%v", us.MetadataFiles)
+	s += line("Recovery data created files:", "%v", us.ParityFiles)
 	return s
 }
 
@@ -1,6 +1,7 @@
 package ilog
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@ type VerifyStatus string
 const (
 	OK    VerifyStatus = "OK"
 	ERROR VerifyStatus = "ERROR"
+	DRIFT VerifyStatus = "DRIFT" // Valid content with changed metadata
 )
 
 type VerifyLog struct {
@@ -35,8 +37,17 @@ func (l VerifyLog) serialize() string {
 	return strings.Join(a, "  ")
 }
 
+// MarshalJSON serializes the reason as message
+func (l VerifyLog) MarshalJSON() ([]byte, error) {
+	type entry VerifyLog
+	return json.Marshal(struct {
+		entry
+		Reason string `json:",omitempty"`
+	}{entry(l), message(l.Reason)})
+}
+
 func (l VerifyLog) visibleOnConsole() bool {
-	return l.Status == ERROR
+	return l.Status != OK
 }
 
 type VerifySummary struct {
@@ -44,13 +55,16 @@ type VerifySummary struct {
 	TotalBytes    int64
 	ValidFiles    int64
 	InvalidFiles  int64
+	IOStrategy    string
+	DriftFiles    int64
 }
 
 func (vs VerifySummary) invalidFilesPercentage() float64 {
 	return float64(vs.InvalidFiles) / float64((vs.InvalidFiles + vs.ValidFiles)) * 100
 }
 
-func (vs VerifySummary) hashRateInS() uint64 {
+// HashRateInS is the hash rate in bytes per second
+func (vs VerifySummary) HashRateInS() uint64 {
 	s := vs.ExecutionTime.Abs().Seconds()
 	if s == 0 {
 		return 0
@@ -62,10 +76,12 @@ func (l VerifySummary) serialize() string {
 	s := title(Verify)
 	s += line("Execution time:", "%.2f s", l.ExecutionTime.Abs().Seconds())
 	s += line("Total size:", "%v", humanize.Bytes(uint64(l.TotalBytes)))
-	s += line("Hash rate:", "%v/s", humanize.Bytes(l.hashRateInS()))
+	s += line("Hash rate:", "%v/s", humanize.Bytes(l.HashRateInS()))
 	s += line("Verified valid files:", "%v", l.ValidFiles)
 	s += line("Verified invalid files:", "%v", l.InvalidFiles)
 	s += line("Percentage of invalid files:", "%.6f", l.invalidFilesPercentage())
+	s += line("I/O strategy:", "%v", l.IOStrategy)
+	s += line("Metadata drift files:", "%v", l.DriftFiles)
 	return s
 }
 
@@ -0,0 +1,39 @@
+package ilog
+
+import (
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type WatchSummary struct {
+	ExecutionTime time.Duration
+	HashedBytes   int64
+	Batches       int64
+	Rescans       int64
+	NewFiles      int64
+	UpdatedFiles  int64
+	DeletedFiles  int64
+	MetadataFiles int64
+	Unprocessed   int64 // Changed paths, which were not processed at stop due to a held lock
+}
+
+func (ws WatchSummary) serialize() string {
+	s := title(Watch)
+	s += line("Execution time:", "%.2f s", ws.ExecutionTime.Abs().Seconds())
+	s += line("Hashed size:", "%v", humanize.Bytes(uint64(ws.HashedBytes)))
+	s += line("Batches:", "%v", ws.Batches)
+	s += line("Rescans:", "%v", ws.Rescans)
+	s += line("New files:", "%v", ws.NewFiles)
+	s += line("Updated files:", "%v", ws.UpdatedFiles)
+	s += line("Deleted files:", "%v", ws.DeletedFiles)
+	s += line("Metadata changed files:", "%v", ws.MetadataFiles)
+	if ws.Unprocessed > 0 {
+		s += line("Unprocessed changes:", "%v", ws.Unprocessed)
+	}
+	return s
+}
+
+func (ws WatchSummary) visibleOnConsole() bool {
+	return true
+}
@@ -0,0 +1,13 @@
+package lock
+
+import "os"
+
+// Returns true, if a process with the PID exists on this host
+func alive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,234 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+)
+
+type Mode int
+
+const (
+	Shared    Mode = iota // Readers, e.g. verify or diff, run concurrently
+	Exclusive             // Writers, e.g. upsert or repair, run alone
+)
+
+const exclusiveFilename = "lock"
+const sharedPrefix = exclusiveFilename + "."
+
+var (
+	refreshInterval = time.Minute     // Interval in which the holder refreshes the timestamp of its lock file
+	staleAfter      = 5 * time.Minute // Locks of other hosts without refresh are considered stale afterwards
+	pollInterval    = 500 * time.Millisecond
+)
+
+// Owner describes the process holding a lock, which is stored as content of the lock file
+type Owner struct {
+	PID      int       `json:"pid"`
+	Host     string    `json:"host"`
+	Acquired time.Time `json:"acquired"`
+}
+
+// LockedError is returned if the lock is held by another live process
+type LockedError struct {
+	Owner Owner
+	Mode  Mode
+}
+
+func (e *LockedError) Error() string {
+	kind := "shared"
+	if e.Mode == Exclusive {
+		kind = "exclusive"
+	}
+	return fmt.Sprintf("integrity directory is locked (%s) by pid %d on %s since %s",
+		kind, e.Owner.PID, e.Owner.Host, e.Owner.Acquired.Local().Format(time.DateTime))
+}
+
+// IsLocked returns true, if the error was caused by a lock of another process
+func IsLocked(err error) bool {
+	var lockedError *LockedError
+	return errors.As(err, &lockedError)
+}
+
+// Acquire locks the integrity directory across processes and hosts. An exclusive lock is the file
+// .integrity/lock, each shared lock is a file .integrity/lock.*. Both contain the PID, the host and the
+// timestamp of the holder. A lock of the same host whose process is gone, or a lock of another host which was
+// not refreshed within five minutes, is stale and removed. Held locks are retried until the wait duration
+// elapsed. An exclusive lock creates the integrity directory, e.g. for the first upsert, while shared locks of a
+// directory without integrity directory lock nothing. The returned release function must be executed.
+func Acquire(basePath string, mode Mode, wait time.Duration) (release func(), err error) {
+	integrityPath := filepath.Join(basePath, dir.Name)
+	if mode == Exclusive {
+		if err := dir.CreateIntegrityDir(basePath); err != nil {
+			return nil, err
+		}
+	} else if info, err := os.Stat(integrityPath); err != nil || !info.IsDir() {
+		return func() {}, nil
+	}
+	owner := newOwner()
+	deadline := time.Now().Add(wait)
+	for {
+		filename, err := tryAcquire(integrityPath, mode, owner)
+		if err == nil {
+			return refresh(filename), nil
+		}
+		if !IsLocked(err) || time.Now().After(deadline) {
+			return nil, err
+		}
+		// Jitter prevents a shared and an exclusive candidate from backing off in lockstep
+		time.Sleep(pollInterval/2 + time.Duration(rand.Int63n(int64(pollInterval))))
+	}
+}
+
+// Both kinds of candidates create their lock file before they check for conflicting locks, hence at least
+// one of two concurrent candidates sees the other and backs off
+func tryAcquire(integrityPath string, mode Mode, owner Owner) (string, error) {
+	if mode == Exclusive {
+		filename := filepath.Join(integrityPath, exclusiveFilename)
+		if err := create(filename, owner); err != nil {
+			return "", err
+		}
+		for _, shared := range sharedFilenames(integrityPath) {
+			if err := assertReleased(shared, Shared); err != nil {
+				os.Remove(filename)
+				return "", err
+			}
+		}
+		return filename, nil
+	}
+	f, err := os.CreateTemp(integrityPath, sharedPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	if err := write(f, owner); err != nil {
+		return "", err
+	}
+	if err := assertReleased(filepath.Join(integrityPath, exclusiveFilename), Exclusive); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Creates the exclusive lock file, a stale lock file is replaced
+func create(filename string, owner Owner) error {
+	for {
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return write(f, owner)
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if err := assertReleased(filename, Exclusive); err != nil {
+			return err
+		}
+	}
+}
+
+func write(f *os.File, owner Owner) error {
+	content, _ := json.Marshal(owner)
+	_, err := f.Write(content)
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Returns nil, if the lock file does not exist or was stale and is removed
+func assertReleased(filename string, mode Mode) error {
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	owner := Owner{}
+	// A lock file without valid content is being written or was torn, hence only its age decides
+	valid := json.Unmarshal(content, &owner) == nil
+	if !stale(owner, valid, info.ModTime()) {
+		return &LockedError{Owner: owner, Mode: mode}
+	}
+	// The lock might have been replaced by another candidate in the meantime
+	if current, err := os.ReadFile(filename); err == nil && string(current) != string(content) {
+		return &LockedError{Owner: owner, Mode: mode}
+	}
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func stale(owner Owner, valid bool, modTime time.Time) bool {
+	if valid && owner.Host == hostname() {
+		return !alive(owner.PID)
+	}
+	return time.Since(modTime) > staleAfter
+}
+
+func sharedFilenames(integrityPath string) []string {
+	entries, _ := os.ReadDir(integrityPath)
+	filenames := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), sharedPrefix) && !entry.IsDir() {
+			filenames = append(filenames, filepath.Join(integrityPath, entry.Name()))
+		}
+	}
+	return filenames
+}
+
+// Refreshes the timestamp of the lock file periodically, so other hosts do not consider the lock stale
+func refresh(filename string) (release func()) {
+	done := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				os.Chtimes(filename, now, now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		os.Remove(filename)
+	}
+}
+
+func newOwner() Owner {
+	return Owner{PID: os.Getpid(), Host: hostname(), Acquired: time.Now().UTC().Truncate(time.Second)}
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}
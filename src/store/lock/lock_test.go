@@ -0,0 +1,108 @@
+package lock
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	basePath := newIntegrityDir(t)
+
+	releaseShared, err := Acquire(basePath, Shared, 0)
+	assert.Nil(t, err)
+	releaseOther, err := Acquire(basePath, Shared, 0)
+	assert.Nil(t, err)
+	_, err = Acquire(basePath, Exclusive, 0)
+	assert.True(t, IsLocked(err))
+	assert.ErrorContains(t, err, "locked (shared) by pid")
+	releaseShared()
+	releaseOther()
+
+	releaseExclusive, err := Acquire(basePath, Exclusive, 0)
+	assert.Nil(t, err)
+	_, err = Acquire(basePath, Shared, 0)
+	assert.ErrorContains(t, err, "locked (exclusive) by pid")
+	_, err = Acquire(basePath, Exclusive, 0)
+	assert.True(t, IsLocked(err))
+	releaseExclusive()
+
+	entries, _ := os.ReadDir(filepath.Join(basePath, dir.Name))
+	assert.Empty(t, entries)
+}
+
+func TestAcquire_wait(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	basePath := newIntegrityDir(t)
+	release, err := Acquire(basePath, Exclusive, 0)
+	assert.Nil(t, err)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		release()
+	}()
+
+	release, err = Acquire(basePath, Shared, 5*time.Second)
+	assert.Nil(t, err)
+	release()
+}
+
+func TestAcquire_stale(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	cases := []struct {
+		name    string
+		owner   Owner
+		modTime time.Time
+		stale   bool
+	}{
+		{name: "Live process", owner: Owner{PID: os.Getpid(), Host: hostname()}, modTime: old, stale: false},
+		{name: "Dead process", owner: Owner{PID: 1 << 30, Host: hostname()}, modTime: time.Now(), stale: true},
+		{name: "Refreshed other host", owner: Owner{PID: 1, Host: "other"}, modTime: time.Now(), stale: false},
+		{name: "Expired other host", owner: Owner{PID: 1, Host: "other"}, modTime: old, stale: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			basePath := newIntegrityDir(t)
+			filename := filepath.Join(basePath, dir.Name, exclusiveFilename)
+			content, _ := json.Marshal(c.owner)
+			os.WriteFile(filename, content, 0644)
+			os.Chtimes(filename, c.modTime, c.modTime)
+
+			release, err := Acquire(basePath, Exclusive, 0)
+			if c.stale {
+				assert.Nil(t, err)
+				release()
+			} else {
+				assert.True(t, IsLocked(err))
+				assert.FileExists(t, filename)
+			}
+		})
+	}
+}
+
+func TestAcquire_withoutIntegrityDir(t *testing.T) {
+	// Shared locks of a directory without integrity directory lock nothing
+	basePath := t.TempDir()
+	release, err := Acquire(basePath, Shared, 0)
+	assert.Nil(t, err)
+	release()
+	assert.NoDirExists(t, filepath.Join(basePath, dir.Name))
+
+	// The first writer creates the integrity directory and locks it
+	release, err = Acquire(basePath, Exclusive, 0)
+	assert.Nil(t, err)
+	assert.DirExists(t, filepath.Join(basePath, dir.Name))
+	_, err = Acquire(basePath, Exclusive, 0)
+	assert.True(t, IsLocked(err))
+	release()
+}
+
+func newIntegrityDir(t *testing.T) string {
+	basePath := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(basePath, dir.Name), 0755))
+	return basePath
+}
@@ -0,0 +1,171 @@
+package repair
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/device"
+	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
+	"github.com/aicirt2012/fileintegrity/src/analysis/parity"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	quarantineDir = "quarantine"
+	paritySource  = "parity"
+	linkSource    = "link target"
+	dirSource     = "directory"
+)
+
+// Repair verifies all files and replaces invalid files by a reconstruction based on the recovery data or by a
+// valid copy of a replica. Replicas are looked up by relative path first and by hash within the replica integrity
+// file afterwards. Temporary files of interrupted repairs are removed first. Every candidate is verified against the expected hash before the invalid file is replaced
+// atomically and kept within the quarantine.
+func Repair(basePath string, replicaPaths []string, options store.Options) {
+	dir.AssertDir(basePath)
+	dir.AssertIntegrityDir(basePath)
+	hash.RemoveTemporaryFiles(basePath)
+	replicas := loadReplicas(replicaPaths)
+	if options.Backup {
+		file.Backup(basePath)
+	}
+	options.Throttle = nil // Repairs are not throttled
+	start := time.Now()
+	summary := ilog.RepairSummary{}
+	logBuffer := ilog.NewAutomaticLogBuffer(basePath, ilog.Repair, 10000, options.Log)
+	_, summary.TotalBytes = file.Totals(basePath)
+
+	invalid := file.FileHashs{}
+	stored := file.NewReader(basePath)
+	progressBar := ilog.ProgressBar(summary.TotalBytes, options.ProgressBar, options.Log.Observer)
+	store.VerifyFiles(basePath, stored, device.Resolve(basePath, options.IOStrategy), options, progressBar, func(fh file.FileHash, err error) {
+		// Metadata drift is reported by verify, but the content requires no repair
+		if err != nil && !hash.IsDrift(err) {
+			invalid = append(invalid, fh)
+		} else {
+			summary.ValidFiles++
+		}
+	})
+	stored.Close()
+	summary.InvalidFiles = int64(len(invalid))
+
+	quarantinePath := filepath.Join(basePath, dir.Name, quarantineDir, start.Format(ilog.TimeFormat))
+	for _, fh := range invalid {
+		existed := exists(path.Absolute(basePath, fh.RelativePath))
+		source, err := restore(basePath, fh, replicas, quarantinePath, options)
+		if err != nil {
+			logBuffer.Append(ilog.RepairLog{Created: time.Now(), Status: ilog.UNREPAIRABLE, RelativePath: fh.RelativePath, Reason: err})
+			summary.UnrepairableFiles++
+			continue
+		}
+		logBuffer.Append(ilog.RepairLog{Created: time.Now(), Status: ilog.REPAIRED, RelativePath: fh.RelativePath, Source: source})
+		summary.RepairedFiles++
+		if existed {
+			summary.QuarantinedFiles++
+		}
+	}
+
+	summary.ExecutionTime = time.Since(start)
+	logBuffer.Append(summary).Flush()
+}
+
+// Restores a file by its recovery data or by the first replica candidate with a matching hash
+func restore(basePath string, fh file.FileHash, replicas []replica, quarantinePath string, options store.Options) (string, error) {
+	request := hash.CopyRequest{
+		TargetPath:     path.Absolute(basePath, fh.RelativePath),
+		TmpDir:         hash.TmpDir(basePath),
+		QuarantinePath: filepath.Join(quarantinePath, filepath.FromSlash(fh.RelativePath)),
+		RelativePath:   fh.RelativePath,
+		ModTime:        fh.ModTime,
+		Hash:           fh.Hash,
+		Algorithm:      options.Algorithm,
+		BufferSize:     options.BufferSize,
+	}
+	// Empty directories are recreated
+	if fh.Dir {
+		request.Dir = true
+		if _, err := hash.CopyVerified(request); err != nil {
+			return "", err
+		}
+		return dirSource, nil
+	}
+	// Links are restored based on the recorded target
+	if fh.IsLink() {
+		request.Target = fh.Target
+		if _, err := hash.CopyVerified(request); err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(request.TargetPath); err != nil {
+			return "", errors.New("link target does not exist")
+		}
+		return linkSource, nil
+	}
+	if parity.Exists(basePath, fh.Hash) {
+		request.SourcePath = parity.Filename(basePath, fh.Hash)
+		if _, err := hash.ReconstructVerified(request); err == nil {
+			return paritySource, nil
+		}
+	}
+	for _, candidate := range candidates(fh, replicas) {
+		request.SourcePath = candidate
+		if _, err := hash.CopyVerified(request); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("no valid recovery data or replica found")
+}
+
+// Candidates with an equal relative path are preferred over candidates with an equal hash
+func candidates(fh file.FileHash, replicas []replica) []string {
+	paths := []string{}
+	for _, r := range replicas {
+		candidate := path.Absolute(r.basePath, fh.RelativePath)
+		if exists(candidate) {
+			paths = append(paths, candidate)
+		}
+	}
+	for _, r := range replicas {
+		for _, rfh := range r.lookup(fh) {
+			candidate := path.Absolute(r.basePath, rfh.RelativePath)
+			if !slices.Contains(paths, candidate) && exists(candidate) {
+				paths = append(paths, candidate)
+			}
+		}
+	}
+	return paths
+}
+
+// Replicas without integrity file are only looked up by relative path
+func loadReplicas(replicaPaths []string) []replica {
+	replicas := []replica{}
+	for _, replicaPath := range replicaPaths {
+		dir.AssertDir(replicaPath)
+		r := replica{
+			basePath: replicaPath,
+			content:  map[string]file.FileHashs{},
+		}
+		if exists(filepath.Join(replicaPath, dir.Name)) {
+			for _, fh := range file.LoadContent(replicaPath).DefragmentedMap() {
+				r.content[key(fh)] = append(r.content[key(fh)], fh)
+			}
+			for _, fhs := range r.content {
+				sort.Sort(fhs)
+			}
+		}
+		replicas = append(replicas, r)
+	}
+	return replicas
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,48 @@
+package repair
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCandidates(t *testing.T) {
+	replicaA := t.TempDir()
+	replicaB := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(replicaA, "renamed.txt"),
+		filepath.Join(replicaB, "a.txt"),
+		filepath.Join(replicaB, "copy.txt"),
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+	}
+	fh := file.FileHash{Hash: "h1", Size: 7, RelativePath: "a.txt"}
+	replicas := []replica{
+		{
+			basePath: replicaA,
+			content: map[string]file.FileHashs{
+				key(fh): {{Hash: "h1", Size: 7, RelativePath: "renamed.txt"}},
+			},
+		},
+		{
+			basePath: replicaB,
+			content: map[string]file.FileHashs{
+				key(fh): {
+					{Hash: "h1", Size: 7, RelativePath: "a.txt"},
+					{Hash: "h1", Size: 7, RelativePath: "copy.txt"},
+					{Hash: "h1", Size: 7, RelativePath: "missing.txt"},
+				},
+			},
+		},
+	}
+
+	expected := []string{
+		filepath.Join(replicaB, "a.txt"),
+		filepath.Join(replicaA, "renamed.txt"),
+		filepath.Join(replicaB, "copy.txt"),
+	}
+	assert.Equal(t, expected, candidates(fh, replicas))
+}
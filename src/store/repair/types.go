@@ -0,0 +1,21 @@
+package repair
+
+import (
+	"strconv"
+
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+)
+
+type replica struct {
+	basePath string
+	content  map[string]file.FileHashs
+}
+
+// Returns entries of the replica integrity file with an equal content
+func (r replica) lookup(fh file.FileHash) file.FileHashs {
+	return r.content[key(fh)]
+}
+
+func key(fh file.FileHash) string {
+	return fh.Hash + strconv.FormatInt(fh.Size, 10)
+}
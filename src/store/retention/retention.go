@@ -0,0 +1,178 @@
+package retention
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+const (
+	logSuffix    = ".log"
+	backupSuffix = ".integrity.zip"
+)
+
+// GC removes the logs and backups of the integrity directory, which are expired according to the policies.
+// With the dry run flag, expired files are only reported.
+func GC(basePath string, policies Policies, dryRun bool, options store.Options) {
+	dir.AssertIntegrityDir(basePath)
+	start := time.Now()
+	artifacts, err := list(basePath)
+	if err != nil {
+		log.Fatal("could not list integrity dir", err)
+	}
+	expired := expire(artifacts, policies)
+	summary := ilog.RetentionSummary{
+		KeptFiles: int64(len(artifacts) - len(expired)),
+		DryRun:    dryRun,
+	}
+	logBuffer := ilog.NewManualLogBuffer(basePath, ilog.Retention, options.Log)
+	for _, a := range expired {
+		status := ilog.EXPIRED
+		if !dryRun {
+			if err := os.Remove(filepath.Join(basePath, dir.Name, a.name)); err != nil {
+				log.Fatal("could not remove expired file", err)
+			}
+			status = ilog.REMOVED
+		}
+		logBuffer.Append(ilog.RetentionLog{Status: status, Filename: a.name, Size: a.size})
+		if a.backup {
+			summary.RemovedBackups++
+		} else {
+			summary.RemovedLogs++
+		}
+		summary.RemovedBytes += a.size
+	}
+	summary.ExecutionTime = time.Since(start)
+	logBuffer.Append(summary).Flush()
+}
+
+// Prune removes the expired logs and backups without reporting, e.g. after each execution. A directory
+// without integrity directory is skipped.
+func Prune(basePath string, policies Policies) error {
+	if policies.Logs.keepsAll() && policies.Logs.MaxSize == 0 && policies.Backups.keepsAll() && policies.Backups.MaxSize == 0 {
+		return nil
+	}
+	artifacts, err := list(basePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, a := range expire(artifacts, policies) {
+		if err := os.Remove(filepath.Join(basePath, dir.Name, a.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the artifacts, which are not kept by the policies
+func expire(artifacts []artifact, policies Policies) []artifact {
+	groups := map[string][]artifact{}
+	for _, a := range artifacts {
+		groups[a.group] = append(groups[a.group], a)
+	}
+	expired := []artifact{}
+	for group, grouped := range groups {
+		policy := policies.Logs
+		if group == "" {
+			policy = policies.Backups
+		}
+		expired = append(expired, policy.expired(grouped)...)
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].name < expired[j].name
+	})
+	return expired
+}
+
+// Applies the policy to the artifacts of a group
+func (p Policy) expired(artifacts []artifact) []artifact {
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].created.After(artifacts[j].created)
+	})
+	keep := make([]bool, len(artifacts))
+	for i := range artifacts {
+		keep[i] = p.keepsAll() || i < p.KeepLast || i == 0
+	}
+	keepPeriods(artifacts, keep, p.KeepDaily, day)
+	keepPeriods(artifacts, keep, p.KeepWeekly, week)
+	keepPeriods(artifacts, keep, p.KeepMonthly, month)
+	if p.MaxSize > 0 {
+		total := int64(0)
+		for i, a := range artifacts {
+			if !keep[i] {
+				continue
+			}
+			total += a.size
+			if total > p.MaxSize && i > 0 {
+				keep[i] = false
+			}
+		}
+	}
+	expired := []artifact{}
+	for i, a := range artifacts {
+		if !keep[i] {
+			expired = append(expired, a)
+		}
+	}
+	return expired
+}
+
+// Keeps the newest artifact of each of the newest n periods, the artifacts are ordered by creation descending
+func keepPeriods(artifacts []artifact, keep []bool, n int, period func(time.Time) string) {
+	last := ""
+	for i, a := range artifacts {
+		if n <= 0 {
+			return
+		}
+		if key := period(a.created); key != last {
+			keep[i] = true
+			last = key
+			n--
+		}
+	}
+}
+
+// Lists the logs and backups of the integrity directory. The creation is parsed from the filename.
+func list(basePath string) ([]artifact, error) {
+	entries, err := os.ReadDir(filepath.Join(basePath, dir.Name))
+	if err != nil {
+		return nil, err
+	}
+	artifacts := []artifact{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) <= len(ilog.TimeFormat) {
+			continue
+		}
+		created, err := time.ParseInLocation(ilog.TimeFormat, name[:len(ilog.TimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		a := artifact{name: name, created: created}
+		rest := name[len(ilog.TimeFormat):]
+		switch {
+		case rest == backupSuffix:
+			a.backup = true
+		case strings.HasPrefix(rest, ".") && strings.HasSuffix(rest, logSuffix):
+			a.group = strings.TrimSuffix(rest[1:], logSuffix)
+		default:
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		a.size = info.Size()
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, nil
+}
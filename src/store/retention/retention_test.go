@@ -0,0 +1,69 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_expired(t *testing.T) {
+	// Nightly runs of 60 days, the newest run is at the end of the 2nd of March
+	newest := time.Date(2024, 3, 2, 23, 0, 0, 0, time.Local)
+	artifacts := []artifact{}
+	for i := 0; i < 60; i++ {
+		artifacts = append(artifacts, artifact{name: name(newest.AddDate(0, 0, -i)), created: newest.AddDate(0, 0, -i), size: 10})
+	}
+
+	cases := []struct {
+		name     string
+		policy   Policy
+		expected int
+	}{
+		{name: "Keep all", policy: Policy{}, expected: 0},
+		{name: "Keep last", policy: Policy{KeepLast: 7}, expected: 53},
+		{name: "Keep daily", policy: Policy{KeepDaily: 7}, expected: 53},
+		{name: "Keep monthly", policy: Policy{KeepMonthly: 3}, expected: 57},
+		{name: "Keep combined", policy: Policy{KeepLast: 2, KeepMonthly: 3}, expected: 56},
+		{name: "Max size", policy: Policy{MaxSize: 100}, expected: 50},
+		{name: "Max size below newest", policy: Policy{MaxSize: 5}, expected: 59},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expired := c.policy.expired(append([]artifact{}, artifacts...))
+			assert.Len(t, expired, c.expected)
+			for _, a := range expired {
+				assert.NotEqual(t, name(newest), a.name)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	basePath := t.TempDir()
+	integrityPath := filepath.Join(basePath, dir.Name)
+	os.Mkdir(integrityPath, 0755)
+	now := time.Now().Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		created := now.Add(-time.Duration(i) * time.Hour).Format(ilog.TimeFormat)
+		os.WriteFile(filepath.Join(integrityPath, created+".upsert.log"), []byte("log"), 0644)
+		os.WriteFile(filepath.Join(integrityPath, created+".extension stats.log"), []byte("log"), 0644)
+		os.WriteFile(filepath.Join(integrityPath, created+".integrity.zip"), []byte("zip"), 0644)
+	}
+	os.WriteFile(filepath.Join(integrityPath, ".integrity"), []byte("entries"), 0644)
+
+	assert.Nil(t, Prune(basePath, Policies{Logs: Policy{KeepLast: 2}, Backups: Policy{KeepLast: 1}}))
+
+	entries, _ := os.ReadDir(integrityPath)
+	assert.Len(t, entries, 1+2*2+1)
+	assert.FileExists(t, filepath.Join(integrityPath, now.Format(ilog.TimeFormat)+".integrity.zip"))
+	assert.Nil(t, Prune(filepath.Join(basePath, "missing"), Policies{Logs: Policy{KeepLast: 1}}))
+}
+
+func name(created time.Time) string {
+	return created.Format(ilog.TimeFormat) + ".verify.log"
+}
@@ -0,0 +1,49 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policy selects the files to keep, similar to restic. The rules are combined, a file is kept if any rule
+// keeps it. A policy without keep rules keeps all files. The newest file is always kept.
+type Policy struct {
+	KeepLast    int   // Number of newest files
+	KeepDaily   int   // Number of days, the newest file of each day is kept
+	KeepWeekly  int   // Number of weeks, the newest file of each week is kept
+	KeepMonthly int   // Number of months, the newest file of each month is kept
+	MaxSize     int64 // Total size of the kept files in bytes, older files exceeding the size are removed
+}
+
+// Policies for the logs and the backups of the integrity file. Logs are kept per category.
+type Policies struct {
+	Logs    Policy
+	Backups Policy
+}
+
+func (p Policy) keepsAll() bool {
+	return p.KeepLast == 0 && p.KeepDaily == 0 && p.KeepWeekly == 0 && p.KeepMonthly == 0
+}
+
+// Log or backup within the integrity directory
+type artifact struct {
+	name    string
+	group   string // Category of the logs, empty for backups
+	backup  bool
+	created time.Time
+	size    int64
+}
+
+// Periods of the keep rules, files within the same period share the key
+func day(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func week(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-%d", year, week)
+}
+
+func month(t time.Time) string {
+	return t.Format("2006-01")
+}
@@ -1,180 +1,426 @@
 package store
 
 import (
-	"runtime"
+	"errors"
+	"log"
 	"time"
 
+	"github.com/aicirt2012/fileintegrity/src/analysis/chunk"
+	"github.com/aicirt2012/fileintegrity/src/analysis/device"
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
 	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
+	"github.com/aicirt2012/fileintegrity/src/analysis/meta"
+	"github.com/aicirt2012/fileintegrity/src/analysis/parity"
 	"github.com/aicirt2012/fileintegrity/src/analysis/path"
 	"github.com/aicirt2012/fileintegrity/src/store/dir"
 	"github.com/aicirt2012/fileintegrity/src/store/file"
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
-
-	"golang.org/x/exp/maps"
 )
 
+// Number of files which are processed concurrently, bounds the memory of the pipeline
+const window = 1000
+
+// Upsert walks the disk and the sorted integrity file side by side. Neither the disk files nor the entries
+// are kept in memory, hence the memory is bounded independent of the number of files.
 func Upsert(basePath string, options Options) error {
 	dir.AssertDir(basePath)
 	dir.UpsertIntegrityDir(basePath)
+	if err := AssertAlgorithm(basePath, options.Algorithm); err != nil {
+		return err
+	}
 	if options.Backup {
 		file.Backup(basePath)
 	}
+	defer options.Throttle.Start(basePath)()
 	start := time.Now()
 
 	logBuffer := ilog.NewManualLogBuffer(basePath, ilog.Upsert, options.Log)
 	fileBuffer := file.NewFileHashsBuffer(basePath, 1, logBuffer.Flush)
 
-	fileHashes := file.LoadContent(basePath)
-	fileHashMap := fileHashes.DefragmentedMap()
-	diskFileMap, err := path.ComputeDiskFileMap(basePath)
-	if err != nil {
-		return err
-	}
-
+	strategy := device.Resolve(basePath, options.IOStrategy)
 	summary := ilog.UpsertSummary{
-		TotalBytes: diskFileMap.TotalBytes(),
+		IOStrategy: string(strategy),
 	}
 
-	progressBar := ilog.ProgressBar(summary.TotalBytes, options.ProgressBar)
-
-	// Process unchanged entries
-	for _, file := range maps.Values(diskFileMap) {
-		fileHash, exists := fileHashMap[file.RelativePath]
-		if exists && fileHash.ModTime.Equal(file.ModTime) && fileHash.Size == file.Size {
-			diskFileMap.Remove(fileHash.RelativePath)
-			fileHashMap.Remove(fileHash.RelativePath)
-			progressBar.Add64(file.Size)
-			summary.SkippedFiles++
-		}
-	}
+	progressBar := ilog.ProgressBar(-1, options.ProgressBar, options.Log.Observer)
 
 	// Initialize channels
 	requests := make(chan hash.CreateRequest, 10)
 	responses := make(chan hash.CreateResponse, 100)
+	tasks := make(chan upsertTask, window)
 	await := make(chan bool)
 
-	// Consume file hash responses
+	// Consume tasks in walk order, so the appended entries remain sorted
 	go func() {
-		for i := 0; i < len(diskFileMap); i++ {
-			response := <-responses
-			diskFile := diskFileMap[response.RelativePath]
-			fileBuffer.Append(file.FileHash{
-				Hash:         response.Hash,
-				Created:      time.Now(),
-				ModTime:      diskFile.ModTime,
-				Size:         diskFile.Size,
-				RelativePath: response.RelativePath,
-			})
-			if fileHashMap.Has(response.RelativePath) {
-				logBuffer.AppendUpsertLog(ilog.UPDATE, response.RelativePath)
+		received := map[string]hash.CreateResponse{}
+		inodeHashes := linkedHashes{}
+		for task := range tasks {
+			inode := task.diskFile.Inode.ID()
+			if task.skipped {
+				inodeHashes.set(inode, task.previous.Hash, task.last)
+				continue
+			}
+			if task.parity {
+				response := awaitResponse(task.diskFile.RelativePath, responses, received, func(r hash.CreateResponse) string {
+					return r.RelativePath
+				})
+				summary.AddHashedBytes(response.HashedBytes)
+				inodeHashes.set(inode, task.previous.Hash, task.last)
+				// Recovery data of a differing content is removed by the prune, verify reports the file
+				if response.Error != nil || response.Hash != task.previous.Hash {
+					log.Printf("%v: recovery data not created, the content differs from the entry", task.previous.RelativePath)
+					continue
+				}
+				summary.ParityFiles++
+				continue
+			}
+			if task.deleted {
+				fileBuffer.Append(deletedEntry(task.previous))
+				logBuffer.AppendUpsertLog(ilog.DELETE, task.previous.RelativePath)
+				summary.DeletedFiles++
+				continue
+			}
+			fileHash := task.hash
+			switch {
+			case task.hash != "":
+			case task.linked:
+				fileHash = inodeHashes[inode]
+				summary.LinkedFiles++
+			default:
+				response := awaitResponse(task.diskFile.RelativePath, responses, received, func(r hash.CreateResponse) string {
+					return r.RelativePath
+				})
+				fileHash = response.Hash
+				summary.AddHashedBytes(response.HashedBytes)
+			}
+			inodeHashes.set(inode, fileHash, task.last)
+			fileBuffer.Append(newEntry(task.diskFile, fileHash))
+			if task.metadata {
+				logBuffer.AppendUpsertLog(ilog.METADATA, task.diskFile.RelativePath)
+				summary.MetadataFiles++
+			} else if task.exists {
+				logBuffer.AppendUpsertLog(ilog.UPDATE, task.diskFile.RelativePath)
 				summary.UpdatedFiles++
 			} else {
-				logBuffer.AppendUpsertLog(ilog.NEW, response.RelativePath)
+				logBuffer.AppendUpsertLog(ilog.NEW, task.diskFile.RelativePath)
 				summary.NewFiles++
 			}
-			summary.AddHashedBytes(diskFile.Size)
-			progressBar.Add64(diskFile.Size)
+			progressBar.Add64(task.diskFile.Size)
 		}
 		fileBuffer.Flush()
 		await <- true
 	}()
 
 	// Create file hash workers
-	for w := 1; w <= runtime.NumCPU(); w++ {
+	for w := 1; w <= strategy.Workers(); w++ {
 		go hash.CreationWorker(requests, responses)
 	}
 
-	// Produce file hash requests for all new or not up to date entries
-	for _, file := range maps.Values(diskFileMap) {
-		requests <- hash.CreateRequest{
-			BasePath:     basePath,
-			RelativePath: file.RelativePath,
+	// Each inode is hashed once, further hardlinks reuse the hash
+	links := hardlinks{}
+	hashTask := func(task upsertTask, visited bool) {
+		if task.diskFile.Target != "" {
+			task.hash = hash.Link(task.diskFile.Target, options.Algorithm)
+			tasks <- task
+			return
+		}
+		if task.diskFile.Dir {
+			task.hash = hash.DirHash
+			tasks <- task
+			return
+		}
+		if visited {
+			task.linked = true
+			tasks <- task
+			return
 		}
+		tasks <- task
+		requests <- createRequest(basePath, task.diskFile, task.previous, options)
 	}
-	<-await
 
-	// Delete hashes for non existing files
-	for _, hash := range maps.Values(fileHashMap) {
-		if _, exists := diskFileMap[hash.RelativePath]; exists {
+	// Merge disk files and entries ordered by relative path. Unchanged entries are skipped, new or not up
+	// to date entries are hashed and entries of non existing files are deleted.
+	stored := file.NewReader(basePath)
+	defer stored.Close()
+	diskFiles, walkErr := path.Stream(basePath, options.Symlinks, options.Ignore)
+	diskFile, hasDiskFile := <-diskFiles
+	fileHash, hasFileHash := stored.Next()
+	for hasDiskFile || hasFileHash {
+		c := 0
+		if hasDiskFile && hasFileHash {
+			c = path.Compare(diskFile.RelativePath, fileHash.RelativePath)
+		}
+		if !hasFileHash || hasDiskFile && c < 0 {
+			summary.TotalBytes += diskFile.Size
+			diskFile.Metadata = captureMetadata(diskFile, file.FileHash{}, options)
+			visited, last := links.visit(diskFile.Inode)
+			hashTask(upsertTask{diskFile: diskFile, last: last}, visited)
+			diskFile, hasDiskFile = <-diskFiles
+			continue
+		}
+		if !hasDiskFile || c > 0 {
+			// Entries are only deleted, if the walk is complete
+			if !hasDiskFile && walkErr() != nil {
+				break
+			}
+			tasks <- upsertTask{previous: fileHash, deleted: true}
+			fileHash, hasFileHash = stored.Next()
 			continue
 		}
-		fileBuffer.Append(file.FileHash{
-			Hash:         file.EmptyHash,
-			Created:      time.Now(),
-			ModTime:      hash.ModTime,
-			Size:         hash.Size,
-			RelativePath: hash.RelativePath,
-		})
-		logBuffer.AppendUpsertLog(ilog.DELETE, hash.RelativePath)
-		summary.DeletedFiles++
-	}
-
-	fileBuffer.Flush()
+		summary.TotalBytes += diskFile.Size
+		diskFile.Metadata = captureMetadata(diskFile, fileHash, options)
+		visited, last := links.visit(diskFile.Inode)
+		switch {
+		case !fileHash.ModTime.Equal(diskFile.ModTime) || fileHash.Size != diskFile.Size || fileHash.Target != diskFile.Target || fileHash.Dir != diskFile.Dir:
+			hashTask(upsertTask{diskFile: diskFile, previous: fileHash, exists: true, last: last}, visited)
+		case options.Metadata && fileHash.Metadata != diskFile.Metadata:
+			tasks <- upsertTask{diskFile: diskFile, previous: fileHash, exists: true, hash: fileHash.Hash, last: last, metadata: true}
+		case fileHash.Inode != diskFile.Inode.ID():
+			// Hardlinks were added or removed without changing the content
+			tasks <- upsertTask{diskFile: diskFile, previous: fileHash, exists: true, hash: fileHash.Hash, last: last}
+		default:
+			if options.Redundancy > 0 && !visited && missingParity(basePath, fileHash) {
+				// Entries upserted without redundancy receive their recovery data with the next upsert
+				tasks <- upsertTask{diskFile: diskFile, previous: fileHash, parity: true, last: last}
+				requests <- createRequest(basePath, diskFile, fileHash, options)
+			} else if diskFile.Inode.Linked() && (!visited || last) {
+				tasks <- upsertTask{diskFile: diskFile, previous: fileHash, skipped: true, last: last}
+			}
+			progressBar.Add64(diskFile.Size)
+			summary.SkippedFiles++
+		}
+		diskFile, hasDiskFile = <-diskFiles
+		fileHash, hasFileHash = stored.Next()
+	}
+	close(tasks)
+	close(requests)
+	<-await
+	stored.Close()
+	if err := walkErr(); err != nil {
+		return err
+	}
+
 	file.Defragment(basePath)
+	if err := prune(basePath); err != nil {
+		return err
+	}
 	summary.ExecutionTime = time.Since(start)
 	logBuffer.Append(summary).Flush()
 
 	return nil
 }
 
+func newEntry(diskFile path.DiskFile, hash string) file.FileHash {
+	return file.FileHash{
+		Hash:         hash,
+		Created:      time.Now(),
+		ModTime:      diskFile.ModTime,
+		Size:         diskFile.Size,
+		RelativePath: diskFile.RelativePath,
+		Inode:        diskFile.Inode.ID(),
+		Target:       diskFile.Target,
+		Metadata:     diskFile.Metadata,
+		Dir:          diskFile.Dir,
+	}
+}
+
+// Returns true, if the entry of a non empty file has no recovery data
+func missingParity(basePath string, fileHash file.FileHash) bool {
+	if fileHash.Size == 0 || fileHash.Target != "" || fileHash.Dir {
+		return false
+	}
+	return !parity.Exists(basePath, fileHash.Hash)
+}
+
+// Marks the entry as deleted
+func deletedEntry(previous file.FileHash) file.FileHash {
+	return file.FileHash{
+		Hash:         file.EmptyHash,
+		Created:      time.Now(),
+		ModTime:      previous.ModTime,
+		Size:         previous.Size,
+		RelativePath: previous.RelativePath,
+	}
+}
+
+// Captures the metadata of files, if enabled. Otherwise the metadata of the previous entry is kept, so upserts
+// without metadata do not drop it. Files with unreadable extended attributes are captured without them.
+func captureMetadata(diskFile path.DiskFile, previous file.FileHash, options Options) meta.Metadata {
+	if diskFile.Target != "" {
+		return meta.Metadata{}
+	}
+	if !options.Metadata {
+		return previous.Metadata
+	}
+	m := diskFile.Metadata
+	xattrs, err := meta.ReadXattrs(diskFile.AbsolutePath, options.Xattrs)
+	if err != nil {
+		log.Printf("%v: %v", diskFile.RelativePath, err)
+	}
+	m.Xattrs = xattrs
+	return m
+}
+
+func createRequest(basePath string, diskFile path.DiskFile, previous file.FileHash, options Options) hash.CreateRequest {
+	return hash.CreateRequest{
+		BasePath:     basePath,
+		RelativePath: diskFile.RelativePath,
+		Redundancy:   options.Redundancy,
+		ChunkSize:    options.ChunkSize,
+		AppendOnly:   options.AppendOnly,
+		PreviousHash: previous.Hash,
+		PreviousSize: previous.Size,
+		Algorithm:    options.Algorithm,
+		BufferSize:   options.BufferSize,
+		Throttle:     options.Throttle,
+	}
+}
+
+// Returns the response of the relative path. Responses of other files are kept until they are awaited.
+func awaitResponse[T any](relativePath string, responses <-chan T, received map[string]T, key func(T) string) T {
+	for {
+		if response, exists := received[relativePath]; exists {
+			delete(received, relativePath)
+			return response
+		}
+		response := <-responses
+		received[key(response)] = response
+	}
+}
+
+// Removes recovery data and chunk hashes of deleted or updated files
+func prune(basePath string) error {
+	existing := parity.Hashes(basePath)
+	for hash := range chunk.Hashes(basePath) {
+		existing[hash] = true
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+	hashes := map[string]bool{}
+	stored := file.NewReader(basePath)
+	defer stored.Close()
+	for fileHash, ok := stored.Next(); ok; fileHash, ok = stored.Next() {
+		if existing[fileHash.Hash] {
+			hashes[fileHash.Hash] = true
+		}
+	}
+	if err := parity.Prune(basePath, hashes); err != nil {
+		return err
+	}
+	return chunk.Prune(basePath, hashes)
+}
+
+// AssertAlgorithm asserts that the stored hashes were created with the algorithm, based on the hash length of the first file
+func AssertAlgorithm(basePath string, algorithm digest.Algorithm) error {
+	if !file.Exists(basePath) {
+		return nil
+	}
+	stored := file.NewReader(basePath)
+	defer stored.Close()
+	for fileHash, ok := stored.Next(); ok; fileHash, ok = stored.Next() {
+		if fileHash.Dir || fileHash.Hash == file.EmptyHash {
+			continue
+		}
+		if len(fileHash.Hash) != algorithm.HexLen() {
+			return errors.New("integrity file was created with another hash algorithm than " + string(algorithm))
+		}
+		return nil
+	}
+	return nil
+}
+
 func Verify(basePath string, options Options) error {
 	dir.AssertDir(basePath)
 	dir.AssertIntegrityDir(basePath)
+	if err := AssertAlgorithm(basePath, options.Algorithm); err != nil {
+		return err
+	}
+	defer options.Throttle.Start(basePath)()
 	start := time.Now()
 	logBuffer := ilog.NewAutomaticLogBuffer(basePath, ilog.Verify, 1000, options.Log)
-	fileHashes := file.LoadContent(basePath)
-	fileHashesMap := fileHashes.DefragmentedMap()
-	totalBytes := fileHashes.TotalBytes()
+	_, totalBytes := file.Totals(basePath)
+	validCount := int64(0)
 	errorCount := int64(0)
+	driftCount := int64(0)
+	strategy := device.Resolve(basePath, options.IOStrategy)
+
+	stored := file.NewReader(basePath)
+	defer stored.Close()
+	progressBar := ilog.ProgressBar(totalBytes, options.ProgressBar, options.Log.Observer)
+	VerifyFiles(basePath, stored, strategy, options, progressBar, func(fileHash file.FileHash, err error) {
+		status := ilog.OK
+		if hash.IsDrift(err) {
+			status = ilog.DRIFT
+			driftCount++
+			validCount++
+		} else if err != nil {
+			status = ilog.ERROR
+			errorCount++
+		} else {
+			validCount++
+		}
+		logBuffer.AppendVerifyLog(status, fileHash.RelativePath, err)
+	})
+
+	logBuffer.Append(ilog.VerifySummary{
+		ExecutionTime: time.Since(start),
+		TotalBytes:    totalBytes,
+		ValidFiles:    validCount,
+		InvalidFiles:  errorCount,
+		IOStrategy:    string(strategy),
+		DriftFiles:    driftCount,
+	}).Flush()
+	return nil
+}
 
-	progressBar := ilog.ProgressBar(totalBytes, options.ProgressBar)
+// VerifyFiles verifies the given entries with workers according to the I/O strategy. The done callback is executed
+// sequentially in the order of the entries with the verification error, if the entry is invalid. Files with valid
+// content, but changed metadata are reported with a hash.DriftError.
+func VerifyFiles(basePath string, fileHashes file.Iterator, strategy device.Strategy, options Options, progressBar *ilog.Progress, done func(file.FileHash, error)) {
 
 	// Initialize channels
 	requests := make(chan hash.VerifyRequest, 10)
 	responses := make(chan hash.VerifyResponse, 100)
+	tasks := make(chan file.FileHash, window)
 	await := make(chan bool)
 
-	// Consume file hash responses
+	// Consume file hash responses in the order of the entries
 	go func() {
-		for i := 0; i < len(fileHashes); i++ {
-			response := <-responses
-			fileHash := fileHashesMap[response.RelativePath]
-
-			status := ilog.OK
-			var reason error
-			if response.Error != nil {
-				status = ilog.ERROR
-				reason = response.Error
-				errorCount++
-			}
-			logBuffer.AppendVerifyLog(status, response.RelativePath, reason)
+		received := map[string]hash.VerifyResponse{}
+		for fileHash := range tasks {
+			response := awaitResponse(fileHash.RelativePath, responses, received, func(r hash.VerifyResponse) string {
+				return r.RelativePath
+			})
+			done(fileHash, response.Error)
 			progressBar.Add64(fileHash.Size)
 		}
 		await <- true
 	}()
 
 	// Create file hash workers
-	for w := 1; w <= runtime.NumCPU(); w++ {
+	for w := 1; w <= strategy.Workers(); w++ {
 		go hash.VerifyWorker(requests, responses)
 	}
 
 	// Produce file hash requests
-	for _, fileHash := range fileHashes {
+	for fileHash, ok := fileHashes.Next(); ok; fileHash, ok = fileHashes.Next() {
+		tasks <- fileHash
 		requests <- hash.VerifyRequest{
 			BasePath:     basePath,
 			RelativePath: fileHash.RelativePath,
 			Size:         fileHash.Size,
 			ModTime:      fileHash.ModTime,
 			Hash:         fileHash.Hash,
+			Target:       fileHash.Target,
+			Dir:          fileHash.Dir,
+			Metadata:     fileHash.Metadata,
+			Algorithm:    options.Algorithm,
+			BufferSize:   options.BufferSize,
+			Throttle:     options.Throttle,
 		}
 	}
+	close(tasks)
+	close(requests)
 	<-await
-
-	logBuffer.Append(ilog.VerifySummary{
-		ExecutionTime: time.Since(start),
-		TotalBytes:    totalBytes,
-		ValidFiles:    int64(len(fileHashes) - int(errorCount)),
-		InvalidFiles:  errorCount,
-	}).Flush()
-	return nil
 }
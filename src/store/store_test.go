@@ -0,0 +1,91 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+// The benchmarks report the peak heap, which remains bounded for a growing number of files
+func BenchmarkUpsert(b *testing.B) {
+	for _, n := range []int{1000, 10000, 50000} {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			basePath := createTree(b, n)
+			b.ResetTimer()
+			peak := measurePeakHeap(func() {
+				for i := 0; i < b.N; i++ {
+					os.RemoveAll(filepath.Join(basePath, ".integrity"))
+					if err := Upsert(basePath, Options{Log: ilog.Options{File: true}}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.ReportMetric(float64(peak)/(1024*1024), "peak-heap-MiB")
+		})
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	for _, n := range []int{1000, 10000, 50000} {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			basePath := createTree(b, n)
+			if err := Upsert(basePath, Options{Log: ilog.Options{File: true}}); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			peak := measurePeakHeap(func() {
+				for i := 0; i < b.N; i++ {
+					Verify(basePath, Options{Log: ilog.Options{File: true}})
+				}
+			})
+			b.ReportMetric(float64(peak)/(1024*1024), "peak-heap-MiB")
+		})
+	}
+}
+
+func createTree(b *testing.B, n int) string {
+	basePath := b.TempDir()
+	for i := 0; i < n; i++ {
+		filename := filepath.Join(basePath, fmt.Sprintf("d%03d", i%100), fmt.Sprintf("f%06d.txt", i))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(filename), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return basePath
+}
+
+// Samples the live heap objects during the execution
+func measurePeakHeap(fn func()) uint64 {
+	runtime.GC()
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	peak := uint64(0)
+	done := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			metrics.Read(sample)
+			peak = max(peak, sample[0].Value.Uint64())
+			select {
+			case <-done:
+				close(stopped)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	fn()
+	close(done)
+	<-stopped
+	return peak
+}
@@ -0,0 +1,90 @@
+package transfer
+
+import (
+	slashpath "path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"golang.org/x/exp/maps"
+)
+
+// Copy copies all files of the source integrity file matching the pattern into the target directory.
+// The written bytes are verified against the source hash and matching entries are appended to the
+// target integrity file, so the target is verifiable without a second hash pass. Temporary files of
+// interrupted copies are removed first.
+func Copy(sourcePath string, pattern string, targetPath string, options store.Options) {
+	dir.AssertDir(sourcePath)
+	dir.AssertIntegrityDir(sourcePath)
+	dir.AssertDir(targetPath)
+	dir.UpsertIntegrityDir(targetPath)
+	hash.RemoveTemporaryFiles(targetPath)
+	if options.Backup {
+		file.Backup(targetPath)
+	}
+	start := time.Now()
+	summary := ilog.CopySummary{}
+	logBuffer := ilog.NewManualLogBuffer(targetPath, ilog.Copy, options.Log)
+	fileBuffer := file.NewFileHashsBuffer(targetPath, 1, logBuffer.Flush)
+
+	source := file.LoadContent(sourcePath).DefragmentedMap()
+	target := file.LoadContent(targetPath).DefragmentedMap()
+	matches := match(maps.Values(source), pattern)
+	summary.MatchedFiles = int64(len(matches))
+
+	copyFiles(sourcePath, targetPath, matches, options, func(fh file.FileHash, err error) {
+		if err != nil {
+			logBuffer.AppendSyncLog(ilog.FAIL, fh.RelativePath, err)
+			summary.FailedFiles++
+			return
+		}
+		fileBuffer.Append(newEntry(fh, fh.RelativePath))
+		if target.Has(fh.RelativePath) {
+			logBuffer.AppendSyncLog(ilog.REPLACE, fh.RelativePath, nil)
+			summary.ReplacedFiles++
+		} else {
+			logBuffer.AppendSyncLog(ilog.COPY, fh.RelativePath, nil)
+			summary.CopiedFiles++
+		}
+		summary.CopiedBytes += fh.Size
+	})
+
+	fileBuffer.Flush()
+	file.Defragment(targetPath)
+	summary.ExecutionTime = time.Since(start)
+	logBuffer.Append(summary).Flush()
+}
+
+// An entry matches, if the pattern matches the relative path or one of its parent directories.
+// Therefore, the pattern can be a relative file path, a directory path or a glob.
+func match(fhs file.FileHashs, pattern string) file.FileHashs {
+	pattern = path.Canonical(filepath.Clean(pattern))
+	matches := file.FileHashs{}
+	for _, fh := range fhs {
+		if matchPath(fh.RelativePath, pattern) {
+			matches = append(matches, fh)
+		}
+	}
+	sort.Sort(matches)
+	return matches
+}
+
+func matchPath(relativePath string, pattern string) bool {
+	sections := path.Split(relativePath)
+	for i := range sections {
+		parent := path.Join(sections[:i+1]...)
+		if parent == pattern {
+			return true
+		}
+		if ok, err := slashpath.Match(pattern, parent); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
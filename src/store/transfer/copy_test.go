@@ -0,0 +1,64 @@
+package transfer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	fhs := file.FileHashs{
+		{RelativePath: "a/a1.txt"},
+		{RelativePath: "a/a2.md"},
+		{RelativePath: "b/b1.txt"},
+		{RelativePath: "b/bb/bb1.txt"},
+	}
+	cases := []struct {
+		name     string
+		pattern  string
+		expected []string
+	}{
+		{
+			name:     "Relative file path",
+			pattern:  "a/a1.txt",
+			expected: []string{"a/a1.txt"},
+		},
+		{
+			name:     "Directory path",
+			pattern:  "b",
+			expected: []string{"b/b1.txt", "b/bb/bb1.txt"},
+		},
+		{
+			name:     "Directory path with trailing separator",
+			pattern:  "b/bb/",
+			expected: []string{"b/bb/bb1.txt"},
+		},
+		{
+			name:     "File glob",
+			pattern:  "*/*.txt",
+			expected: []string{"a/a1.txt", "b/b1.txt"},
+		},
+		{
+			name:     "Directory glob",
+			pattern:  "?",
+			expected: []string{"a/a1.txt", "a/a2.md", "b/b1.txt", "b/bb/bb1.txt"},
+		},
+		{
+			name:     "No match",
+			pattern:  "c",
+			expected: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := []string{}
+			for _, fh := range match(fhs, filepath.FromSlash(c.pattern)) {
+				actual = append(actual, fh.RelativePath)
+			}
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package transfer
+
+import (
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/diff"
+	"github.com/aicirt2012/fileintegrity/src/store/dir"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+// Sync mirrors the source into the target directory based on both integrity files. Missing and modified files
+// are copied and verified by the hash of the written bytes, so the target integrity file is updated without
+// re-hashing. Optionally, deletions and moves within the source are propagated to the target. Temporary files
+// of interrupted copies are removed first.
+func Sync(sourcePath string, targetPath string, deletions bool, moves bool, options store.Options) {
+	dir.AssertDir(sourcePath)
+	dir.AssertIntegrityDir(sourcePath)
+	dir.AssertDir(targetPath)
+	dir.UpsertIntegrityDir(targetPath)
+	hash.RemoveTemporaryFiles(targetPath)
+	if options.Backup {
+		file.Backup(targetPath)
+	}
+	start := time.Now()
+	summary := ilog.SyncSummary{}
+	logBuffer := ilog.NewManualLogBuffer(targetPath, ilog.Sync, options.Log)
+	fileBuffer := file.NewFileHashsBuffer(targetPath, 1, logBuffer.Flush)
+
+	source := file.LoadContent(sourcePath).DefragmentedMap()
+	target := file.LoadContent(targetPath).DefragmentedMap()
+	result := diff.Analyze(source, target)
+	summary.UnchangedFiles = int64(len(result.Equal))
+
+	copies := append(file.FileHashs{}, result.OnlyA...)
+	for _, pair := range result.Modified {
+		copies = append(copies, pair.A)
+	}
+	removals := append(file.FileHashs{}, result.OnlyB...)
+
+	// Moves are propagated by renaming the target file, otherwise the file is copied
+	for _, pair := range result.Moved {
+		if !moves {
+			copies = append(copies, pair.A)
+			removals = append(removals, pair.B)
+			continue
+		}
+		log := ilog.SyncLog{
+			Created:      time.Now(),
+			Operation:    ilog.MOVE,
+			RelativePath: pair.B.RelativePath,
+			TargetPath:   pair.A.RelativePath,
+		}
+		if err := move(targetPath, pair.B.RelativePath, pair.A.RelativePath); err != nil {
+			log.Operation = ilog.FAIL
+			log.Reason = err
+			summary.FailedFiles++
+		} else {
+			fileBuffer.Append(newEntry(pair.B, pair.A.RelativePath))
+			fileBuffer.Append(deletedEntry(pair.B))
+			summary.MovedFiles++
+		}
+		logBuffer.Append(log)
+	}
+
+	copyFiles(sourcePath, targetPath, copies, options, func(fh file.FileHash, err error) {
+		if err != nil {
+			logBuffer.AppendSyncLog(ilog.FAIL, fh.RelativePath, err)
+			summary.FailedFiles++
+			return
+		}
+		fileBuffer.Append(newEntry(fh, fh.RelativePath))
+		if target.Has(fh.RelativePath) {
+			logBuffer.AppendSyncLog(ilog.REPLACE, fh.RelativePath, nil)
+			summary.ReplacedFiles++
+		} else {
+			logBuffer.AppendSyncLog(ilog.COPY, fh.RelativePath, nil)
+			summary.CopiedFiles++
+		}
+		summary.CopiedBytes += fh.Size
+	})
+
+	if deletions {
+		for _, fh := range removals {
+			if err := remove(targetPath, fh.RelativePath); err != nil {
+				logBuffer.AppendSyncLog(ilog.FAIL, fh.RelativePath, err)
+				summary.FailedFiles++
+				continue
+			}
+			fileBuffer.Append(deletedEntry(fh))
+			logBuffer.AppendSyncLog(ilog.REMOVE, fh.RelativePath, nil)
+			summary.RemovedFiles++
+		}
+	}
+
+	fileBuffer.Flush()
+	file.Defragment(targetPath)
+	summary.ExecutionTime = time.Since(start)
+	logBuffer.Append(summary).Flush()
+}
@@ -0,0 +1,106 @@
+package transfer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/store"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
+
+// Copies the given entries from the source into the target directory with multiple workers.
+// The done callback is executed sequentially for each entry after the copy succeeded or failed.
+func copyFiles(sourcePath string, targetPath string, fhs file.FileHashs, options store.Options, done func(file.FileHash, error)) {
+	progressBar := ilog.ProgressBar(fhs.TotalBytes(), options.ProgressBar, nil)
+	fileHashMap := file.FileHashMap{}
+	for _, fh := range fhs {
+		fileHashMap[fh.RelativePath] = fh
+	}
+
+	// Initialize channels
+	requests := make(chan hash.CopyRequest, 10)
+	responses := make(chan hash.CopyResponse, 100)
+	await := make(chan bool)
+
+	// Consume file copy responses
+	go func() {
+		for i := 0; i < len(fhs); i++ {
+			response := <-responses
+			fh := fileHashMap[response.RelativePath]
+			done(fh, response.Error)
+			progressBar.Add64(fh.Size)
+		}
+		await <- true
+	}()
+
+	// Create file copy workers
+	for w := 1; w <= runtime.NumCPU(); w++ {
+		go hash.CopyWorker(requests, responses)
+	}
+
+	// Produce file copy requests
+	for _, fh := range fhs {
+		requests <- hash.CopyRequest{
+			SourcePath:   path.Absolute(sourcePath, fh.RelativePath),
+			TargetPath:   path.Absolute(targetPath, fh.RelativePath),
+			TmpDir:       hash.TmpDir(targetPath),
+			RelativePath: fh.RelativePath,
+			ModTime:      fh.ModTime,
+			Hash:         fh.Hash,
+			Target:       fh.Target,
+			Dir:          fh.Dir,
+			Algorithm:    options.Algorithm,
+			BufferSize:   options.BufferSize,
+		}
+	}
+	close(requests)
+	<-await
+}
+
+// Renames a file within the base directory, missing parent directories are created
+func move(basePath string, relativePath string, newRelativePath string) error {
+	newPath := path.Absolute(basePath, newRelativePath)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return errors.New("could not create target dir")
+	}
+	if err := os.Rename(path.Absolute(basePath, relativePath), newPath); err != nil {
+		return errors.New("could not move file")
+	}
+	return nil
+}
+
+func remove(basePath string, relativePath string) error {
+	err := os.Remove(path.Absolute(basePath, relativePath))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New("could not remove file")
+	}
+	return nil
+}
+
+func newEntry(fh file.FileHash, relativePath string) file.FileHash {
+	return file.FileHash{
+		Hash:         fh.Hash,
+		Created:      time.Now(),
+		ModTime:      fh.ModTime,
+		Size:         fh.Size,
+		RelativePath: relativePath,
+		Target:       fh.Target,
+		Dir:          fh.Dir,
+	}
+}
+
+func deletedEntry(fh file.FileHash) file.FileHash {
+	return file.FileHash{
+		Hash:         file.EmptyHash,
+		Created:      time.Now(),
+		ModTime:      fh.ModTime,
+		Size:         fh.Size,
+		RelativePath: fh.RelativePath,
+	}
+}
@@ -1,9 +1,82 @@
 package store
 
-import "github.com/aicirt2012/fileintegrity/src/store/ilog"
+import (
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/device"
+	"github.com/aicirt2012/fileintegrity/src/analysis/digest"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/analysis/throttle"
+	"github.com/aicirt2012/fileintegrity/src/analysis/watch"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+)
 
 type Options struct {
-	Log         ilog.Options
-	Backup      bool
-	ProgressBar bool
+	Log                 ilog.Options
+	Backup              bool
+	LockWait            time.Duration // Duration to wait for a lock of another process
+	ProgressBar         bool
+	Redundancy          int
+	ChunkSize           int64
+	AppendOnly          bool // Grown files with chunk hashes are resumed
+	IOStrategy          device.Strategy
+	Symlinks            path.Symlinks
+	Ignore              path.Ignore
+	Algorithm           digest.Algorithm
+	Metadata            bool               // Captures mode bits and ownership
+	Xattrs              []string           // Extended attributes captured with the metadata
+	Throttle            *throttle.Throttle // Limits of the execution, nil does not limit
+	BufferSize          int                // Read buffer size per file reader, zero defaults to hash.DefaultBufferSize
+	Watch               watch.Options
+	MaxPathLength       int   // Style limit of relative paths, zero defaults to 260
+	MaxDirLength        int   // Style limit of directory names, zero defaults to 60
+	DuplicateIgnoreSize int64 // Files up to this size are not checked for duplicates
+}
+
+type upsertTask struct {
+	diskFile path.DiskFile
+	previous file.FileHash
+	exists   bool
+	deleted  bool
+	skipped  bool   // Unchanged hardlink, which only provides the hash of its inode
+	linked   bool   // Hash of an already hashed hardlink of the same inode is reused
+	hash     string // Known hash, which requires no hashing
+	last     bool   // Last visited hardlink of the inode
+	metadata bool   // Only the metadata changed
+	parity   bool   // Unchanged file, whose recovery data is created without changing the entry
+}
+
+// Counts the visited hardlinks per inode. An inode is forgotten after its last hardlink was visited, hence
+// only inodes with hardlinks outside the directory remain in memory.
+type hardlinks map[string]uint64
+
+// Returns whether a further hardlink of the inode was visited before and whether this is the last one
+func (h hardlinks) visit(inode path.Inode) (bool, bool) {
+	id := inode.ID()
+	if id == "" {
+		return false, false
+	}
+	visited := h[id]
+	last := visited+1 >= inode.Links
+	if last {
+		delete(h, id)
+	} else {
+		h[id] = visited + 1
+	}
+	return visited > 0, last
+}
+
+// Hashes of the hardlink groups, which are still awaited
+type linkedHashes map[string]string
+
+func (lh linkedHashes) set(inode string, hash string, last bool) {
+	if inode == "" {
+		return
+	}
+	if last {
+		delete(lh, inode)
+		return
+	}
+	lh[inode] = hash
 }
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aicirt2012/fileintegrity/src/analysis/device"
+	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
+	"github.com/aicirt2012/fileintegrity/src/analysis/path"
+	"github.com/aicirt2012/fileintegrity/src/analysis/watch"
+	"github.com/aicirt2012/fileintegrity/src/store/file"
+	"github.com/aicirt2012/fileintegrity/src/store/ilog"
+	"github.com/aicirt2012/fileintegrity/src/store/lock"
+)
+
+// Number of appended batches, after which the integrity file is defragmented. Keeps the number of sorted runs
+// below the limit of the streaming reader.
+const defragmentBatches = 32
+
+// Watch performs an initial upsert and keeps the integrity file up to date until stop is closed. Changed paths
+// are collected by change notifications and processed after a quiet period, so only changed files are hashed
+// and appended to the integrity file. The integrity file is defragmented periodically. Lost events and
+// platforms without change notifications lead to a full rescan by upsert.
+// Each step holds the exclusive lock of the directory, a held lock postpones the step.
+func Watch(basePath string, options Options, stop <-chan bool) error {
+	release, err := lock.Acquire(basePath, lock.Exclusive, options.LockWait)
+	if err != nil {
+		return err
+	}
+	err = Upsert(basePath, options)
+	release()
+	if err != nil {
+		return err
+	}
+	options.ProgressBar = false
+	watchOptions := options.Watch.WithDefaults()
+	start := time.Now()
+
+	watcher := newWatcher(basePath, options.Symlinks, watchOptions.Interval)
+	defer func() {
+		watcher.Close()
+	}()
+	logBuffer := ilog.NewManualLogBuffer(basePath, ilog.Watch, options.Log)
+	summary := ilog.WatchSummary{}
+	state := watchState{deleted: map[string]bool{}, index: file.NewIndex(basePath)}
+	pending := changes{}
+	rescan := false
+
+	process := func() {
+		if rescan || state.upsert(basePath, pending, options, &logBuffer, &summary) {
+			if err := Upsert(basePath, options); err != nil {
+				log.Println("rescan failed:", err)
+			}
+			state.reset()
+			summary.Rescans++
+		}
+		pending = changes{}
+		rescan = false
+	}
+
+	debounce := time.NewTimer(watchOptions.Debounce)
+	debounce.Stop()
+	defragment := time.NewTicker(watchOptions.Interval)
+	defer defragment.Stop()
+	for {
+		select {
+		case <-stop:
+			// Changes which cannot be processed are detected by the next upsert or watch
+			if !locked(basePath, options, func() {
+				process()
+				state.defragment(basePath)
+			}) && (len(pending) > 0 || rescan) {
+				summary.Unprocessed = int64(len(pending))
+				log.Printf("%d changed paths are not processed, they are detected by the next upsert", len(pending))
+			}
+			summary.ExecutionTime = time.Since(start)
+			logBuffer.Append(summary).Flush()
+			return nil
+		case event, ok := <-watcher.Events():
+			if !ok {
+				log.Printf("change notifications failed, directory is polled every %v", watchOptions.Interval)
+				watcher = watch.Poll(watchOptions.Interval)
+				rescan = true
+			} else if event.Overflow {
+				rescan = true
+			} else {
+				pending.add(event)
+			}
+			if len(pending) < window || !locked(basePath, options, process) {
+				restart(debounce, watchOptions.Debounce)
+			}
+		case <-debounce.C:
+			if !locked(basePath, options, process) {
+				restart(debounce, watchOptions.Debounce)
+			}
+		case <-defragment.C:
+			locked(basePath, options, func() {
+				state.defragment(basePath)
+			})
+		}
+	}
+}
+
+// Executes the step with the exclusive lock, which is awaited up to the lock wait duration. Returns false, if
+// the lock is held by another process and the step is postponed.
+func locked(basePath string, options Options, step func()) bool {
+	release, err := lock.Acquire(basePath, lock.Exclusive, options.LockWait)
+	if err != nil {
+		log.Println("postponed changes:", err)
+		return false
+	}
+	defer release()
+	step()
+	return true
+}
+
+// Change notifications do not cover followed directory links, hence the directory is polled instead
+func newWatcher(basePath string, symlinks path.Symlinks, interval time.Duration) watch.Watcher {
+	if symlinks != path.FOLLOW {
+		watcher, err := watch.New(basePath)
+		if err == nil {
+			return watcher
+		}
+		log.Printf("%v, directory is polled every %v", err, interval)
+	}
+	return watch.Poll(interval)
+}
+
+func restart(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// Changed paths of the watched directory. Trees are removed directories, which include all paths below.
+type changes map[string]bool
+
+func (c changes) add(event watch.Event) {
+	c[event.RelativePath] = c[event.RelativePath] || event.Tree
+	// The parent directory may become empty or is not empty anymore
+	if parent := path.Dir(event.RelativePath); parent != "." {
+		if _, exists := c[parent]; !exists {
+			c[parent] = false
+		}
+	}
+}
+
+type watchState struct {
+	deleted map[string]bool // Deleted entries since the last defragmentation, which hide appended entries
+	batches int             // Appended batches since the last defragmentation
+	index   *file.Index     // Locates the stored entries of changed paths, which is kept between batches
+}
+
+// Upserts the changed paths like Upsert and appends the entries as one sorted run. Returns true, if a rescan
+// is required instead, because changes of hardlinks affect the further hardlinks of the inode as well.
+func (s *watchState) upsert(basePath string, pending changes, options Options, logBuffer *ilog.LogFileBuffer, summary *ilog.WatchSummary) bool {
+	if len(pending) == 0 {
+		return false
+	}
+	defer options.Throttle.Start(basePath)()
+	s.index.Update()
+	previous := s.index.Lookup(pending)
+	relativePaths := make([]string, 0, len(pending)+len(previous))
+	for relativePath := range pending {
+		if _, exists := previous[relativePath]; !exists {
+			relativePaths = append(relativePaths, relativePath)
+		}
+	}
+	for relativePath := range previous {
+		relativePaths = append(relativePaths, relativePath)
+	}
+	sort.Slice(relativePaths, func(i, j int) bool {
+		return path.Compare(relativePaths[i], relativePaths[j]) < 0
+	})
+
+	tasks := []upsertTask{}
+	requests := make(chan hash.CreateRequest, len(relativePaths))
+	for _, relativePath := range relativePaths {
+		fileHash, exists := previous[relativePath]
+		diskFile, found := path.Stat(basePath, relativePath, options.Symlinks, options.Ignore)
+		if !found {
+			if exists {
+				if fileHash.Inode != "" {
+					return true
+				}
+				tasks = append(tasks, upsertTask{previous: fileHash, deleted: true})
+			}
+			continue
+		}
+		diskFile.Metadata = captureMetadata(diskFile, fileHash, options)
+		task := upsertTask{diskFile: diskFile, previous: fileHash, exists: exists}
+		switch {
+		case !exists || !fileHash.ModTime.Equal(diskFile.ModTime) || fileHash.Size != diskFile.Size || fileHash.Target != diskFile.Target || fileHash.Dir != diskFile.Dir:
+			if diskFile.Inode.Linked() || fileHash.Inode != "" {
+				return true
+			}
+			if diskFile.Target != "" {
+				task.hash = hash.Link(diskFile.Target, options.Algorithm)
+			} else if diskFile.Dir {
+				task.hash = hash.DirHash
+			} else {
+				requests <- createRequest(basePath, diskFile, fileHash, options)
+			}
+		case options.Metadata && fileHash.Metadata != diskFile.Metadata:
+			task.hash = fileHash.Hash
+			task.metadata = true
+		case fileHash.Inode != diskFile.Inode.ID():
+			return true
+		default:
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	close(requests)
+
+	// Hash the changed files
+	responses := make(chan hash.CreateResponse, len(requests))
+	hashed := map[string]hash.CreateResponse{}
+	count := len(requests)
+	for w := 1; w <= min(device.Resolve(basePath, options.IOStrategy).Workers(), count); w++ {
+		go hash.CreationWorker(requests, responses)
+	}
+	for i := 0; i < count; i++ {
+		response := <-responses
+		hashed[response.RelativePath] = response
+	}
+
+	// Appended entries of deleted paths are hidden until the deletion is defragmented
+	for _, task := range tasks {
+		if !task.deleted && s.deleted[task.diskFile.RelativePath] {
+			s.defragment(basePath)
+			break
+		}
+	}
+
+	fileBuffer := file.NewFileHashsBuffer(basePath, 1, logBuffer.Flush)
+	for _, task := range tasks {
+		if task.deleted {
+			fileBuffer.Append(deletedEntry(task.previous))
+			logBuffer.AppendUpsertLog(ilog.DELETE, task.previous.RelativePath)
+			s.deleted[task.previous.RelativePath] = true
+			summary.DeletedFiles++
+			continue
+		}
+		fileHash := task.hash
+		if fileHash == "" {
+			response := hashed[task.diskFile.RelativePath]
+			if response.Error != nil {
+				// The file was removed or replaced in the meanwhile, which is reported by a further event
+				log.Printf("%v: %v", task.diskFile.RelativePath, response.Error)
+				continue
+			}
+			fileHash = response.Hash
+			summary.HashedBytes += response.HashedBytes
+		}
+		fileBuffer.Append(newEntry(task.diskFile, fileHash))
+		if task.metadata {
+			logBuffer.AppendUpsertLog(ilog.METADATA, task.diskFile.RelativePath)
+			summary.MetadataFiles++
+		} else if task.exists {
+			logBuffer.AppendUpsertLog(ilog.UPDATE, task.diskFile.RelativePath)
+			summary.UpdatedFiles++
+		} else {
+			logBuffer.AppendUpsertLog(ilog.NEW, task.diskFile.RelativePath)
+			summary.NewFiles++
+		}
+	}
+	fileBuffer.Flush()
+	logBuffer.Flush()
+	summary.Batches++
+	s.batches++
+	if s.batches >= defragmentBatches {
+		s.defragment(basePath)
+	}
+	return false
+}
+
+func (s *watchState) defragment(basePath string) {
+	if s.batches == 0 {
+		return
+	}
+	file.Defragment(basePath)
+	if err := prune(basePath); err != nil {
+		log.Println("could not prune recovery data:", err)
+	}
+	s.reset()
+}
+
+func (s *watchState) reset() {
+	s.deleted = map[string]bool{}
+	s.batches = 0
+}
@@ -2,11 +2,19 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aicirt2012/fileintegrity/src/cli/cmd"
+	"github.com/aicirt2012/fileintegrity/src/hook"
 	"github.com/aicirt2012/fileintegrity/tests/common"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestUpsertFlow(t *testing.T) {
@@ -141,6 +149,244 @@ func TestExtensionStatsFlow(t *testing.T) {
 	})
 }
 
+func TestDiffFlow(t *testing.T) {
+	dir, files := common.CreateScenario("diff", common.Files{
+		common.NewFile(`a\equal.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`b\equal.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	dirA := filepath.Join(dir, `a`)
+	dirB := filepath.Join(dir, `b`)
+
+	common.CreateIntegrityFile(t, dirA, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `13`, `equal.txt`),
+		common.NewFileHash(`f59d71f706fb095ce60a3babaf1e5cd65521154ab6854b31d0eb93e678ecddc6`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `12`, `only a.md`),
+	})
+	common.CreateIntegrityFile(t, dirB, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `13`, `equal.txt`),
+	})
+
+	executeCli([]string{"diff", dirA, dirB, "-q"})
+
+	common.AssertFilesExist(t, dir, files)
+	common.AssertDiffLogFile(t, dirA, []string{
+		`ONLY A  only a.md`,
+	}, 1, 1, 0, 0, 0)
+}
+
+func TestSyncFlow(t *testing.T) {
+	dir, files := common.CreateScenario("sync", common.Files{
+		common.NewFile(`source\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`target\obsolete.md`, `2022-05-06T00:40:21+02:00`, `bb1 sample md`),
+	})
+	sourceDir := filepath.Join(dir, `source`)
+	targetDir := filepath.Join(dir, `target`)
+	executeCli([]string{"upsert", sourceDir, "-q"})
+	executeCli([]string{"upsert", targetDir, "-q"})
+
+	time.Sleep(time.Second)
+	executeCli([]string{"sync", sourceDir, targetDir, "-q", "--delete"})
+
+	common.AssertFilesExist(t, dir, common.Files{
+		files[0],
+		common.NewFile(`target\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	common.AssertIntegrityFile(t, targetDir, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a1.txt`),
+	})
+	common.AssertSyncLogFile(t, targetDir, 0, 1, 0, 0, 1, 0)
+}
+
+func TestCopyFlow(t *testing.T) {
+	dir, files := common.CreateScenario("copy", common.Files{
+		common.NewFile(`source\a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`source\b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+		common.NewFile(`target\x.md`, `2022-05-06T00:40:21+02:00`, `x sample md`),
+	})
+	sourceDir := filepath.Join(dir, `source`)
+	targetDir := filepath.Join(dir, `target`)
+	executeCli([]string{"upsert", sourceDir, "-q"})
+
+	executeCli([]string{"cp", sourceDir, "b", targetDir, "-q"})
+
+	common.AssertFilesExist(t, dir, append(files,
+		common.NewFile(`target\b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+	))
+	common.AssertIntegrityFile(t, targetDir, []common.FileHash{
+		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, ``, `2022-05-06T00:40:21+02:00`, `12`, `b\b1.md`),
+	})
+	common.AssertCopyLogFile(t, targetDir, 1, 1, 0, 0)
+}
+
+func TestRepairFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("repair", common.Files{
+		common.NewFile(`archive\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`replica\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	archiveDir := filepath.Join(dir, `archive`)
+	replicaDir := filepath.Join(dir, `replica`)
+	executeCli([]string{"upsert", archiveDir, "-q"})
+	common.UpdateFile(archiveDir, `a1.txt`, `a1 sample TXT`, `2022-05-06T00:40:21+02:00`)
+
+	time.Sleep(time.Second)
+	executeCli([]string{"repair", archiveDir, "--from", replicaDir, "-q"})
+
+	common.AssertFilesExist(t, archiveDir, common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	common.AssertQuarantinedFile(t, archiveDir, `a1.txt`, `a1 sample TXT`)
+	common.AssertRepairLogFile(t, archiveDir, 0, 1, 1, 0)
+}
+
+func TestMetricsFlow(t *testing.T) {
+	content := strings.Repeat(`a sample txt `, 10)
+	dir, _ := common.CreateScenario("metrics", common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, content),
+		common.NewFile(`a2.txt`, `2022-05-06T00:40:21+02:00`, content),
+	})
+	metricsFile := filepath.Join(t.TempDir(), "fileintegrity.prom")
+	executeCli([]string{"upsert", dir, "-q", "--metrics-file", metricsFile})
+	time.Sleep(time.Second)
+	executeCli([]string{"verify", dir, "-q", "--metrics-file", metricsFile})
+	time.Sleep(time.Second)
+	executeCli([]string{"check", "duplicates", dir, "-q", "--metrics-file", metricsFile})
+
+	metrics, err := os.ReadFile(metricsFile)
+	assert.Nil(t, err)
+	path, _ := filepath.Abs(dir)
+	assert.Contains(t, string(metrics), `fileintegrity_upsert_hashed_files{path="`+path+`"} 2`)
+	assert.Contains(t, string(metrics), `fileintegrity_verify_valid_files{path="`+path+`"} 2`)
+	assert.Contains(t, string(metrics), `fileintegrity_verify_invalid_files{path="`+path+`"} 0`)
+	assert.Contains(t, string(metrics), `fileintegrity_duplicate_bytes{path="`+path+`"} 130`)
+}
+
+func TestHookFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("hook", common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	var payloads []hook.Payload
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload hook.Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+	}))
+	defer webhook.Close()
+	executeCli([]string{"upsert", dir, "-q", "--webhook", "invalid=" + webhook.URL})
+	common.UpdateFile(dir, `a1.txt`, `a1 sample TXT`, `2022-05-06T00:40:21+02:00`)
+
+	time.Sleep(time.Second)
+	executeCli([]string{"verify", dir, "-q", "--webhook", "invalid=" + webhook.URL})
+
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, hook.INVALID, payloads[0].Event)
+	assert.Equal(t, "verify", payloads[0].Kind)
+	assert.Equal(t, float64(1), payloads[0].Summary.(map[string]any)["InvalidFiles"])
+}
+
+func TestHookFlow_newDuplicates(t *testing.T) {
+	content := strings.Repeat(`a sample txt `, 10)
+	dir, _ := common.CreateScenario("hook", common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, content),
+		common.NewFile(`a2.txt`, `2022-05-06T00:40:21+02:00`, content),
+	})
+	var payloads []hook.Payload
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload hook.Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+	}))
+	defer webhook.Close()
+	executeCli([]string{"upsert", dir, "-q"})
+	executeCli([]string{"check", "duplicates", dir, "-q", "--webhook", "duplicates=" + webhook.URL})
+	assert.Len(t, payloads, 1)
+
+	// Known duplicates trigger no hook
+	time.Sleep(time.Second)
+	executeCli([]string{"check", "duplicates", dir, "-q", "--webhook", "duplicates=" + webhook.URL})
+	assert.Len(t, payloads, 1)
+
+	// A further file of a known group is new
+	common.CreateFile(dir, `a3.txt`, content, `2022-05-06T00:40:21+02:00`)
+	time.Sleep(time.Second)
+	executeCli([]string{"upsert", dir, "-q"})
+	executeCli([]string{"check", "duplicates", dir, "-q", "--webhook", "duplicates=" + webhook.URL})
+	assert.Len(t, payloads, 2)
+	assert.Equal(t, hook.DUPLICATES, payloads[1].Event)
+	assert.Equal(t, float64(1), payloads[1].Summary.(map[string]any)["NewDuplicateGroups"])
+}
+
+func TestConfigFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("config", common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a1.tmp`, `2022-05-06T00:40:21+02:00`, `a1 sample tmp`),
+	})
+
+	output, _ := executeCliStdout([]string{"config", dir, "--init"})
+	assert.Contains(t, output, "hash: sha256")
+	content, err := os.ReadFile(filepath.Join(dir, ".integrity", "config"))
+	assert.Nil(t, err)
+	content = []byte(strings.Replace(string(content), "ignore: []", "ignore: ['*.tmp']", 1))
+	content = []byte(strings.Replace(string(content), "strategy: auto", "strategy: parallel", 1))
+	os.WriteFile(filepath.Join(dir, ".integrity", "config"), content, 0644)
+
+	// Flags take precedence over the config
+	executeCli([]string{"upsert", dir, "-q", "--io", "sequential"})
+	common.AssertIntegrityFile(t, dir, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a1.txt`),
+	})
+	common.AssertLogFileContains(t, dir, "sequential")
+	output, errOutput := executeCliStdout([]string{"config", dir})
+	assert.Contains(t, output, "- '*.tmp'")
+	assert.Empty(t, errOutput)
+}
+
+func TestGCFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("gc", common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	executeCli([]string{"config", dir, "--init"})
+	for _, name := range []string{`230101.120000.verify.log`, `230102.120000.verify.log`, `230103.120000.verify.log`} {
+		common.CreateFile(dir, filepath.Join(`.integrity`, name), `old`, `2023-01-03T12:00:00+00:00`)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, ".integrity", "config"))
+	content = []byte(strings.Replace(string(content), "logs:\n        keepLast: 0", "logs:\n        keepLast: 1", 1))
+	os.WriteFile(filepath.Join(dir, ".integrity", "config"), content, 0644)
+
+	executeCli([]string{"gc", dir, "--dry-run", "-q"})
+	assert.FileExists(t, filepath.Join(dir, `.integrity`, `230101.120000.verify.log`))
+
+	executeCli([]string{"gc", dir, "-q"})
+	assert.NoFileExists(t, filepath.Join(dir, `.integrity`, `230101.120000.verify.log`))
+	assert.NoFileExists(t, filepath.Join(dir, `.integrity`, `230102.120000.verify.log`))
+	assert.FileExists(t, filepath.Join(dir, `.integrity`, `230103.120000.verify.log`))
+	common.AssertLogFileContains(t, dir, "REMOVED  230101.120000.verify.log")
+}
+
+func TestBackupFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("backup", common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+	})
+	executeCli([]string{"upsert", dir, "-q"})
+	common.RemoveFile(dir, `b1.md`)
+	executeCli([]string{"upsert", dir, "-q", "--backup"})
+
+	output, errOutput := executeCliStdout([]string{"backup", "list", dir})
+	timestamp := strings.Fields(output)[0]
+	assert.Len(t, strings.Split(strings.TrimSpace(output), "\n"), 1)
+	assert.Empty(t, errOutput)
+
+	time.Sleep(time.Second)
+	executeCli([]string{"backup", "diff", dir, timestamp, "-q"})
+	common.AssertLogFileContains(t, dir, "ONLY A  b1.md")
+
+	output = executeCli([]string{"backup", "restore", dir, timestamp})
+	assert.Contains(t, output, "restored backup "+timestamp)
+	common.AssertIntegrityFile(t, dir, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a1.txt`),
+		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, ``, `2022-05-06T00:40:21+02:00`, `12`, `b1.md`),
+	})
+}
+
 func executeCli(args []string) string {
 	r := new(bytes.Buffer)
 	c := cmd.Root()
@@ -150,3 +396,14 @@ func executeCli(args []string) string {
 	c.Execute()
 	return r.String()
 }
+
+// Executes the cli and returns stdout and stderr separately
+func executeCliStdout(args []string) (string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	c := cmd.Root()
+	c.SetOut(stdout)
+	c.SetErr(stderr)
+	c.SetArgs(args)
+	c.Execute()
+	return stdout.String(), stderr.String()
+}
@@ -49,11 +49,16 @@ func AssertIntegrityFile(t *testing.T, dir string, expected []FileHash) {
 	for i := range expected {
 		expectedLine := expected[i]
 		actualLine := actual[i]
-		assert.Len(t, actualLine, 5)
+		assert.Len(t, actualLine, 5+len(expectedLine.attributes))
 		assert.Equal(t, actualLine[0], expectedLine.hash, expectedLine.relativePath)
-		assert.Equal(t, parseTime(actualLine[2]).UTC(), parseTime(expectedLine.modTime).UTC())
+		if expectedLine.modTime != "" {
+			assert.Equal(t, parseTime(actualLine[2]).UTC(), parseTime(expectedLine.modTime).UTC())
+		}
 		assert.Equal(t, actualLine[3], expectedLine.size)
-		assert.Equal(t, NormalizePath(actualLine[4]), expectedLine.relativePath)
+		assert.Equal(t, actualLine[4], expectedLine.relativePath)
+		if len(actualLine) > 5 {
+			assert.Equal(t, expectedLine.attributes, actualLine[5:])
+		}
 	}
 }
 
@@ -85,7 +90,7 @@ func AssertVerifyLogFile(t *testing.T, dir string, valid int, invalid int) {
 	}
 	lines := strings.Split(content, "\n")
 
-	regex := regexp.MustCompile(`^\d{6}\.\d{6}  (OK|ERROR)  .{1,260}$`)
+	regex := regexp.MustCompile(`^\d{6}\.\d{6}  (OK|ERROR|DRIFT)  .{1,260}$`)
 	for i := 0; i < expectedLogLines; i++ {
 		assert.Regexp(t, regex, lines[i])
 	}
@@ -143,6 +148,138 @@ func AssertExtensionStatsLogFile(t *testing.T, dir string, lines []string) {
 	assert.Equal(t, "//// Extension Stats Summary /////////////", actualLines[currentLine+2])
 }
 
+func AssertDiffLogFile(t *testing.T, dir string, logLines []string, equal int, onlyA int, onlyB int, modified int, moved int) {
+	content, err := lastLogFileContent(dir)
+	if err != nil {
+		log.Fatal("could not read log file", err)
+	}
+	lines := strings.Split(content, "\n")
+	for i, line := range logLines {
+		assert.Equal(t, line, lines[i])
+	}
+
+	currentLine := len(logLines)
+	assert.Equal(t, "//// Diff Summary ////////////////////////", lines[currentLine+2])
+	assertSummaryLine(t, "Equal files:", equal, lines[currentLine+6])
+	assertSummaryLine(t, "Only in A files:", onlyA, lines[currentLine+7])
+	assertSummaryLine(t, "Only in B files:", onlyB, lines[currentLine+8])
+	assertSummaryLine(t, "Modified files:", modified, lines[currentLine+9])
+	assertSummaryLine(t, "Moved files:", moved, lines[currentLine+10])
+}
+
+func AssertSyncLogFile(t *testing.T, dir string, unchanged int, copied int, replaced int, moved int, removed int, failed int) {
+	expectedLogLines := copied + replaced + moved + removed + failed
+	content, err := lastLogFileContent(dir)
+	if err != nil {
+		log.Fatal("could not read log file", err)
+	}
+	lines := strings.Split(content, "\n")
+
+	regex := regexp.MustCompile(`^\d{6}\.\d{6}  (COPY|REPLACE|MOVE|REMOVE|FAIL)  .{1,260}$`)
+	for i := 0; i < expectedLogLines; i++ {
+		assert.Regexp(t, regex, lines[i])
+	}
+
+	assert.Equal(t, "//// Sync Summary ////////////////////////", lines[expectedLogLines+2])
+	assertSummaryLine(t, "Unchanged files:", unchanged, lines[expectedLogLines+6])
+	assertSummaryLine(t, "Copied files:", copied, lines[expectedLogLines+7])
+	assertSummaryLine(t, "Replaced files:", replaced, lines[expectedLogLines+8])
+	assertSummaryLine(t, "Moved files:", moved, lines[expectedLogLines+9])
+	assertSummaryLine(t, "Removed files:", removed, lines[expectedLogLines+10])
+	assertSummaryLine(t, "Failed files:", failed, lines[expectedLogLines+11])
+}
+
+func AssertCopyLogFile(t *testing.T, dir string, matched int, copied int, replaced int, failed int) {
+	expectedLogLines := copied + replaced + failed
+	content, err := lastLogFileContent(dir)
+	if err != nil {
+		log.Fatal("could not read log file", err)
+	}
+	lines := strings.Split(content, "\n")
+
+	regex := regexp.MustCompile(`^\d{6}\.\d{6}  (COPY|REPLACE|FAIL)  .{1,260}$`)
+	for i := 0; i < expectedLogLines; i++ {
+		assert.Regexp(t, regex, lines[i])
+	}
+
+	assert.Equal(t, "//// Copy Summary ////////////////////////", lines[expectedLogLines+2])
+	assertSummaryLine(t, "Matched files:", matched, lines[expectedLogLines+6])
+	assertSummaryLine(t, "Copied files:", copied, lines[expectedLogLines+7])
+	assertSummaryLine(t, "Replaced files:", replaced, lines[expectedLogLines+8])
+	assertSummaryLine(t, "Failed files:", failed, lines[expectedLogLines+9])
+}
+
+func AssertRepairLogFile(t *testing.T, dir string, valid int, invalid int, repaired int, unrepairable int) {
+	content, err := lastLogFileContent(dir)
+	if err != nil {
+		log.Fatal("could not read log file", err)
+	}
+	lines := strings.Split(content, "\n")
+
+	regex := regexp.MustCompile(`^\d{6}\.\d{6}  (REPAIRED|UNREPAIRABLE)  .{1,260}$`)
+	for i := 0; i < invalid; i++ {
+		assert.Regexp(t, regex, lines[i])
+	}
+
+	assert.Equal(t, "//// Repair Summary //////////////////////", lines[invalid+2])
+	assertSummaryLine(t, "Verified valid files:", valid, lines[invalid+5])
+	assertSummaryLine(t, "Verified invalid files:", invalid, lines[invalid+6])
+	assertSummaryLine(t, "Repaired files:", repaired, lines[invalid+7])
+	assertSummaryLine(t, "Unrepairable files:", unrepairable, lines[invalid+8])
+}
+
+func AssertQuarantinedFile(t *testing.T, dir string, relativePath string, content string) {
+	pattern := filepath.Join(dir, integrity, "quarantine", "*", NormalizePath(relativePath))
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		log.Fatal("could not glob quarantine", err)
+	}
+	assert.Len(t, matches, 1, relativePath)
+	for _, match := range matches {
+		actual, err := os.ReadFile(match)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(actual))
+	}
+}
+
+func AssertParityFileCount(t *testing.T, dir string, expected int) {
+	matches, err := filepath.Glob(filepath.Join(dir, integrity, "parity", "*.par"))
+	if err != nil {
+		log.Fatal("could not glob parity files", err)
+	}
+	assert.Len(t, matches, expected)
+}
+
+func AssertChunkFileCount(t *testing.T, dir string, expected int) {
+	matches, err := filepath.Glob(filepath.Join(dir, integrity, "chunks", "*.json"))
+	if err != nil {
+		log.Fatal("could not glob chunk files", err)
+	}
+	assert.Len(t, matches, expected)
+}
+
+func AssertLogFileContains(t *testing.T, dir string, expected string) {
+	content, err := lastLogFileContent(dir)
+	if err != nil {
+		log.Fatal("could not read log file", err)
+	}
+	assert.Contains(t, content, expected)
+}
+
+func AssertLogFileSummaryLine(t *testing.T, dir string, expectedLabel string, expectedValue int) {
+	content, err := lastLogFileContent(dir)
+	if err != nil {
+		log.Fatal("could not read log file", err)
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, expectedLabel) {
+			assertSummaryLine(t, expectedLabel, expectedValue, line)
+			return
+		}
+	}
+	assert.Fail(t, "summary line missing", expectedLabel)
+}
+
 func AssertLogBlocks(t *testing.T, lines []string, blocks []LogBlock) (int, int, int) {
 	currentLine := 0
 	duplicates := 0
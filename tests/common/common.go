@@ -11,6 +11,11 @@ func NormalizePath(path string) string {
 	return strings.ReplaceAll(path, `/`, string(filepath.Separator))
 }
 
+// Converts static test data into the canonical notation of the integrity file and the logs
+func CanonicalPath(path string) string {
+	return strings.ReplaceAll(path, `\`, `/`)
+}
+
 func StaticContent(sizeInkB int) string {
 	n := sizeInkB * 1024
 	var sb strings.Builder
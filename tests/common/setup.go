@@ -91,6 +91,30 @@ func RemoveFile(dir string, filename string) {
 	}
 }
 
+func CreateDir(dir string, dirname string) {
+	err := os.MkdirAll(filepath.Join(dir, NormalizePath(dirname)), 0755)
+	if err != nil {
+		log.Fatal("could not create dir", err)
+	}
+}
+
+func CreateFile(dir string, filename string, content string, modTime string) {
+	filename = filepath.Join(dir, NormalizePath(filename))
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		log.Fatal("could not create file dir", err)
+	}
+	err = os.WriteFile(filename, []byte(content), 0644)
+	if err != nil {
+		log.Fatal("could not create file", err)
+	}
+	mt := parseTime(modTime)
+	err = os.Chtimes(filename, mt, mt)
+	if err != nil {
+		log.Fatal("could not adapt time", err)
+	}
+}
+
 func UpdateFile(dir string, filename string, content string, modTime string) {
 	filename = filepath.Join(dir, NormalizePath(filename))
 	err := os.WriteFile(filename, []byte(content), os.ModeAppend)
@@ -104,6 +128,35 @@ func UpdateFile(dir string, filename string, content string, modTime string) {
 	}
 }
 
+func LinkFile(dir string, filename string, link string) {
+	link = filepath.Join(dir, NormalizePath(link))
+	err := os.MkdirAll(filepath.Dir(link), 0755)
+	if err != nil {
+		log.Fatal("could not create link dir", err)
+	}
+	err = os.Link(filepath.Join(dir, NormalizePath(filename)), link)
+	if err != nil {
+		log.Fatal("could not create hardlink", err)
+	}
+}
+
+func ChmodFile(dir string, filename string, mode os.FileMode) {
+	err := os.Chmod(filepath.Join(dir, NormalizePath(filename)), mode)
+	if err != nil {
+		log.Fatal("could not change file mode", err)
+	}
+}
+
+// Creates or replaces a symbolic link with a target relative to the link
+func SymlinkFile(dir string, target string, link string) {
+	link = filepath.Join(dir, NormalizePath(link))
+	os.Remove(link)
+	err := os.Symlink(NormalizePath(target), link)
+	if err != nil {
+		log.Fatal("could not create symlink", err)
+	}
+}
+
 func lastLogFileContent(dir string) (content string, err error) {
 	absoluteDir := filepath.Join(dir, integrity)
 	files, err := os.ReadDir(absoluteDir)
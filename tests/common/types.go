@@ -3,6 +3,7 @@ package common
 import (
 	"time"
 
+	"github.com/aicirt2012/fileintegrity/src/analysis/hash"
 	"github.com/aicirt2012/fileintegrity/src/store/ilog"
 )
 
@@ -38,6 +39,7 @@ type FileHash struct {
 	modTime      string
 	size         string
 	relativePath string
+	attributes   []string
 }
 
 func NewFileHash(hash string, created string, modTime string, size string, relativePath string) FileHash {
@@ -46,7 +48,17 @@ func NewFileHash(hash string, created string, modTime string, size string, relat
 		created:      created,
 		modTime:      modTime,
 		size:         size,
-		relativePath: NormalizePath(relativePath),
+		relativePath: CanonicalPath(relativePath),
+	}
+}
+
+// NewDirHash creates the entry of an empty directory, whose modification time is not asserted
+func NewDirHash(relativePath string) FileHash {
+	return FileHash{
+		hash:         hash.DirHash,
+		size:         `0`,
+		relativePath: CanonicalPath(relativePath),
+		attributes:   []string{`type=dir`},
 	}
 }
 
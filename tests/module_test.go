@@ -1,12 +1,15 @@
 package tests
 
 import (
+	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
 	"github.com/aicirt2012/fileintegrity"
 	"github.com/aicirt2012/fileintegrity/tests/common"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestUpsertFlow(t *testing.T) {
@@ -40,8 +43,9 @@ func TestUpsertFlow(t *testing.T) {
 		common.NewFileHash(`2592c50e3d57402c5b5f2293bb2a52dfb38bfc91ae1c9a1f2452b798d53bf7c6`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a\a2.txt`),
 		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, ``, `2022-05-06T00:40:21+02:00`, `12`, `b\b1.md`),
 		common.NewFileHash(`f59d71f706fb095ce60a3babaf1e5cd65521154ab6854b31d0eb93e678ecddc6`, ``, `2022-05-06T00:40:21+02:00`, `12`, `b\b2.md`),
+		common.NewDirHash(`b\bb`),
 	})
-	common.AssertUpsertLogFile(t, dir, 4, 0, 0, 1)
+	common.AssertUpsertLogFile(t, dir, 4, 1, 0, 1)
 
 	time.Sleep(time.Second)
 	common.UpdateFile(dir, `b\b2.md`, `changed content`, `2023-05-06T13:00:21+02:00`)
@@ -52,8 +56,73 @@ func TestUpsertFlow(t *testing.T) {
 		common.NewFileHash(`2592c50e3d57402c5b5f2293bb2a52dfb38bfc91ae1c9a1f2452b798d53bf7c6`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a\a2.txt`),
 		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, ``, `2022-05-06T00:40:21+02:00`, `12`, `b\b1.md`),
 		common.NewFileHash(`b92d13bbe02db7ca7686a8e7b854de49c7455948c05cf91a47044278395e212e`, ``, `2023-05-06T13:00:21+02:00`, `15`, `b\b2.md`),
+		common.NewDirHash(`b\bb`),
 	})
-	common.AssertUpsertLogFile(t, dir, 3, 0, 1, 0)
+	common.AssertUpsertLogFile(t, dir, 4, 0, 1, 0)
+}
+
+func TestUpsertFlow_appendedChunks(t *testing.T) {
+	dir, _ := common.CreateScenario("upsert.appendedChunks", common.Files{
+		common.NewFile(`a\a1.bin`, `2022-05-06T00:40:21+02:00`, common.StaticContent(10)),
+	})
+	options := fileintegrity.EnabledOptions()
+	options.ChunkSize = 1024
+	fileintegrity.Upsert(dir, options)
+
+	// Grown files are hashed completely by default, since the content before the tail may have changed as well
+	time.Sleep(time.Second)
+	edited := "B" + common.StaticContent(10)[1:] + `appended tail`
+	common.UpdateFile(dir, `a\a1.bin`, edited, `2022-05-07T00:40:21+02:00`)
+	fileintegrity.Upsert(dir, options)
+	common.AssertUpsertLogFile(t, dir, 0, 0, 1, 0)
+	common.AssertChunkFileCount(t, dir, 1)
+
+	time.Sleep(time.Second)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertVerifyLogFile(t, dir, 1, 0)
+
+	time.Sleep(time.Second)
+	options.AppendOnly = true
+	common.UpdateFile(dir, `a\a1.bin`, edited+`second tail`, `2022-05-08T00:40:21+02:00`)
+	fileintegrity.Upsert(dir, options)
+	common.AssertUpsertLogFile(t, dir, 0, 0, 1, 0)
+
+	time.Sleep(time.Second)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertVerifyLogFile(t, dir, 1, 0)
+
+	// Append-only upserts do not detect changes before the tail
+	time.Sleep(time.Second)
+	common.UpdateFile(dir, `a\a1.bin`, common.StaticContent(10)+`appended tail`+`second tail`+`third tail`, `2022-05-09T00:40:21+02:00`)
+	fileintegrity.Upsert(dir, options)
+
+	time.Sleep(time.Second)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertVerifyLogFile(t, dir, 0, 1)
+}
+
+func TestUpsertFlow_hardlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inodes are not supported")
+	}
+	dir, _ := common.CreateScenario("upsert.hardlinks", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, common.StaticContent(1)),
+	})
+	common.LinkFile(dir, `a\a1.txt`, `b\b1.txt`)
+
+	fileintegrity.Upsert(dir, fileintegrity.EnabledOptions())
+	common.AssertUpsertLogFile(t, dir, 0, 2, 0, 0)
+	common.AssertLogFileSummaryLine(t, dir, "Hardlinked files:", 1)
+
+	time.Sleep(time.Second)
+	fileintegrity.Upsert(dir, fileintegrity.EnabledOptions())
+	common.AssertUpsertLogFile(t, dir, 2, 0, 0, 0)
+
+	time.Sleep(time.Second)
+	fileintegrity.CheckDuplicates(dir, fileintegrity.EnabledOptions())
+	common.AssertLogFileContains(t, dir, common.CanonicalPath(`b\b1.txt`)+" (hardlink)")
+	common.AssertLogFileSummaryLine(t, dir, "Duplicate files:", 0)
+	common.AssertLogFileSummaryLine(t, dir, "Hardlinked files:", 1)
 }
 
 func TestUpsertFlow_disabledLog(t *testing.T) {
@@ -70,6 +139,67 @@ func TestUpsertFlow_disabledLog(t *testing.T) {
 	common.AssertLogFileNotExists(t, dir)
 }
 
+func TestWatchFlow(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("change notifications are only supported on linux")
+	}
+	dir, _ := common.CreateScenario("watch", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+	})
+	options := fileintegrity.EnabledOptions()
+	options.Debounce = 100 * time.Millisecond
+	stop := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		fileintegrity.Watch(dir, stop, options)
+		close(done)
+	}()
+
+	time.Sleep(time.Second)
+	common.UpdateFile(dir, `a\a1.txt`, `a1 sample txt updated`, `2023-05-06T13:00:21+02:00`)
+	common.CreateFile(dir, `b\b1.md`, `b1 sample md`, `2022-05-06T00:40:21+02:00`)
+	common.RemoveFile(dir, `a\a2.txt`)
+	time.Sleep(time.Second)
+	close(stop)
+	<-done
+
+	common.AssertIntegrityFile(t, dir, []common.FileHash{
+		common.NewFileHash(`eb220d279475c190336ea30b5934e752bc997658fc79217367781e022231cd92`, ``, `2023-05-06T13:00:21+02:00`, `21`, `a\a1.txt`),
+		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, ``, `2022-05-06T00:40:21+02:00`, `12`, `b\b1.md`),
+	})
+	common.AssertLogFileSummaryLine(t, dir, "Rescans:", 0)
+	common.AssertLogFileSummaryLine(t, dir, "New files:", 1)
+	common.AssertLogFileSummaryLine(t, dir, "Updated files:", 1)
+	common.AssertLogFileSummaryLine(t, dir, "Deleted files:", 1)
+}
+
+func TestWatchFlow_polling(t *testing.T) {
+	dir, _ := common.CreateScenario("watch.polling", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	options := fileintegrity.EnabledOptions()
+	options.Symlinks = "follow"
+	options.Interval = 200 * time.Millisecond
+	stop := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		fileintegrity.Watch(dir, stop, options)
+		close(done)
+	}()
+
+	time.Sleep(time.Second)
+	common.CreateFile(dir, `b\b1.md`, `b1 sample md`, `2022-05-06T00:40:21+02:00`)
+	time.Sleep(time.Second)
+	close(stop)
+	<-done
+
+	common.AssertIntegrityFile(t, dir, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a\a1.txt`),
+		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, ``, `2022-05-06T00:40:21+02:00`, `12`, `b\b1.md`),
+	})
+}
+
 func TestVerifyFlow_happyCase(t *testing.T) {
 	dir, files := common.CreateScenario("verify.happyCase", common.Files{
 		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
@@ -106,6 +236,18 @@ func TestVerifyFlow_fileNotExists(t *testing.T) {
 	common.AssertVerifyLogFile(t, dir, 0, 1)
 }
 
+func TestVerifyFlow_missingDirectory(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	assert.ErrorContains(t, fileintegrity.Upsert(missing, fileintegrity.DisabledOptions()), "directory does not exist")
+	assert.ErrorContains(t, fileintegrity.Verify(missing, fileintegrity.DisabledOptions()), "directory does not exist")
+
+	// Executions besides upsert require the integrity directory
+	dir := t.TempDir()
+	assert.ErrorContains(t, fileintegrity.Verify(dir, fileintegrity.DisabledOptions()), "upsert is required first")
+	assert.ErrorContains(t, fileintegrity.CheckDuplicates(dir, fileintegrity.DisabledOptions()), "upsert is required first")
+	assert.ErrorContains(t, fileintegrity.Sync(dir, missing, false, false, fileintegrity.DisabledOptions()), "upsert is required first")
+}
+
 func TestVerifyFlow_fileSizeDiffers(t *testing.T) {
 	dir, files := common.CreateScenario("verify.fileSizeDiffers", common.Files{
 		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt+`),
@@ -136,6 +278,185 @@ func TestVerifyFlow_invalidHash(t *testing.T) {
 	common.AssertVerifyLogFile(t, dir, 0, 1)
 }
 
+func TestVerifyFlow_corruptChunks(t *testing.T) {
+	content := common.StaticContent(10)
+	dir, _ := common.CreateScenario("verify.corruptChunks", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a\a2.bin`, `2022-05-06T00:40:21+02:00`, content),
+	})
+	options := fileintegrity.DisabledOptions()
+	options.ChunkSize = 1024
+	fileintegrity.Upsert(dir, options)
+	common.AssertChunkFileCount(t, dir, 1)
+
+	corrupt := content[:2500] + "B" + content[2501:7000] + "B" + content[7001:]
+	common.UpdateFile(dir, `a\a2.bin`, corrupt, `2022-05-06T00:40:21+02:00`)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+
+	common.AssertVerifyLogFile(t, dir, 1, 1)
+	common.AssertLogFileContains(t, dir, "file hash different at bytes 2048-3071, 6144-7167")
+}
+
+func TestVerifyFlow_sequentialIO(t *testing.T) {
+	dir, files := common.CreateScenario("verify.sequentialIO", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+		common.NewFile(`b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+	})
+	options := fileintegrity.EnabledOptions()
+	options.IOStrategy = "sequential"
+	fileintegrity.Upsert(dir, options)
+	common.AssertUpsertLogFile(t, dir, 0, 3, 0, 0)
+	common.AssertLogFileContains(t, dir, "I/O strategy:                   sequential\n")
+
+	time.Sleep(time.Second)
+	fileintegrity.Verify(dir, options)
+	common.AssertFilesExist(t, dir, files)
+	common.AssertVerifyLogFile(t, dir, 3, 0)
+	common.AssertLogFileContains(t, dir, "I/O strategy:                   sequential\n")
+
+	// An unknown strategy fails the execution
+	options.IOStrategy = "fast"
+	assert.ErrorContains(t, options.Validate(), "unknown io strategy: fast")
+	assert.ErrorContains(t, fileintegrity.Verify(dir, options), "unknown io strategy: fast")
+}
+
+func TestVerifyFlow_throttled(t *testing.T) {
+	dir, files := common.CreateScenario("verify.throttled", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, common.StaticContent(10)),
+	})
+	fileintegrity.Upsert(dir, fileintegrity.DisabledOptions())
+
+	otherDir, _ := common.CreateScenario("verify.unthrottled", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, common.StaticContent(10)),
+	})
+	fileintegrity.Upsert(otherDir, fileintegrity.DisabledOptions())
+
+	options := fileintegrity.EnabledOptions()
+	options.ReadRate = 20 * 1024
+	options.Workers = 1
+	start := time.Now()
+	done := make(chan time.Duration)
+	go func() {
+		// The limits apply only to the throttled execution
+		time.Sleep(100 * time.Millisecond)
+		otherStart := time.Now()
+		fileintegrity.Verify(otherDir, fileintegrity.DisabledOptions())
+		done <- time.Since(otherStart)
+	}()
+	fileintegrity.Verify(dir, options)
+
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	assert.Less(t, <-done, 300*time.Millisecond)
+	common.AssertFilesExist(t, dir, files)
+	common.AssertVerifyLogFile(t, dir, 1, 0)
+}
+
+func TestVerifyFlow_symlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges")
+	}
+	dir, _ := common.CreateScenario("verify.symlinks", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+	})
+	common.SymlinkFile(dir, `a1.txt`, `a\link.txt`)
+	fileintegrity.Upsert(dir, fileintegrity.EnabledOptions())
+	common.AssertUpsertLogFile(t, dir, 0, 3, 0, 0)
+
+	time.Sleep(time.Second)
+	common.SymlinkFile(dir, `a2.txt`, `a\link.txt`)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertVerifyLogFile(t, dir, 2, 1)
+	common.AssertLogFileContains(t, dir, "link target different: a2.txt")
+
+	time.Sleep(time.Second)
+	common.SymlinkFile(dir, `missing.txt`, `a\link.txt`)
+	fileintegrity.Repair(dir, []string{}, fileintegrity.EnabledOptions())
+	common.AssertRepairLogFile(t, dir, 2, 1, 1, 0)
+	common.AssertLogFileSummaryLine(t, dir, "Quarantined files:", 1)
+
+	time.Sleep(time.Second)
+	common.RemoveFile(dir, `a\a1.txt`)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertLogFileContains(t, dir, "link target does not exist")
+
+	// An unknown policy fails the execution
+	options := fileintegrity.EnabledOptions()
+	options.Symlinks = "skip"
+	assert.ErrorContains(t, options.Validate(), "unknown symlink policy: skip")
+	assert.ErrorContains(t, fileintegrity.Upsert(dir, options), "unknown symlink policy: skip")
+}
+
+func TestVerifyFlow_metadataDrift(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mode bits are not supported")
+	}
+	dir, _ := common.CreateScenario("verify.metadataDrift", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+	})
+	common.ChmodFile(dir, `a\a1.txt`, 0644)
+	options := fileintegrity.EnabledOptions()
+	options.Metadata = true
+	fileintegrity.Upsert(dir, options)
+	common.AssertUpsertLogFile(t, dir, 0, 2, 0, 0)
+
+	time.Sleep(time.Second)
+	common.ChmodFile(dir, `a\a1.txt`, 0600)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertVerifyLogFile(t, dir, 2, 0)
+	common.AssertLogFileContains(t, dir, "DRIFT  "+common.CanonicalPath(`a\a1.txt`)+"  metadata different: mode 0644 -> 0600")
+	common.AssertLogFileSummaryLine(t, dir, "Metadata drift files:", 1)
+
+	time.Sleep(time.Second)
+	fileintegrity.Upsert(dir, options)
+	common.AssertLogFileContains(t, dir, "METADATA  "+common.CanonicalPath(`a\a1.txt`))
+	common.AssertLogFileSummaryLine(t, dir, "Skipped files:", 1)
+	common.AssertLogFileSummaryLine(t, dir, "Updated files:", 0)
+	common.AssertLogFileSummaryLine(t, dir, "Metadata changed files:", 1)
+
+	time.Sleep(time.Second)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertLogFileSummaryLine(t, dir, "Metadata drift files:", 0)
+
+	// Upserts without metadata keep the recorded metadata of updated files
+	time.Sleep(time.Second)
+	common.UpdateFile(dir, `a\a2.txt`, `a2 updated txt`, `2022-05-07T00:40:21+02:00`)
+	fileintegrity.Upsert(dir, fileintegrity.EnabledOptions())
+	common.AssertUpsertLogFile(t, dir, 1, 0, 1, 0)
+
+	time.Sleep(time.Second)
+	common.ChmodFile(dir, `a\a2.txt`, 0600)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertLogFileContains(t, dir, "DRIFT  "+common.CanonicalPath(`a\a2.txt`)+"  metadata different: mode")
+	common.AssertLogFileSummaryLine(t, dir, "Metadata drift files:", 1)
+}
+
+func TestVerifyFlow_emptyDirs(t *testing.T) {
+	dir, _ := common.CreateScenario("verify.emptyDirs", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	common.CreateDir(dir, `b\empty`)
+	fileintegrity.Upsert(dir, fileintegrity.EnabledOptions())
+	common.AssertUpsertLogFile(t, dir, 0, 2, 0, 0)
+	common.AssertIntegrityFile(t, dir, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a\a1.txt`),
+		common.NewDirHash(`b\empty`),
+	})
+
+	time.Sleep(time.Second)
+	common.RemoveFile(dir, `b\empty`)
+	fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	common.AssertVerifyLogFile(t, dir, 1, 1)
+	common.AssertLogFileContains(t, dir, "directory does not exist")
+
+	time.Sleep(time.Second)
+	fileintegrity.Repair(dir, []string{}, fileintegrity.EnabledOptions())
+	common.AssertRepairLogFile(t, dir, 1, 1, 1, 0)
+	assert.DirExists(t, filepath.Join(dir, common.NormalizePath(`b\empty`)))
+}
+
 func TestVerifyFlow_disabledLog(t *testing.T) {
 	dir, files := common.CreateScenario("verify.fileNotExistsNoLogs", common.Files{})
 
@@ -237,6 +558,391 @@ func TestExtensionStatsFlow(t *testing.T) {
 	})
 }
 
+func TestDiffFlow(t *testing.T) {
+	dir, files := common.CreateScenario("diff", common.Files{
+		common.NewFile(`a\equal.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`b\equal.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	dirA := filepath.Join(dir, `a`)
+	dirB := filepath.Join(dir, `b`)
+
+	common.CreateIntegrityFile(t, dirA, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `13`, `equal.txt`),
+		common.NewFileHash(`2592c50e3d57402c5b5f2293bb2a52dfb38bfc91ae1c9a1f2452b798d53bf7c6`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `13`, `modified.txt`),
+		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `12`, `x\moved.md`),
+		common.NewFileHash(`f59d71f706fb095ce60a3babaf1e5cd65521154ab6854b31d0eb93e678ecddc6`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `12`, `only a.md`),
+	})
+	common.CreateIntegrityFile(t, dirB, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `13`, `equal.txt`),
+		common.NewFileHash(`b92d13bbe02db7ca7686a8e7b854de49c7455948c05cf91a47044278395e212e`, `2023-05-06T15:12:00.8247784+02:00`, `2023-05-06T13:00:21+02:00`, `15`, `modified.txt`),
+		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `12`, `y\moved.md`),
+		common.NewFileHash(`ff6464b4321e5d9b09ae7cb7ba219cee688099f232ef5b978be5f7c94083cc4b`, `2023-05-06T15:12:00.8247784+02:00`, `2022-05-06T00:40:21+02:00`, `13`, `only b.md`),
+	})
+
+	fileintegrity.Diff(dirA, dirB, fileintegrity.EnabledOptions())
+
+	common.AssertFilesExist(t, dir, files)
+	common.AssertDiffLogFile(t, dirA, []string{
+		`MODIFIED  modified.txt`,
+		`MOVED  ` + common.CanonicalPath(`x\moved.md`) + ` -> ` + common.CanonicalPath(`y\moved.md`),
+		`ONLY A  only a.md`,
+		`ONLY B  only b.md`,
+	}, 1, 1, 1, 1, 1)
+}
+
+func TestSyncFlow(t *testing.T) {
+	dir, files := common.CreateScenario("sync", common.Files{
+		common.NewFile(`source\keep.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`source\new.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+		common.NewFile(`source\changed.md`, `2023-05-06T13:00:21+02:00`, `changed content`),
+		common.NewFile(`source\y\moved.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+		common.NewFile(`target\keep.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`target\changed.md`, `2022-05-06T00:40:21+02:00`, `b2 sample md`),
+		common.NewFile(`target\x\moved.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+		common.NewFile(`target\obsolete.md`, `2022-05-06T00:40:21+02:00`, `bb1 sample md`),
+	})
+	sourceDir := filepath.Join(dir, `source`)
+	targetDir := filepath.Join(dir, `target`)
+	fileintegrity.Upsert(sourceDir, fileintegrity.DisabledOptions())
+	fileintegrity.Upsert(targetDir, fileintegrity.DisabledOptions())
+	// Temporary file of an interrupted sync and a file of the user with the same extension
+	stale := filepath.Join(targetDir, `.integrity`, `tmp`, `new.txt.fitmp`)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(stale), 0755))
+	assert.Nil(t, os.WriteFile(stale, []byte(`a2`), 0644))
+	own := filepath.Join(targetDir, `own.fitmp`)
+	assert.Nil(t, os.WriteFile(own, []byte(`own`), 0644))
+
+	fileintegrity.Sync(sourceDir, targetDir, true, true, fileintegrity.EnabledOptions())
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, own)
+	assert.Nil(t, os.Remove(own))
+
+	common.AssertFilesExist(t, dir, append(files[:4],
+		common.NewFile(`target\keep.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`target\new.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+		common.NewFile(`target\changed.md`, `2023-05-06T13:00:21+02:00`, `changed content`),
+		common.NewFile(`target\y\moved.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+	))
+	common.AssertIntegrityFile(t, targetDir, []common.FileHash{
+		common.NewFileHash(`b92d13bbe02db7ca7686a8e7b854de49c7455948c05cf91a47044278395e212e`, ``, `2023-05-06T13:00:21+02:00`, `15`, `changed.md`),
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `keep.txt`),
+		common.NewFileHash(`2592c50e3d57402c5b5f2293bb2a52dfb38bfc91ae1c9a1f2452b798d53bf7c6`, ``, `2022-05-06T00:40:21+02:00`, `13`, `new.txt`),
+		common.NewFileHash(`d64783f26f53c1e668cc75b30f29a89b42e0d19ddddb93bffa1fce509a139922`, ``, `2022-05-06T00:40:21+02:00`, `12`, `y\moved.md`),
+	})
+	common.AssertSyncLogFile(t, targetDir, 1, 1, 1, 1, 1, 0)
+}
+
+func TestSyncFlow_corruptSource(t *testing.T) {
+	dir, files := common.CreateScenario("sync.corruptSource", common.Files{
+		common.NewFile(`source\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`target\other.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+	})
+	sourceDir := filepath.Join(dir, `source`)
+	targetDir := filepath.Join(dir, `target`)
+	fileintegrity.Upsert(sourceDir, fileintegrity.DisabledOptions())
+	common.UpdateFile(dir, `source\a1.txt`, `a1 sample TXT`, `2022-05-06T00:40:21+02:00`)
+
+	fileintegrity.Sync(sourceDir, targetDir, false, false, fileintegrity.EnabledOptions())
+
+	common.AssertFilesExist(t, dir, common.Files{
+		common.NewFile(`source\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample TXT`),
+		files[1],
+	})
+	common.AssertIntegrityFile(t, targetDir, []common.FileHash{})
+	common.AssertSyncLogFile(t, targetDir, 0, 0, 0, 0, 0, 1)
+}
+
+func TestSyncFlow_otherAlgorithm(t *testing.T) {
+	dir, _ := common.CreateScenario("sync.otherAlgorithm", common.Files{
+		common.NewFile(`source\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`target\other.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+	})
+	sourceDir := filepath.Join(dir, `source`)
+	targetDir := filepath.Join(dir, `target`)
+	assert.Nil(t, fileintegrity.Upsert(sourceDir, fileintegrity.DisabledOptions()))
+	config, err := fileintegrity.LoadConfig(targetDir)
+	assert.Nil(t, err)
+	config.Hash = "sha512"
+	assert.Nil(t, os.MkdirAll(filepath.Join(targetDir, `.integrity`), 0755))
+	assert.Nil(t, config.Save(targetDir))
+	assert.Nil(t, fileintegrity.Upsert(targetDir, fileintegrity.DisabledOptions()))
+
+	// Entries of the source are not appended to a target of another algorithm
+	err = fileintegrity.Sync(sourceDir, targetDir, false, false, fileintegrity.EnabledOptions())
+	assert.ErrorContains(t, err, "target is configured with another hash algorithm than sha256")
+	err = fileintegrity.Copy(sourceDir, `a1.txt`, targetDir, fileintegrity.EnabledOptions())
+	assert.ErrorContains(t, err, "target is configured with another hash algorithm than sha256")
+	assert.NoFileExists(t, filepath.Join(targetDir, `a1.txt`))
+
+	// Entries of another algorithm are detected without config as well
+	assert.Nil(t, os.Remove(filepath.Join(targetDir, `.integrity`, `config`)))
+	err = fileintegrity.Sync(sourceDir, targetDir, false, false, fileintegrity.EnabledOptions())
+	assert.ErrorContains(t, err, "target integrity file was created with another hash algorithm than sha256")
+	assert.NoFileExists(t, filepath.Join(targetDir, `a1.txt`))
+}
+
+func TestCopyFlow(t *testing.T) {
+	dir, files := common.CreateScenario("copy", common.Files{
+		common.NewFile(`source\a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`source\a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+		common.NewFile(`source\b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+		common.NewFile(`target\a\a1.txt`, `2022-05-06T00:40:21+02:00`, `outdated`),
+	})
+	sourceDir := filepath.Join(dir, `source`)
+	targetDir := filepath.Join(dir, `target`)
+	fileintegrity.Upsert(sourceDir, fileintegrity.DisabledOptions())
+	fileintegrity.Upsert(targetDir, fileintegrity.DisabledOptions())
+	// Temporary file of an interrupted copy and a file with restricted permissions
+	stale := filepath.Join(targetDir, `.integrity`, `tmp`, `b1.md.fitmp`)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(stale), 0755))
+	assert.Nil(t, os.WriteFile(stale, []byte(`b1`), 0644))
+	assert.Nil(t, os.Chmod(filepath.Join(sourceDir, `a`, `a2.txt`), 0600))
+
+	fileintegrity.Copy(sourceDir, common.NormalizePath(`a/*.txt`), targetDir, fileintegrity.EnabledOptions())
+	assert.NoFileExists(t, stale)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(targetDir, `a`, `a2.txt`))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	common.AssertFilesExist(t, dir, append(files[:3],
+		common.NewFile(`target\a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`target\a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+	))
+	common.AssertIntegrityFile(t, targetDir, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a\a1.txt`),
+		common.NewFileHash(`2592c50e3d57402c5b5f2293bb2a52dfb38bfc91ae1c9a1f2452b798d53bf7c6`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a\a2.txt`),
+	})
+	common.AssertCopyLogFile(t, targetDir, 2, 1, 1, 0)
+
+	time.Sleep(time.Second)
+	fileintegrity.Verify(targetDir, fileintegrity.EnabledOptions())
+	common.AssertVerifyLogFile(t, targetDir, 2, 0)
+}
+
+func TestRepairFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("repair", common.Files{
+		common.NewFile(`archive\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`archive\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt`),
+		common.NewFile(`archive\b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+		common.NewFile(`archive\b\b2.md`, `2022-05-06T00:40:21+02:00`, `b2 sample md`),
+		common.NewFile(`replica\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample TXT`),
+		common.NewFile(`replica\renamed.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`replica\b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+	})
+	archiveDir := filepath.Join(dir, `archive`)
+	replicaDir := filepath.Join(dir, `replica`)
+	fileintegrity.Upsert(archiveDir, fileintegrity.DisabledOptions())
+	fileintegrity.Upsert(replicaDir, fileintegrity.DisabledOptions())
+	common.UpdateFile(archiveDir, `a1.txt`, `a1 sample TXT`, `2022-05-06T00:40:21+02:00`)
+	common.UpdateFile(archiveDir, `a2.txt`, `a2 sample TXT`, `2022-05-06T00:40:21+02:00`)
+	common.RemoveFile(archiveDir, `b\b1.md`)
+
+	fileintegrity.Repair(archiveDir, []string{replicaDir}, fileintegrity.EnabledOptions())
+
+	common.AssertFilesExist(t, archiveDir, common.Files{
+		common.NewFile(`a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample TXT`),
+		common.NewFile(`b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md`),
+		common.NewFile(`b\b2.md`, `2022-05-06T00:40:21+02:00`, `b2 sample md`),
+	})
+	common.AssertQuarantinedFile(t, archiveDir, `a1.txt`, `a1 sample TXT`)
+	common.AssertRepairLogFile(t, archiveDir, 1, 3, 2, 1)
+}
+
+func TestRepairFlow_parity(t *testing.T) {
+	dir, files := common.CreateScenario("repair.parity", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt `+common.StaticContent(10)),
+		common.NewFile(`a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt `+common.StaticContent(10)),
+		common.NewFile(`b\b1.md`, `2022-05-06T00:40:21+02:00`, `b1 sample md `+common.StaticContent(10)),
+	})
+	options := fileintegrity.DisabledOptions()
+	options.Redundancy = 10
+	fileintegrity.Upsert(dir, options)
+	common.AssertParityFileCount(t, dir, 3)
+
+	common.UpdateFile(dir, `a\a1.txt`, `a1 sample TXT `+common.StaticContent(10), `2022-05-06T00:40:21+02:00`)
+	fileintegrity.Repair(dir, []string{}, fileintegrity.EnabledOptions())
+
+	common.AssertFilesExist(t, dir, files)
+	common.AssertQuarantinedFile(t, dir, `a\a1.txt`, `a1 sample TXT `+common.StaticContent(10))
+	common.AssertRepairLogFile(t, dir, 2, 1, 1, 0)
+
+	common.RemoveFile(dir, `b\b1.md`)
+	fileintegrity.Upsert(dir, options)
+	common.AssertParityFileCount(t, dir, 2)
+}
+
+func TestRepairFlow_parityBackfill(t *testing.T) {
+	dir, files := common.CreateScenario("repair.parity.backfill", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt `+common.StaticContent(10)),
+		common.NewFile(`a\a2.txt`, `2022-05-06T00:40:21+02:00`, `a2 sample txt `+common.StaticContent(10)),
+	})
+	fileintegrity.Upsert(dir, fileintegrity.EnabledOptions())
+	common.AssertParityFileCount(t, dir, 0)
+
+	// Unchanged files receive their recovery data, once the redundancy is set
+	time.Sleep(time.Second)
+	options := fileintegrity.EnabledOptions()
+	options.Redundancy = 10
+	fileintegrity.Upsert(dir, options)
+	common.AssertUpsertLogFile(t, dir, 2, 0, 0, 0)
+	common.AssertLogFileSummaryLine(t, dir, "Recovery data created files:", 2)
+	common.AssertParityFileCount(t, dir, 2)
+
+	common.UpdateFile(dir, `a\a1.txt`, `a1 sample TXT `+common.StaticContent(10), `2022-05-06T00:40:21+02:00`)
+	fileintegrity.Repair(dir, []string{}, fileintegrity.EnabledOptions())
+	common.AssertFilesExist(t, dir, files)
+	common.AssertQuarantinedFile(t, dir, `a\a1.txt`, `a1 sample TXT `+common.StaticContent(10))
+}
+
+func TestConfigFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("config", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`a\a1.tmp`, `2022-05-06T00:40:21+02:00`, `a1 sample tmp`),
+		common.NewFile(`cache\c1.txt`, `2022-05-06T00:40:21+02:00`, `c1 sample txt`),
+	})
+	config, err := fileintegrity.LoadConfig(dir)
+	assert.Nil(t, err)
+	config.Hash = "sha512"
+	config.Ignore = []string{"*.tmp", "cache/"}
+	options := fileintegrity.EnabledOptions()
+	options.Config = config
+
+	assert.Nil(t, fileintegrity.Upsert(dir, options))
+
+	common.AssertIntegrityFile(t, dir, []common.FileHash{
+		common.NewFileHash(`15ad9357c0a06c4062f8f2ae9829016f8d28fec3a8adb6d5e6bbc282b1e43e1ee6257a147f312bff8f54f2e739043440e8ce1454f126d9545d440b869da3c4f3`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a\a1.txt`),
+	})
+	common.AssertUpsertLogFile(t, dir, 0, 1, 0, 0)
+
+	assert.Nil(t, fileintegrity.Verify(dir, options))
+	common.AssertVerifyLogFile(t, dir, 1, 0)
+
+	// Hashes of another algorithm are not comparable
+	assert.ErrorContains(t, fileintegrity.Verify(dir, fileintegrity.EnabledOptions()), "another hash algorithm than sha256")
+
+	// An invalid config fails the execution
+	os.WriteFile(filepath.Join(dir, ".integrity", "config"), []byte("hash: md5\n"), 0644)
+	assert.ErrorContains(t, fileintegrity.Verify(dir, fileintegrity.EnabledOptions()), "invalid config")
+	assert.ErrorContains(t, fileintegrity.CheckDuplicates(dir, fileintegrity.EnabledOptions()), "invalid config")
+
+	// The read buffer size only affects the memory usage
+	options.BufferSize = 4
+	assert.Nil(t, fileintegrity.Verify(dir, options))
+	common.AssertVerifyLogFile(t, dir, 1, 0)
+}
+
+func TestRetentionFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("retention", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	common.CreateDir(dir, `.integrity`)
+	for _, name := range []string{`230101.120000.upsert.log`, `230102.120000.upsert.log`, `230102.120000.verify.log`, `230101.120000.integrity.zip`} {
+		common.CreateFile(dir, filepath.Join(`.integrity`, name), `old`, `2023-01-02T12:00:00+00:00`)
+	}
+	config, err := fileintegrity.LoadConfig(dir)
+	assert.Nil(t, err)
+	config.Retention.Logs.KeepLast = 2
+	config.Retention.Backups.KeepLast = 1
+	options := fileintegrity.EnabledOptions()
+	options.Config = config
+
+	assert.Nil(t, fileintegrity.Upsert(dir, options))
+
+	// The newest logs are kept per command
+	assert.NoFileExists(t, filepath.Join(dir, `.integrity`, `230101.120000.upsert.log`))
+	assert.FileExists(t, filepath.Join(dir, `.integrity`, `230102.120000.upsert.log`))
+	assert.FileExists(t, filepath.Join(dir, `.integrity`, `230102.120000.verify.log`))
+	assert.FileExists(t, filepath.Join(dir, `.integrity`, `230101.120000.integrity.zip`))
+	common.AssertUpsertLogFile(t, dir, 0, 1, 0, 0)
+
+	// Reading executions hold a shared lock and do not prune
+	common.CreateFile(dir, filepath.Join(`.integrity`, `230101.120000.upsert.log`), `old`, `2023-01-02T12:00:00+00:00`)
+	assert.Nil(t, fileintegrity.Verify(dir, options))
+	assert.FileExists(t, filepath.Join(dir, `.integrity`, `230101.120000.upsert.log`))
+}
+
+func TestFsckFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("fsck", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile(`b\b1.txt`, `2022-05-06T00:40:21+02:00`, `b1 sample txt`),
+	})
+	assert.Nil(t, fileintegrity.Upsert(dir, fileintegrity.EnabledOptions()))
+	f, err := os.OpenFile(filepath.Join(dir, `.integrity`, `.integrity`), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	f.WriteString("malformed entry\n")
+	f.Close()
+
+	// Logs are ordered by their timestamp in seconds
+	time.Sleep(time.Second)
+	assert.Nil(t, fileintegrity.Fsck(dir, false, fileintegrity.EnabledOptions()))
+	common.AssertLogFileContains(t, dir, "MALFORMED  line 3")
+	common.AssertLogFileSummaryLine(t, dir, "Malformed lines:", 1)
+
+	time.Sleep(time.Second)
+	assert.Nil(t, fileintegrity.Fsck(dir, true, fileintegrity.EnabledOptions()))
+	common.AssertLogFileSummaryLine(t, dir, "Salvaged entries:", 2)
+	assert.Nil(t, fileintegrity.Verify(dir, fileintegrity.EnabledOptions()))
+	common.AssertVerifyLogFile(t, dir, 2, 0)
+}
+
+func TestLockFlow(t *testing.T) {
+	dir, _ := common.CreateScenario("lock", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	assert.Nil(t, fileintegrity.Upsert(dir, fileintegrity.EnabledOptions()))
+	lockFile := filepath.Join(dir, `.integrity`, `lock`)
+	assert.Nil(t, os.WriteFile(lockFile, []byte(`{"pid":42,"host":"other","acquired":"2024-01-01T12:00:00Z"}`), 0644))
+
+	err := fileintegrity.Verify(dir, fileintegrity.EnabledOptions())
+	assert.ErrorContains(t, err, "locked (exclusive) by pid 42 on other")
+	err = fileintegrity.CheckDuplicates(dir, fileintegrity.EnabledOptions())
+	assert.ErrorContains(t, err, "locked (exclusive) by pid 42 on other")
+
+	// The lock is released by the other host in the meantime
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		os.Remove(lockFile)
+	}()
+	options := fileintegrity.EnabledOptions()
+	options.LockWait = 10 * time.Second
+	assert.Nil(t, fileintegrity.Verify(dir, options))
+	common.AssertVerifyLogFile(t, dir, 1, 0)
+	assert.NoFileExists(t, lockFile)
+
+	// The exclusive lock of the target conflicts with the shared lock of the source
+	err = fileintegrity.Sync(dir, filepath.Join(dir, "."), false, false, options)
+	assert.ErrorContains(t, err, "source and target are the same directory")
+}
+
+func TestPortabilityFlow(t *testing.T) {
+	// The name is stored decomposed (NFD) like on macOS
+	dir, _ := common.CreateScenario("portability", common.Files{
+		common.NewFile(`a\a1.txt`, `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+		common.NewFile("e\u0301.txt", `2022-05-06T00:40:21+02:00`, `a1 sample txt`),
+	})
+	// Integrity file of a former version created on windows
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, `.integrity`), 0755))
+	legacy := "85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301,2023-05-06T15:12:00+02:00,2022-05-06T00:40:21+02:00,13,a\\a1.txt\n" +
+		"85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301,2023-05-06T15:12:00+02:00,2022-05-06T00:40:21+02:00,13,\u00e9.txt\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, `.integrity`, `.integrity`), []byte(legacy), 0644))
+
+	assert.Nil(t, fileintegrity.Verify(dir, fileintegrity.EnabledOptions()))
+	common.AssertVerifyLogFile(t, dir, 2, 0)
+
+	// The next write migrates the integrity file into the canonical form
+	time.Sleep(time.Second)
+	assert.Nil(t, fileintegrity.Upsert(dir, fileintegrity.EnabledOptions()))
+	common.AssertUpsertLogFile(t, dir, 2, 0, 0, 0)
+	common.AssertIntegrityFile(t, dir, []common.FileHash{
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, `a/a1.txt`),
+		common.NewFileHash(`85b883632e34f9f140915c79f1f4d131f50784a1077c0b1516e38fe226f72301`, ``, `2022-05-06T00:40:21+02:00`, `13`, "\u00e9.txt"),
+	})
+	assert.FileExists(t, filepath.Join(dir, `.integrity`, `format`))
+}
+
 func TestDemoFlow(t *testing.T) {
 	dir, _ := common.CreateScenario("demo", common.Files{
 		common.NewFile(`images\2020 Yellowstone National Park\IMG_0091.jpg`, `2020-05-06T13:40:00+00:00`, common.StaticContent(5120)),